@@ -0,0 +1,83 @@
+package fig
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// latin1 maps the bytes 0x80-0xff of ISO-8859-1 to their runes, which
+// are the code points of the same value.
+var latin1 = func() *[128]rune {
+	var t [128]rune
+	for i := range t {
+		t[i] = rune(0x80 + i)
+	}
+	return &t
+}()
+
+// windows1252 maps the bytes 0x80-0xff of Windows-1252 to their runes.
+// It differs from ISO-8859-1 in the range 0x80-0x9f, in which the bytes
+// that are undefined are mapped to the code points of the same value.
+var windows1252 = func() *[128]rune {
+	t := *latin1
+	copy(t[:32], []rune{
+		'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+		0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+	})
+	return &t
+}()
+
+// charsetTable returns the table that maps the non-ASCII bytes of the
+// named charset to runes, or nil if the charset is UTF-8 and so needs no
+// conversion. Names are matched ignoring case, dashes and underscores.
+func charsetTable(charset string) (*[128]rune, error) {
+	name := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(charset))
+	switch name {
+	case "utf8":
+		return nil, nil
+	case "iso88591", "latin1", "l1":
+		return latin1, nil
+	case "windows1252", "cp1252":
+		return windows1252, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", charset)
+	}
+}
+
+// charsetReader is an io.Reader that converts the bytes read from r,
+// which are in a single-byte charset described by table, to UTF-8.
+type charsetReader struct {
+	r     io.Reader
+	table *[128]rune
+	src   []byte // bytes read from r.
+	buf   []byte // converted bytes that are yet to be read.
+	err   error  // error returned by r, returned once buf is drained.
+}
+
+func (cr *charsetReader) Read(p []byte) (int, error) {
+	for len(cr.buf) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		if cr.src == nil {
+			cr.src = make([]byte, 4096)
+		}
+
+		var n int
+		n, cr.err = cr.r.Read(cr.src)
+		cr.buf = cr.buf[:0]
+		for _, b := range cr.src[:n] {
+			if b < utf8.RuneSelf {
+				cr.buf = append(cr.buf, b)
+			} else {
+				cr.buf = utf8.AppendRune(cr.buf, cr.table[b-utf8.RuneSelf])
+			}
+		}
+	}
+
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	return n, nil
+}
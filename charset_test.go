@@ -0,0 +1,92 @@
+package fig
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func Test_charsetReader(t *testing.T) {
+	for _, tc := range []struct {
+		Name    string
+		Charset string
+		In      []byte
+		Want    string
+	}{
+		{Name: "latin1", Charset: "ISO-8859-1", In: []byte("caf\xe9 \xbd \xff"), Want: "café ½ ÿ"},
+		{Name: "windows-1252", Charset: "cp1252", In: []byte("\x80 \x93hi\x94 \xe9 \x81"), Want: "€ “hi” é \u0081"},
+		{Name: "ascii", Charset: "latin_1", In: []byte("plain"), Want: "plain"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			table, err := charsetTable(tc.Charset)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			// read a byte at a time to exercise buffering
+			got, err := io.ReadAll(iotest.OneByteReader(&charsetReader{r: bytes.NewReader(tc.In), table: table}))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if string(got) != tc.Want {
+				t.Errorf("want %q, got %q", tc.Want, got)
+			}
+		})
+	}
+
+	t.Run("utf-8", func(t *testing.T) {
+		table, err := charsetTable("UTF8")
+		if err != nil || table != nil {
+			t.Fatalf("want nil table and err, got %v, %v", table, err)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		if _, err := charsetTable("shift-jis"); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		r := &charsetReader{r: iotest.ErrReader(iotest.ErrTimeout), table: latin1}
+		if _, err := io.ReadAll(r); err != iotest.ErrTimeout {
+			t.Fatalf("want err %v, got %v", iotest.ErrTimeout, err)
+		}
+	})
+}
+
+func TestEncoding(t *testing.T) {
+	type Config struct {
+		City string `fig:"city"`
+	}
+
+	data := []byte("city: M\xfcnchen\n")
+
+	var cfg Config
+	if err := Load(&cfg, Reader(bytes.NewReader(data), DecoderYaml), Encoding("latin1")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.City != "München" {
+		t.Errorf("want %q, got %q", "München", cfg.City)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(bytes.NewReader([]byte("{\"city\": \"Gen\xe8ve\"}")), DecoderJSON), Encoding("windows-1252"))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.City != "Genève" {
+			t.Errorf("want %q, got %q", "Genève", cfg.City)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader("city: x\n"), DecoderYaml), Encoding("ebcdic")); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
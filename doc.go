@@ -63,6 +63,10 @@ Define your struct and load it:
 
 Pass options as additional parameters to `Load()` to configure fig's behaviour.
 
+With `FromEnvConfig()` some options can also be given by environment variables, so that they can be changed without
+changing code. `FIG_CONFIG_FILE` gives the path of the config file and `FIG_STRICT=1` enables strict parsing. Options passed
+to `Load()` take precedence over these variables.
+
 # IgnoreFile
 
 Do not look for any configuration file with `IgnoreFile()`.
@@ -83,8 +87,97 @@ searches in with `File()` and `Dirs()`.
 
 Fig searches for the file in dirs sequentially and uses the first matching file.
 
+Give several filenames in order of priority with `Files()` to use the first of them that exists, e.g. a local file that fully
+replaces the base file when present. The files are not merged. Every one of the dirs is searched for a filename before the next
+filename is tried, so a higher priority file in any of the dirs wins over a lower priority one in the first dir.
+
+	fig.Load(&cfg,
+	  fig.Files("config.local.yaml", "config.yaml"),
+	  fig.Dirs(".", "/etc/myapp"),
+	)
+
+With `SkipInvalidFiles()` a matching file that fails to decode is reported to a callback and skipped, and the search continues
+in the next directory. Load only fails if no matching file can be decoded.
+
+Use `SystemThenUser()` to follow the common convention of a system-wide config file that is overlaid by a user config file.
+Both files are optional and the values of the user file are merged over those of the system file.
+
+	fig.Load(&cfg, fig.SystemThenUser("myapp")) // /etc/myapp/config.yaml, then ~/.config/myapp/config.yaml
+
+Use `RequireFile()` along with it to fail with `ErrFileNotFound` if neither file exists.
+
+Defaults can be kept in a file of their own with `DefaultsFile()`. The config is merged over the values of the defaults file,
+and the default tags of fields still apply to any that are left unset.
+
+	fig.Load(&cfg, fig.DefaultsFile("defaults.yaml"))
+
+`DefaultsFS()` reads the defaults file from an `fs.FS` instead, such as an `embed.FS` that ships the defaults inside the binary.
+
+	//go:embed defaults.yaml
+	var defaults embed.FS
+
+	fig.Load(&cfg, fig.DefaultsFS(defaults, "defaults.yaml"))
+
+Limit the size of the config that fig reads with `MaxFileSize()`. Larger configs, including gzip files that decompress to a
+larger size, result in an error.
+
+Configs are assumed to be UTF-8. Use `Encoding()` to read a config in a legacy encoding such as ISO-8859-1 or Windows-1252.
+
+	fig.Load(&cfg, fig.Encoding("latin1"))
+
+Only the first document of a yaml config that contains several documents separated by `---` is loaded. Select another document
+by its index with `YAMLDocument()`, or by the value of one of its top-level keys with `YAMLDocumentWhere()`.
+
+	fig.Load(&cfg, fig.YAMLDocumentWhere("env", "prod"))
+
 The decoder (yaml/json/toml) used is picked based on the file's extension.
 
+Files compressed with gzip are decompressed before being decoded if their name ends in `.gz`, in which case the decoder is picked
+based on the preceding extension (e.g. `config.yaml.gz` is decoded as yaml).
+
+A yaml or json config whose top level is a list rather than a mapping is loaded into the only slice field of the struct. It is
+an error for the struct to have any other number of slice fields, while its other fields can still be set by the environment
+and defaults.
+
+	type Config struct {
+	  Jobs []Job `fig:"jobs"` // - name: backup
+	}
+
+Map fields may have keys of any basic type, e.g. `map[int]string` or `map[bool]string`. Keys in the config file are converted
+to the key type of the field, and a key that cannot be converted results in an error.
+
+Use `NormalizeMapKeys()` to rewrite the keys of map fields before they are decoded, e.g. with `strings.ToLower` so that
+`Prod` and `prod` are the same entry. Keys of one map that are rewritten into the same key are folded in their sorted order:
+maps are merged and otherwise the value of the last key wins.
+
+# URL
+
+Fetch the config file over HTTP with `URL()` instead of searching for it on the file system.
+
+	fig.Load(&cfg, fig.URL("https://config.internal/myapp.yaml"))
+
+The decoder is picked based on the extension of the URL's path. Use `HTTPClient()` to configure the client used to fetch the file.
+
+# Reader
+
+Read the config from an `io.Reader` with `Reader()`, stating its format, or with `ReaderNamed()`, which picks the decoder based
+on a filename in the same way as `File()`.
+
+	fig.Load(&cfg, fig.Reader(strings.NewReader(data), fig.DecoderToml))
+	fig.Load(&cfg, fig.ReaderNamed(upload, "config.yaml"))
+
+If the format is not known then `DecoderAuto` guesses it from the first line that is neither blank nor a comment: `{`, or `[`
+other than a toml table header, means json and `key = value` means toml. Anything else is decoded as yaml.
+
+# Subtree
+
+Load only part of the config file into the struct with `Subtree()`. Nested keys are separated by a dot.
+
+	fig.Load(&dbCfg, fig.Subtree("services.database"))
+
+By default `Load` returns an error wrapping `ErrSubtreeNotFound` if the subtree does not exist. Use `AllowMissingSubtree()`
+to load an empty subtree instead, in which case only defaults and environment variables are applied.
+
 # Tag
 
 The struct tag key tag fig looks for to find the field's alt name can be changed using `Tag()`.
@@ -128,6 +221,27 @@ environment variables:
 	MYAPP_LOG_LEVEL
 	MYAPP_SERVER_HOST
 
+Use `EnvPrefixes(prefixes...)` instead of `UseEnv` to search for environment variables with more than one prefix. Prefixes are
+tried in the order given and the first variable that exists is used.
+
+	fig.Load(&cfg, fig.EnvPrefixes("myapp", "oldapp")) // MYAPP_LOG_LEVEL, then OLDAPP_LOG_LEVEL
+
+Use `EnvKeys()` to list the environment variables fig looks up for a struct without loading it, e.g. to document them.
+
+	keys, err := fig.EnvKeys(&cfg, "myapp") // [MYAPP_BUILD MYAPP_LOG_LEVEL MYAPP_SERVER_HOST]
+
+Field names without an alt name are only upper-cased, so a field `LogLevel` maps to `LOGLEVEL`. Use `EnvSplitCamelCase()` to
+separate the words of camel-cased field names with underscores, in which case `LogLevel` maps to `LOG_LEVEL`.
+
+Alt names are used in place of field names in environment keys. If the keys of the config file and environment variables follow
+different conventions, use `EnvUseFieldName()` to form environment keys from field names only.
+
+	type Config struct {
+	  LogLevel string `fig:"log"`
+	}
+
+	fig.Load(&cfg, fig.UseEnv("myapp"), fig.EnvUseFieldName(), fig.EnvSplitCamelCase()) // MYAPP_LOG_LEVEL
+
 Fields contained in struct slices whose elements already exists can be also be set via the environment in the form PARENT_IDX_FIELD, where idx is the index of the field in the slice.
 
 	type Config struct {
@@ -144,9 +258,65 @@ With the config above individual servers may be configured with the following en
 
 Note: the Server slice must already have members inside it (i.e. from loading of the configuration file) for the containing fields to be altered via the environment. Fig will not instantiate and insert elements into the slice.
 
+A scalar given for a slice field, in the config file or in the environment, is set as a slice of one element, so `hosts: kafka1`
+and `HOSTS=kafka1` both set a `[]string` to `[kafka1]`.
+
+Slices set from the environment replace any existing value. With the `MergeEnvSlices()` option a value prefixed by `+` is
+appended to the existing slice and a value prefixed by `-` has its elements removed from it.
+
+	MYAPP_PORTS=+[8080]
+	MYAPP_PORTS=-[443]
+
+Fields of an embedded struct that is squashed with a `fig:",squash"` tag are treated as fields of the surrounding struct, both in
+the config file and in the environment.
+
+	type Base struct {
+	  Host string `fig:"host" default:"127.0.0.1"`
+	}
+
+	type Config struct {
+	  Base `fig:",squash"`
+	}
+
+With the struct above and `UseEnv("myapp")` fig would search for `MYAPP_HOST`.
+
+A field is never set from the environment if its tag contains `env:"-"` or a `noenv` flag, which also applies to the fields
+it contains. This is useful for values that must only come from the config file. Any other value of an `env` tag, such as the
+variable names used by other env libraries, is ignored and is only reported by `StrictTags()`.
+
+	type Config struct {
+	  Host     string
+	  Password string `fig:"password" env:"-"` // or `fig:"password,noenv"`
+	}
+
+With `EnvFile(".env.local")` the KEY=VALUE lines of a .env file are used as environment variables, beneath the actual
+environment, which is handy for local development. A variable that is set in the environment always wins over the file. The
+variables of the file are also seen by the ${VAR} references of defaults expanded with `ExpandEnvDefaults()`.
+
+Values found in the environment silently override those of the config file. With `ConflictError()` fig instead returns an
+error for every field that the environment sets to a different value than the config file.
+
+Entries of map fields are set from variables named after the map followed by the entry's key, which is lower-cased. Values are
+parsed according to the map's value type in the same way as defaults. A variable that names another field, such as
+`MYAPP_QUOTAS_ENABLED` for a field `quotas_enabled` next to the map `quotas`, sets that field rather than a map entry.
+
+	type Config struct {
+	  Quotas map[string]int `fig:"quotas"`
+	}
+
+	MYAPP_QUOTAS_FREE=10 // quotas[free] = 10
+	MYAPP_QUOTAS_PRO=100 // quotas[pro] = 100
+
+With the `EnvJSON()` option a struct or map field can be given a json object, which is decoded over its value from the config
+file. Variables of the fields or entries within it are applied afterwards, so the finer grained variable wins.
+
+	MYAPP_SERVER={"host":"0.0.0.0","port":8080}
+	MYAPP_SERVER_PORT=9090 // server.port = 9090
+
 # Environment Limitations
 
-Maps and map values cannot be populated from the environment.
+Maps of structs, maps and interfaces cannot be populated from the environment. Every variable that starts with the name of a map
+supported by the environment followed by an underscore is taken to be one of its entries.
 
 # Time
 
@@ -164,10 +334,131 @@ Change the layout fig uses to parse times using `TimeLayout()`.
 
 By default fig parses time using the `RFC.3339` layout (`2006-01-02T15:04:05Z07:00`).
 
+The layout of an individual field can be overridden with a `timelayout` key in the field's struct tag. The field's layout is used when parsing
+its value from the config file, the environment and its default, while all other fields continue to use the global layout.
+
+	type Config struct {
+	  Date      time.Time `fig:"date" timelayout:"2006-01-02"`
+	  Timestamp time.Time `fig:"timestamp"`
+	}
+
+A config file can declare its own layout in a top-level key that is named with `TimeLayoutFromKey()`. The key is removed before
+the file is decoded into the struct.
+
+	// config.yaml
+	_time_layout: "2006-01-02"
+	date: "2019-12-25"
+
+	fig.Load(&cfg, fig.TimeLayoutFromKey("_time_layout"))
+
+The layout only applies to times that are given as strings. Unquoted timestamps in yaml files and datetimes in toml files are
+decoded natively and used as is, with local toml dates and times taken to be in UTC. A native timestamp that is decoded into a
+string field is formatted using the layout.
+
+	// config.toml
+	built = 1979-05-27T07:32:00Z // used as is
+	date  = "12-25-2019"         // parsed using the layout
+
+# Byte Sizes
+
+Integer fields that contain a `bytes` flag in their tag accept human-readable byte sizes from the config file, the environment
+and their default.
+
+	type Config struct {
+	  MaxBodySize int64 `fig:"max_body_size,bytes" default:"10MB"`
+	}
+
+A size is a number followed by an optional, case-insensitive unit. SI units (`KB`, `MB`, `GB`, `TB`, `PB`, `EB`) are powers
+of 1000 and IEC units (`KiB`, `MiB`, `GiB`, `TiB`, `PiB`, `EiB`) are powers of 1024, so `10MB` is 10000000 bytes while `10MiB`
+is 10485760 bytes. A size without a unit, or with the unit `B`, is a number of bytes.
+
+# Hex
+
+Byte slices and arrays that contain a `hex` flag in their tag are decoded from a hex string, in the config file, the
+environment and their default. An array must be given exactly as many bytes as its length. Dump writes such fields as hex.
+
+	type Config struct {
+	  Key [32]byte `fig:"key,hex"`
+	}
+
+Quote values in a yaml config file that yaml would otherwise take as a number, e.g. "0001".
+
+# Relative Paths
+
+String fields that contain a `relpath` flag in their tag hold paths that are relative to the config file. A relative path in the
+config file is made absolute by joining it with the directory of the file.
+
+	type Config struct {
+	  CertFile string `fig:"cert_file,relpath"` // cert_file: certs/server.pem => /etc/myapp/certs/server.pem
+	}
+
+Pointers to strings and slices of strings are resolved element by element. Paths set by the environment or by a default are
+left relative to the working directory, as are paths in configs loaded from a url or a reader.
+
+Each path is relative to the file that sets it, so paths in the system file of `SystemThenUser()` are joined with the
+system directory, and paths in a `DefaultsFile()` with the directory of the defaults file. Paths in a `DefaultsFS()` file are
+left as they are.
+
+# Transforms
+
+A `transform` tag normalizes the value of a string field once it is set, whichever source it is set from. The transforms are
+`lower`, `upper`, `trim`, which trims leading and trailing white space, and `title`, which upper-cases the first letter of each
+word. Several transforms separated by commas are applied in order.
+
+	type Config struct {
+	  Host string   `fig:"host" transform:"trim,lower"` // host: " Example.COM " => example.com
+	  Tags []string `fig:"tags" transform:"trim"`
+	}
+
+Pointers to strings and slices of strings are transformed element by element, before any string validations of the field. An
+unknown transform results in an error.
+
+# Raw JSON
+
+Fields of type `json.RawMessage` capture the value of their key as json, regardless of the format of the config file, which
+is useful for sections that are parsed later on.
+
+	type Config struct {
+	  Plugins json.RawMessage `fig:"plugins"`
+	}
+
+The value is re-encoded from its decoded form, so the formatting and key order of a json config file are not preserved.
+Environment variables and defaults of such fields must contain valid json.
+
+# Custom Decoding
+
+A config type that implements `MapDecoder` decodes the values of the config file itself. Its `Decode` method is
+called with the values in place of fig's decoding, while the environment, defaults and validations are applied afterwards
+as usual.
+
+	func (c *Config) Decode(vals map[string]interface{}) error {
+	  ...
+	}
+
+Fields set by `Decode` are not known to fig, so they do not count as present in the config file and `UseStrict()` reports
+no unknown keys.
+
 # Strict Parsing
 
 By default fig ignores any fields in the config file that are not present in the struct. This behaviour can be changed using `UseStrict()` to achieve strict parsing.
-When strict parsing is enabled, extra fields in the config file will cause an error.
+When strict parsing is enabled, extra fields in the config file will cause an error of type `*ErrUnknownKeys` that lists the paths of all the extra fields.
+
+	var unknown *fig.ErrUnknownKeys
+	if errors.As(err, &unknown) {
+	  fmt.Println(unknown.Keys) // [logger.format tls]
+	}
+
+Yaml and toml configs that contain the same key twice in a mapping are rejected. Json configs take the value of the last key
+instead, unless `RejectDuplicateKeys()` is used, in which case the path of the duplicated key is returned as an error.
+
+A map field tagged with the `remain` flag collects the keys of its struct that no other field matches, instead of them being ignored
+or, in strict mode, reported as unknown. Remain fields are never set from the environment, and `Dump` writes their entries alongside
+the other fields of the struct.
+
+	type Config struct {
+	  Name    string                 `fig:"name"`
+	  Plugins map[string]interface{} `fig:",remain"`
+	}
 
 # Required
 
@@ -177,6 +468,12 @@ A validate key with a required value in the field's struct tag makes fig check i
 	  Host string `fig:"host" validate:"required"` // or simply `validate:"required"`
 	}
 
+A required flag in the `fig` tag is equivalent to the validate key, in the style of `encoding/json`:
+
+	type Config struct {
+	  Host string `fig:"host,required"`
+	}
+
 Fig uses the following properties to check if a field is set:
 
 	basic types:           != to its zero value ("" for str, 0 for int, etc.)
@@ -185,9 +482,25 @@ Fig uses the following properties to check if a field is set:
 	structs:               always true (use a struct pointer to check for struct presence)
 	time.Time:             !time.IsZero()
 	time.Duration:         != 0
+	IsZeroer:              !IsZero()
 
 	*pointers to non-struct types (with the exception of time.Time) are de-referenced if they are non-nil and then checked
 
+Types that implement the `IsZeroer` interface define their own zero value, which lets structs and other user types be required.
+
+With `DeepRequired()` a required struct is instead considered set if any of its fields, or the fields of the structs it contains,
+is set other than to its default.
+
+A pointer to a struct that is required only needs to be non-nil, so a section that is present but empty passes. Use the
+`required_nonempty` validation to also require the struct to have content, in the same sense as with `DeepRequired()`:
+
+	type Config struct {
+	  Section *Section `fig:"section" validate:"required"`          // passes for `section: {}`
+	  Content *Section `fig:"content" validate:"required_nonempty"` // fails for `content: {}`
+	}
+
+On fields that are not structs `required_nonempty` is the same as `required`.
+
 See example below to help understand:
 
 	type Config struct {
@@ -223,6 +536,108 @@ See example below to help understand:
 	fmt.Print(err)
 	// A: required validation failed, B: required validation failed, C: required validation failed, D: required validation failed, E: required validation failed, G: required validation failed, H.J: required validation failed, K: required validation failed, M: required validation failed, N: required validation failed
 
+# Conditionally required
+
+A field can be made required depending on whether a sibling field (a field of the same struct) is set. The sibling is referred
+to by either its struct field name or its alt name.
+
+	type Config struct {
+	  Email string `fig:"email" validate:"required_without=phone"` // required if phone is not set
+	  Phone string `fig:"phone" validate:"required_without=email"` // required if email is not set
+	  Cert  string `fig:"cert" validate:"required_with=key"`       // required if key is set
+	  Key   string `fig:"key" validate:"required_with=cert"`       // required if cert is set
+	}
+
+Fields that are alternatives to one another can be placed in a required group, in which case exactly one field of the group
+must be set. A group is made up of the sibling fields that share its name, and is reported under its name if the validation fails.
+
+	type Config struct {
+	  Token    string `fig:"token" validate:"required_group=auth"`
+	  Password string `fig:"password" validate:"required_group=auth"`
+	  KeyFile  string `fig:"key_file" validate:"required_group=auth"`
+	}
+	// auth: exactly one of [token password key_file] must be set
+
+Multiple validations in the validate key are separated by a comma.
+
+# Strict Tags
+
+By default fig ignores parts of struct tags it does not recognise. With `StrictTags()` the tags of all fields are checked before
+anything is loaded, and unknown flags or validations such as a misspelled `validate:"requird"` result in an error.
+
+	fig.Load(&cfg, fig.StrictTags())
+
+# String validations
+
+The `email`, `url` and `hostname` validations check that a string field holds a valid email address, absolute url or RFC 1123
+hostname respectively. The elements of string slices are checked individually.
+
+	type Config struct {
+	  Admin string   `fig:"admin" validate:"required,email"`
+	  Docs  string   `fig:"docs" validate:"url"`
+	  Peers []string `fig:"peers" validate:"hostname"`
+	}
+
+The `contains=substr` and `excludes=substr` validations check that a string field does or does not contain a substring.
+
+	type Config struct {
+	  DSN string `fig:"dsn" validate:"contains=sslmode=,excludes=password"`
+	}
+
+Empty values are not checked by any of these validations, so combine them with `required` to reject empty values.
+The errors of these validations refer to the value of a secret field as just "value", e.g. `dsn: url validation failed:
+value is not a valid url`.
+
+The keys of a map field with string keys are checked with `keys:` followed by the name of a format validation. Keys set by the
+environment are checked as well as those of the config file.
+
+	type Config struct {
+	  Endpoints map[string]Endpoint `fig:"endpoints" validate:"keys:hostname"`
+	}
+
+An invalid key is reported as e.g. `endpoints: invalid key "bad host": hostname validation failed`.
+
+# Unique elements
+
+The `unique` validation checks that the elements of a slice or array field are distinct. With `unique=name` the elements
+must be structs, or pointers to structs, and are compared by their field with that name or alt name instead.
+
+	type Config struct {
+	  Ports      []int       `fig:"ports" validate:"unique"`
+	  Containers []Container `fig:"containers" validate:"unique=name"`
+	}
+
+A duplicate is reported along with the index of the element that repeats it, e.g. `containers: unique validation failed:
+duplicate name "redis" at index 2`, which leaves out the value of a secret field. Nil elements are skipped.
+
+# Presence
+
+Use `PresenceAware()` to have fig track which fields were explicitly provided by the config file or the environment.
+With presence-awareness enabled a required field is considered set if it was provided, even if its value is the zero value for its type.
+
+	type Config struct {
+	  Port int `fig:"port" validate:"required"`
+	}
+
+	// config.yaml
+	port: 0
+
+	fig.Load(&cfg, fig.PresenceAware()) // passes validation
+
+Fields explicitly set to `null` in the config file are not considered present.
+
+By default a field that is `null` in the config file is set to its default, the same as if its key were missing. With
+`NullAsEmpty()` a null value instead means that the field is explicitly empty, and the field is left at its zero value.
+
+	type Config struct {
+	  Proxy string `fig:"proxy" default:"http://proxy:3128"`
+	}
+
+	// config.yaml
+	proxy: null
+
+	fig.Load(&cfg, fig.NullAsEmpty()) // cfg.Proxy == ""
+
 # Default
 
 A default key in the field tag makes fig fill the field with the value specified when the field is not otherwise set.
@@ -233,13 +648,44 @@ Fig attempts to parse the value based on the field's type. If parsing fails then
 	  Port int `fig:"port" default:"8000"` // or simply `default:"8000"`
 	}
 
+A default can also be given as the last flag of the `fig` tag. Everything after `default=` is taken as the value, so it may
+contain commas:
+
+	type Config struct {
+	  Port  int      `fig:"port,default=8000"`
+	  Hosts []string `fig:"hosts,default=[a,b]"`
+	}
+
 A default value can be set for the following types:
 
-	all basic types except bool and complex
+	all basic types except complex
 	time.Time
 	time.Duration
-	*regexp.Regexp
-	slices (of above types)
+	time.Weekday, time.Month
+	regexp.Regexp, *regexp.Regexp
+	big.Rat
+	types that implement fmt.Scanner
+	slices and arrays (of above types)
+	sets such as map[string]struct{} (of above types)
+	pointers (to above types)
+	slices of pointers (to above types)
+	structs and pointers to structs (see below)
+
+A big.Rat is parsed with `SetString`, so it accepts fractions such as "1/3" as well as decimals. Any other type whose pointer
+implements `fmt.Scanner`, including big.Int, is scanned from the value, which must not contain anything after what is scanned.
+Config file values of these types may be given as strings or numbers.
+
+A time.Weekday or time.Month is given by its full or three letter name in any case, such as "Monday", "mon" or "JAN", or by
+its number. Names are accepted in the config file and the environment as well.
+
+Integers may be written with the prefixes of Go literals, 0x for hexadecimal, 0o for octal and 0b for binary, so
+`default:"0o644"` sets 420. Other leading zeros are ignored, so `default:"010"` sets 10. This also applies to integers set from
+the environment.
+
+Floats accept the infinities and NaN as parsed by `strconv.ParseFloat`, i.e. "Inf", "+Inf", "-Inf", "Infinity" and "NaN" in
+any case, in defaults, the environment and as strings in the config file. A NaN counts as set for the required validation.
+
+Nil pointers, including the elements of slices of pointers, are allocated and set to the default value. If the default value cannot be parsed then the pointer is left nil.
 
 Successive elements of slice defaults should be separated by a comma. The entire slice can optionally be enclosed in square brackets:
 
@@ -247,11 +693,103 @@ Successive elements of slice defaults should be separated by a comma. The entire
 	  Durations []time.Duration `default:"[30m,1h,90m,2h]"` // or `default:"30m,1h,90m,2h"`
 	}
 
+Elements may be signed, so `default:"[-1.5e3,2.5]"` and `default:"[-30m,1h]"` give negative first elements. Spaces around
+numeric elements are ignored.
+
+The elements of an array default are set positionally, so the default must give exactly as many elements as the array's length.
+An array is given its default only if all of its elements are zero.
+
+	type Config struct {
+	  Weights [3]float64 `default:"[1.0,0.5,0.25]"`
+	}
+
+A set is a map whose values are empty structs. Sets are given as lists, in the config file as well as in the environment and
+defaults, and each element of the list becomes a key of the set. `Dump` writes sets as sorted lists.
+
+	type Config struct {
+	  Tags map[string]struct{} `fig:"tags" default:"[a,b,c]"`
+	}
+
+Commas inside balanced brackets, braces or parentheses do not separate elements, and an element can be enclosed in single or
+double quotes to contain any other commas:
+
+	type Config struct {
+	  Patterns []*regexp.Regexp `default:"[[a-z]{2,4},\\d+,'a,b']"`
+	}
+
+With the `ExpandEnvDefaults()` option, references to environment variables of the form `${VAR}` or `${VAR:-fallback}` are expanded in
+default values. Each element of a slice default is expanded individually:
+
+	type Config struct {
+	  Paths []string `default:"[${HOME}/bin,${GOPATH:-/go}/bin]"`
+	}
+
+A struct field is given a default in a flow style mapping of its fields' names to their values, which may themselves be
+mappings or lists enclosed in square brackets. Values are converted in the same way as values in a config file. The default
+is only set if the struct is zero after loading the config file.
+
+	type Config struct {
+	  Server struct {
+	    Host string
+	    Port int
+	    TLS  struct {
+	      Enabled bool
+	    }
+	  } `default:"{host:localhost,port:8080,tls:{enabled:true}}"`
+	}
+
+Interface fields can only be given a default if a constructor for the interface type is registered with `RegisterDefault()`. The
+value returned by the constructor is set when the field is nil, and the value of the default key is ignored.
+
+	type Config struct {
+	  Notifier Notifier `fig:"notifier" default:"noop"`
+	}
+
+	fig.Load(&cfg, fig.RegisterDefault(reflect.TypeOf((*Notifier)(nil)).Elem(), func() interface{} {
+	  return noopNotifier{}
+	}))
+
+Defaults that cannot be written as literals can be computed by a resolver registered with `DefaultResolver()`. The resolver is
+given the field's path and the raw value of its default key, and the string it returns is parsed in place of the raw value.
+If the resolver returns false then the raw value is parsed as usual.
+
+	fig.Load(&cfg, fig.DefaultResolver(func(fieldPath, raw string) (string, bool) {
+	  s, ok := limits[raw] // e.g. default:"@MaxConns"
+	  return s, ok
+	}))
+
+A type that implements `StringEnum` lists the values it accepts, and defaults of fields of that type, or of slices of it, must
+be one of them. `Fields()` reports the accepted values of such fields.
+
+	type Level string
+
+	func (l *Level) UnmarshalString(s string) error { ... }
+
+	func (Level) Values() []string { return []string{"debug", "info", "error"} }
+
+	type Config struct {
+	  Level Level `fig:"level" default:"verbose"` // error: "verbose" is not one of [debug info error]
+	}
+
+Default values are not set at all when the `NoDefaults()` option is given, leaving fields that were not set by the config file
+or the environment at their zero value.
+
+A default is normally only parsed when it is set, so a malformed default on a field that is always provided goes unnoticed.
+With `ValidateDefaults()` the defaults of all fields are parsed before anything is loaded and every failure is reported.
+
+	fig.Load(&cfg, fig.ValidateDefaults())
+
 # Defaults Limitations
 
  1. Boolean values:
-    Fig cannot distinguish between false and an unset value for boolean types.
-    As a result, default values for booleans are not currently supported.
+    Fig cannot distinguish between false and an unset value by looking at a bool field.
+    Instead the default of a bool field is set only when the field is provided by neither
+    the config file nor the environment, so an explicit `color: false` or `MYAPP_COLOR=false`
+    is honored even if the default is true. This applies to every type whose kind is bool,
+    including named types such as `type Flag bool` and pointers to them.
+    Other types used as flags, such as an on/off enum of kind uint8, take defaults like any
+    other field, i.e. when they are zero. Give such a type a zero value that means unset so that
+    an explicit off is not replaced by the default.
 
  2. Maps:
     Maps are not supported because providing a map in a string form would be complex and error-prone.
@@ -271,6 +809,100 @@ This is not allowed:
 	  Level string `validate:"required" default:"warn"` // will result in an error
 	}
 
+# Multiple structs
+
+Load the same config file into several structs with `LoadAll()`, which reads and decodes the file only once. Each struct's
+defaults, environment variables and validations are processed independently.
+
+	err := fig.LoadAll([]interface{}{&dbCfg, &serverCfg}, fig.File("app.yaml"))
+
+In strict mode a key is only reported as unknown if none of the structs contain a field for it.
+
+# Migrations
+
+Register migrations with `Migration()` to bring config files written for older versions of a program up to date. The version
+of a config file is given by its top-level `version` key, and each migration transforms the values of the file from one version
+to the next before they're decoded.
+
+	fig.Load(&cfg,
+	  fig.Migration(1, migrateV1), // 1 -> 2
+	  fig.Migration(2, migrateV2), // 2 -> 3
+	)
+
+A file of version 1 is migrated by migrateV1 and then migrateV2, after which its version key is set to 3, the current version.
+A file without a version key is taken to be of the current version. It is an error if a file's version is newer than the current
+version, or if there is no migration from one of the versions along the way.
+
+# Post-processing
+
+Register a callback with `PostLoad()` to normalise fields once the config has been loaded and validated. The callback receives the
+populated struct, and an error returned from it fails the load.
+
+	fig.Load(&cfg, fig.PostLoad(func(cfg interface{}) error {
+	  c := cfg.(*Config)
+	  c.Host = strings.ToLower(c.Host)
+	  return nil
+	}))
+
+# Reloading
+
+`Reloadable()` loads a config and returns a holder whose `Current()` method gives lock-free access to the latest config that loaded
+successfully. Call `Reload()`, for example on SIGHUP, to load the config again. If a reload fails then the previous config is
+retained and the error is returned as well as delivered to the channel returned by `Errors()`.
+
+	cfg, err := fig.Reloadable[Config](fig.File("config.yaml"))
+	...
+	port := cfg.Current().Port
+
+# Dump
+
+Write a loaded config back out as yaml, json or toml with `Dump()`, which is useful to inspect the configuration an application
+actually computed. Keys are named after the `fig` tag, and the values of fields tagged with a `secret` flag are replaced by `***`.
+
+	type Config struct {
+	  Host     string `fig:"host"`
+	  Password string `fig:"password,secret"`
+	}
+
+	fig.Dump(&cfg, os.Stdout, fig.DecoderYaml)
+
+Pass the `Tag()` option that the config is loaded with to have `Dump` name keys after that tag instead.
+
+`Dump` writes the values of a config but not the comments or layout of the file it came from. To edit a yaml config file in
+place, retain the node of its document with `RetainYAMLNode()`, change it with `SetField()` and write it back with `WriteYAML()`,
+which keeps the file's comments.
+
+	var node yaml.Node
+	err := fig.Load(&cfg, fig.File("config.yaml"), fig.RetainYAMLNode(&node))
+	err = fig.SetField(&node, "server.port", 8443)
+	err = fig.WriteYAML(&node, w)
+
+# Fields
+
+`Fields()` describes the fields of a config struct, including their paths, defaults and validations, without loading anything.
+A description can be attached to a field with a `desc` key in its tag, which fig itself ignores. This is useful to generate
+documentation or command line flags that stay in sync with the struct.
+
+	type Config struct {
+	  Port int `fig:"port" desc:"port to listen on" default:"8080"`
+	}
+
+	fields, err := fig.Fields((*Config)(nil))
+
+Pass the `Tag()` option that the config is loaded with to have the paths follow that tag.
+
+# Logging
+
+Pass a printf-style function to `WithLogger()` to have fig log what it does while loading, e.g. which file it loaded, which
+environment variables it found and which fields were set to their default.
+
+	fig.Load(&cfg, fig.WithLogger(log.Printf))
+
+For a structured account of a whole load use `Trace()`, which is called with an event for each file searched, key decoded,
+environment variable looked up, default applied and validation run.
+
+	fig.Load(&cfg, fig.Trace(func(e fig.TraceEvent) { fmt.Println(e) }))
+
 # Errors
 
 A wrapped error `ErrFileNotFound` is returned when fig is not able to find a config file to load. This can be useful for instance to fallback to a different configuration loading mechanism.
@@ -280,5 +912,20 @@ A wrapped error `ErrFileNotFound` is returned when fig is not able to find a con
 	if errors.Is(err, fig.ErrFileNotFound) {
 	  // load config from elsewhere
 	}
+
+A value from the config file, the environment or a default that cannot be set results in a `*FieldError`, which gives the
+field's path, the raw value, the type it was parsed as and where the value came from. Its message reads like
+`port: cannot parse "eighty" as int (from env MYAPP_PORT): invalid syntax`. The values of secret fields are redacted.
+
+	var fe *fig.FieldError
+	if errors.As(err, &fe) {
+	  fmt.Println(fe.Path, fe.Value, fe.Source, fe.Env)
+	}
+
+For a value of the config file the message names the file instead, e.g. `port: cannot parse "eighty" as int (from file
+config.yaml): invalid syntax`.
+
+A panic raised while setting a field, such as by a buggy `UnmarshalString` method, is recovered and reported as an error of that
+field, e.g. `level: panic: runtime error: index out of range`, and the remaining fields are still processed.
 */
 package fig
@@ -0,0 +1,235 @@
+package fig
+
+import (
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted is the value written by `Dump` in place of fields
+// tagged as secret.
+const Redacted = "***"
+
+// Dump writes cfg to w encoded in the given format. cfg must be a
+// struct or a pointer to a struct, typically one previously filled
+// by `Load`.
+//
+// Keys are named after the field's alt name in the `fig` tag, or the
+// tag given by the `Tag` option, falling back to the field's name. The
+// values of fields that contain a secret flag in their tag are replaced
+// by `Redacted`.
+//
+//	type Config struct {
+//	  Host     string `fig:"host"`
+//	  Password string `fig:"password,secret"`
+//	}
+//
+//	fig.Dump(cfg, os.Stdout, fig.DecoderYaml)
+//
+// Nil pointers, interfaces, slices and maps are omitted. Options that affect
+// how fields are named, namely `Tag`, are honored so that the output can be
+// loaded back with the same options:
+//
+//	fig.Dump(cfg, os.Stdout, fig.DecoderYaml, fig.Tag("yaml"))
+func Dump(cfg interface{}, w io.Writer, format Decoder, options ...Option) error {
+	f := defaultFig()
+	for _, opt := range options {
+		opt(f)
+	}
+
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cfg must be a struct or a pointer to a struct, got %T", cfg)
+	}
+
+	vals := dumpValue(v, f.tag).(map[string]interface{})
+
+	switch format {
+	case DecoderYaml:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(vals); err != nil {
+			return err
+		}
+		return enc.Close()
+	case DecoderJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(vals)
+	case DecoderToml:
+		return toml.NewEncoder(w).Encode(vals)
+	default:
+		return fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+var (
+	timeType           = reflect.TypeOf(time.Time{})
+	durationType       = reflect.TypeOf(time.Duration(0))
+	regexpType         = reflect.TypeOf(&regexp.Regexp{})
+	textMarshalerIface = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// dumpValue converts v into a value made up of maps, slices and
+// scalars that any of the supported encoders can marshal. It returns
+// nil if v is a nil pointer, interface, slice or map. tag is the key
+// of the struct tag that contains the alt names of fields.
+func dumpValue(v reflect.Value, tag string) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	switch v.Type() {
+	case timeType:
+		return v.Interface()
+	case durationType:
+		return v.Interface().(time.Duration).String()
+	case regexpType:
+		return v.Interface().(*regexp.Regexp).String()
+	case regexpType.Elem():
+		return addressable(v).Interface().(*regexp.Regexp).String()
+	case rawMessageType:
+		var raw interface{}
+		if err := json.Unmarshal(v.Bytes(), &raw); err != nil {
+			return string(v.Bytes())
+		}
+		return raw
+	case weekdayType, monthType:
+		return v.Interface().(fmt.Stringer).String()
+	}
+
+	if v.Type().Implements(textMarshalerIface) {
+		if text, err := v.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	} else if v.Kind() != reflect.Ptr && reflect.PointerTo(v.Type()).Implements(textMarshalerIface) {
+		// types such as big.Rat implement the interface on their pointer
+		if text, err := addressable(v).Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return dumpValue(v.Elem(), tag)
+
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		dumpStruct(v, m, tag)
+		return m
+
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s = append(s, dumpValue(v.Index(i), tag))
+		}
+		return s
+
+	case reflect.Map:
+		if isSetType(v.Type()) {
+			// sets are written as the sorted list of their keys
+			keys := make([]interface{}, 0, v.Len())
+			for _, k := range v.MapKeys() {
+				keys = append(keys, dumpValue(k, tag))
+			}
+			sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+			return keys
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if val := dumpValue(iter.Value(), tag); val != nil {
+				m[fmt.Sprint(iter.Key().Interface())] = val
+			}
+		}
+		return m
+
+	default:
+		return v.Interface()
+	}
+}
+
+// addressable returns a pointer to v, or to a copy of v if v
+// is not addressable.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p
+}
+
+// dumpStruct fills m with the fields of the struct v, keyed by
+// their alt name or field name.
+func dumpStruct(v reflect.Value, m map[string]interface{}, tag string) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		st := parseTag(sf.Tag, tag)
+
+		fv := v.Field(i)
+		if st.squash {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				dumpStruct(fv, m, tag)
+			}
+			continue
+		}
+
+		key := sf.Name
+		if st.altName != "" {
+			key = st.altName
+		}
+
+		if st.secret {
+			m[key] = Redacted
+			continue
+		}
+
+		if st.hex && isByteSeqType(fv.Type()) && (fv.Kind() == reflect.Array || !fv.IsNil()) {
+			b := make([]byte, fv.Len())
+			reflect.Copy(reflect.ValueOf(b), fv)
+			m[key] = hex.EncodeToString(b)
+			continue
+		}
+
+		// the entries of a remain map are keys of the struct itself
+		if st.remain && fv.Kind() == reflect.Map {
+			if rest, ok := dumpValue(fv, tag).(map[string]interface{}); ok {
+				for k, v := range rest {
+					if _, ok := m[k]; !ok {
+						m[k] = v
+					}
+				}
+			}
+			continue
+		}
+
+		if val := dumpValue(fv, tag); val != nil {
+			m[key] = val
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package fig
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDump(t *testing.T) {
+	type Base struct {
+		Env string `fig:"env"`
+	}
+
+	type DB struct {
+		Host     string `fig:"host"`
+		Password string `fig:"password,secret"`
+	}
+
+	type Config struct {
+		Base    `fig:",squash"`
+		Name    string
+		Port    int               `fig:"port"`
+		Timeout time.Duration     `fig:"timeout"`
+		Pattern *regexp.Regexp    `fig:"pattern"`
+		DB      DB                `fig:"db"`
+		Replica *DB               `fig:"replica"`
+		Tags    []string          `fig:"tags"`
+		Labels  map[string]string `fig:"labels"`
+		Token   string            `fig:",secret"`
+		Day     time.Weekday      `fig:"day"`
+		hidden  string
+	}
+
+	cfg := Config{
+		Base:    Base{Env: "prod"},
+		Name:    "app",
+		Port:    8080,
+		Timeout: 5 * time.Second,
+		Pattern: regexp.MustCompile("^a+$"),
+		DB:      DB{Host: "db.internal", Password: "hunter2"},
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"team": "infra"},
+		Token:   "abc",
+		Day:     time.Friday,
+		hidden:  "x",
+	}
+
+	want := map[string]interface{}{
+		"env":     "prod",
+		"Name":    "app",
+		"port":    float64(8080),
+		"timeout": "5s",
+		"pattern": "^a+$",
+		"db":      map[string]interface{}{"host": "db.internal", "password": Redacted},
+		"tags":    []interface{}{"a", "b"},
+		"labels":  map[string]interface{}{"team": "infra"},
+		"Token":   Redacted,
+		"day":     "Friday",
+	}
+
+	for _, tc := range []struct {
+		format    Decoder
+		unmarshal func([]byte, interface{}) error
+	}{
+		{format: DecoderYaml, unmarshal: yaml.Unmarshal},
+		{format: DecoderJSON, unmarshal: json.Unmarshal},
+		{format: DecoderToml, unmarshal: toml.Unmarshal},
+	} {
+		t.Run(string(tc.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Dump(&cfg, &buf, tc.format); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if strings.Contains(buf.String(), "hunter2") {
+				t.Fatalf("secret was not redacted:\n%s", buf.String())
+			}
+
+			got := make(map[string]interface{})
+			if err := tc.unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("unable to unmarshal dump: %v", err)
+			}
+			normalizeNumbers(got)
+
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("\nwant %+v\ngot  %+v", want, got)
+			}
+		})
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		if err := Dump(cfg, &bytes.Buffer{}, Decoder(".ini")); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("remain", func(t *testing.T) {
+		var cfg struct {
+			Name string                 `fig:"name"`
+			Rest map[string]interface{} `fig:",remain"`
+		}
+		cfg.Name = "app"
+		cfg.Rest = map[string]interface{}{"plugin": "x", "name": "shadowed"}
+
+		var buf bytes.Buffer
+		if err := Dump(&cfg, &buf, DecoderJSON); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		got := make(map[string]interface{})
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("unable to unmarshal dump: %v", err)
+		}
+		if want := map[string]interface{}{"name": "app", "plugin": "x"}; !reflect.DeepEqual(want, got) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, got)
+		}
+	})
+
+	t.Run("tag option", func(t *testing.T) {
+		type Config struct {
+			Host   string `yaml:"host_name"`
+			Secret string `yaml:"secret,secret"`
+		}
+		cfg := Config{Host: "example.com", Secret: "hunter2"}
+
+		var buf bytes.Buffer
+		if err := Dump(&cfg, &buf, DecoderYaml, Tag("yaml")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := "host_name: example.com\nsecret: '***'\n"; buf.String() != want {
+			t.Errorf("\nwant %q\ngot  %q", want, buf.String())
+		}
+
+		var loaded Config
+		if err := Load(&loaded, Reader(&buf, DecoderYaml), Tag("yaml")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if loaded.Host != cfg.Host {
+			t.Errorf("want host %q loaded back, got %q", cfg.Host, loaded.Host)
+		}
+	})
+
+	t.Run("value types", func(t *testing.T) {
+		type Config struct {
+			Pattern regexp.Regexp   `fig:"pattern"`
+			Ratio   big.Rat         `fig:"ratio"`
+			Raw     json.RawMessage `fig:"raw"`
+		}
+		cfg := Config{
+			Pattern: *regexp.MustCompile("^a+$"),
+			Ratio:   *big.NewRat(1, 3),
+			Raw:     json.RawMessage(`{"a":[1,2]}`),
+		}
+
+		for name, v := range map[string]interface{}{"value": cfg, "pointer": &cfg} {
+			t.Run(name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := Dump(v, &buf, DecoderJSON); err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+
+				got := make(map[string]interface{})
+				if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+					t.Fatalf("unable to unmarshal dump: %v", err)
+				}
+				want := map[string]interface{}{
+					"pattern": "^a+$",
+					"ratio":   "1/3",
+					"raw":     map[string]interface{}{"a": []interface{}{float64(1), float64(2)}},
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("\nwant %+v\ngot  %+v", want, got)
+				}
+			})
+		}
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		if err := Dump(map[string]int{}, &bytes.Buffer{}, DecoderJSON); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+// normalizeNumbers converts the integers in m to float64 so that values
+// decoded by different decoders can be compared.
+func normalizeNumbers(m map[string]interface{}) {
+	for k, v := range m {
+		switch val := v.(type) {
+		case int:
+			m[k] = float64(val)
+		case int64:
+			m[k] = float64(val)
+		case map[string]interface{}:
+			normalizeNumbers(val)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package fig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readEnvFile reads the variables of the env file, if one is set and
+// the environment is used either to set fields or to expand defaults,
+// so that they can be looked up by getenv.
+func (f *fig) readEnvFile() error {
+	if f.envFile == "" || (!f.useEnv && !f.expandDefaults) {
+		return nil
+	}
+
+	file, err := os.Open(f.envFile)
+	if err != nil {
+		return fmt.Errorf("env file: %w", err)
+	}
+	defer file.Close()
+
+	vars, err := parseEnvFile(file)
+	if err != nil {
+		return fmt.Errorf("env file %s: %w", f.envFile, err)
+	}
+	f.fileEnv = vars
+	f.logf("loaded %d variables from env file %s", len(vars), f.envFile)
+
+	return nil
+}
+
+// getenv retrieves the value of the environment variable name. Variables
+// of the env file are used for names that are not set in the environment.
+func (f *fig) getenv(name string) (string, bool) {
+	if val, ok := os.LookupEnv(name); ok {
+		return val, true
+	}
+	val, ok := f.fileEnv[name]
+	return val, ok
+}
+
+// environ is like os.Environ but also contains the variables of the env
+// file whose names are not set in the environment.
+func (f *fig) environ() []string {
+	env := os.Environ()
+	for name, val := range f.fileEnv {
+		if _, ok := os.LookupEnv(name); !ok {
+			env = append(env, name+"="+val)
+		}
+	}
+	return env
+}
+
+// parseEnvFile parses the KEY=VALUE lines of a .env file read from r.
+// Blank lines and lines starting with # are skipped, and a line may be
+// prefixed with export. A value may be enclosed in double quotes, in
+// which case escape sequences such as \n are interpreted, or in single
+// quotes, in which case it's taken literally. An unquoted value ends at
+// a # preceded by a space and has surrounding spaces removed.
+//
+//	# database
+//	DB_HOST=localhost
+//	export DB_USER=app
+//	DB_PASSWORD="p#ss word"
+//	GREETING='hello\nworld' # not a newline
+func parseEnvFile(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, val, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: want KEY=VALUE, got %q", n, line)
+		}
+
+		val, err := parseEnvFileValue(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, name, err)
+		}
+		vars[name] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+// parseEnvFileValue parses the value of a line of a .env file, see
+// parseEnvFile.
+func parseEnvFileValue(val string) (string, error) {
+	if val == "" {
+		return "", nil
+	}
+
+	switch quote := val[0]; quote {
+	case '"', '\'':
+		end := -1
+		for i := 1; i < len(val) && end < 0; i++ {
+			switch {
+			case quote == '"' && val[i] == '\\':
+				i++ // an escaped character never ends the value
+			case val[i] == quote:
+				end = i
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", val)
+		}
+		if rest := strings.TrimSpace(val[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		if quote == '\'' {
+			return val[1:end], nil
+		}
+		return strconv.Unquote(val[:end+1])
+	}
+
+	if i := strings.Index(val, " #"); i >= 0 {
+		val = strings.TrimSpace(val[:i])
+	}
+	return val, nil
+}
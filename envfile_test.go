@@ -0,0 +1,121 @@
+package fig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseEnvFile(t *testing.T) {
+	data := `# database
+DB_HOST=localhost
+export DB_USER = app
+
+DB_PASSWORD="p#ss \"word\""
+GREETING='hello\nworld' # literal
+MULTI="a\nb"
+PORT=5432 # default port
+EMPTY=
+URL=http://x/#anchor
+`
+	got, err := parseEnvFile(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := map[string]string{
+		"DB_HOST":     "localhost",
+		"DB_USER":     "app",
+		"DB_PASSWORD": `p#ss "word"`,
+		"GREETING":    `hello\nworld`,
+		"MULTI":       "a\nb",
+		"PORT":        "5432",
+		"EMPTY":       "",
+		"URL":         "http://x/#anchor",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("\nwant %q\ngot  %q", want, got)
+	}
+
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{data: "NOVALUE\n", want: `line 1: want KEY=VALUE, got "NOVALUE"`},
+		{data: "A=1\n=2\n", want: `line 2: want KEY=VALUE, got "=2"`},
+		{data: "A=\"open\n", want: `line 1: A: unterminated quoted value "open`},
+		{data: "A='a' b\n", want: `line 1: A: unexpected "b" after quoted value`},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			_, err := parseEnvFile(strings.NewReader(tc.data))
+			if err == nil || err.Error() != tc.want {
+				t.Fatalf("want err %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func Test_fig_Load_EnvFile(t *testing.T) {
+	type Config struct {
+		Host   string         `fig:"host"`
+		Port   int            `fig:"port"`
+		Level  string         `fig:"level"`
+		Quotas map[string]int `fig:"quotas"`
+	}
+
+	path := filepath.Join(t.TempDir(), ".env.local")
+	data := "MYAPP_HOST=localhost\nMYAPP_PORT=8080\nAPP_LEVEL=debug\nMYAPP_QUOTAS_FREE=10\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("env wins over file", func(t *testing.T) {
+		setenv(t, "MYAPP_PORT", "9090")
+		setenv(t, "MYAPP_QUOTAS_PRO", "100")
+
+		var cfg Config
+		err := Load(&cfg, IgnoreFile(), EnvPrefixes("myapp", "app"), EnvFile(path))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		want := Config{
+			Host:   "localhost",
+			Port:   9090,
+			Level:  "debug",
+			Quotas: map[string]int{"free": 10, "pro": 100},
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+		}
+	})
+
+	t.Run("expanded in defaults", func(t *testing.T) {
+		var cfg struct {
+			URL string `fig:"url" default:"http://${MYAPP_HOST}:${MYAPP_PORT}"`
+		}
+		if err := Load(&cfg, IgnoreFile(), ExpandEnvDefaults(), EnvFile(path)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := "http://localhost:8080"; cfg.URL != want {
+			t.Errorf("want url %q, got %q", want, cfg.URL)
+		}
+	})
+
+	t.Run("ignored without env", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), EnvFile(filepath.Join(t.TempDir(), "missing"))); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, IgnoreFile(), UseEnv("myapp"), EnvFile(filepath.Join(t.TempDir(), "missing")))
+		if err == nil || !strings.HasPrefix(err.Error(), "env file: ") {
+			t.Fatalf("want env file err, got %v", err)
+		}
+	})
+}
@@ -2,14 +2,157 @@ package fig
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/mitchellh/mapstructure"
 )
 
 // ErrFileNotFound is returned as a wrapped error by `Load` when the config file is
 // not found in the given search dirs.
 var ErrFileNotFound = fmt.Errorf("file not found")
 
+// ErrSubtreeNotFound is returned as a wrapped error by `Load` when the subtree
+// given by the `Subtree` option does not exist in the config file.
+var ErrSubtreeNotFound = fmt.Errorf("subtree not found")
+
+// ErrUnknownKeys is returned by `Load` when strict parsing is enabled and the
+// config file contains keys that do not correspond to any field in the config
+// struct.
+//
+//	var unknown *fig.ErrUnknownKeys
+//	if errors.As(err, &unknown) {
+//	  fmt.Println(unknown.Keys)
+//	}
+type ErrUnknownKeys struct {
+	// Keys contains the sorted paths of the unknown keys.
+	Keys []string
+}
+
+// Error formats the unknown keys into a single string.
+func (e *ErrUnknownKeys) Error() string {
+	return "unknown keys: " + strings.Join(e.Keys, ", ")
+}
+
+// The sources of the values of a `FieldError`.
+const (
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceDefault = "default"
+)
+
+// FieldError is the error of a field whose value from the config file or the
+// environment, or whose default, could not be set. `Load` returns it wrapped in an error that
+// collects the errors of all fields, from which it can be retrieved with
+// errors.As:
+//
+//	var fe *fig.FieldError
+//	if errors.As(err, &fe) {
+//	  fmt.Println(fe.Path, fe.Value, fe.Source)
+//	}
+//
+// The value of a field that contains a secret flag in its tag is replaced by
+// `Redacted`, and the underlying error is left out of the message as it may
+// contain the value.
+type FieldError struct {
+	// Path is the path of the field.
+	Path string
+	// Value is the raw value that could not be set.
+	Value string
+	// Type is the type that Value was parsed as.
+	Type reflect.Type
+	// Source is where Value came from, one of SourceFile, SourceEnv or
+	// SourceDefault.
+	Source string
+	// File is the path of the config file that Value came from, if Source
+	// is SourceFile and the config was read from a named file or url.
+	File string
+	// Env is the name of the environment variable that Value came from, if
+	// Source is SourceEnv.
+	Env string
+	// Err is the underlying error. If Source is SourceFile then it unwraps
+	// to the *mapstructure.Error that reported it.
+	Err error
+}
+
+// Error formats the value, type and source of the field, followed by the
+// underlying error, e.g. `cannot parse "eighty" as int (from env MYAPP_PORT):
+// invalid syntax`. The path of the field is left out as the error is keyed
+// by it when returned by `Load`.
+func (e *FieldError) Error() string {
+	from := e.Source
+	if e.Env != "" {
+		from += " " + e.Env
+	}
+	if e.File != "" {
+		from += " " + e.File
+	}
+	if e.Value == Redacted {
+		return fmt.Sprintf("cannot parse %s as %s (from %s)", Redacted, e.Type, from)
+	}
+
+	// the error of strconv repeats the value, which is already given
+	detail := e.Err.Error()
+	if ne, ok := e.Err.(*strconv.NumError); ok {
+		detail = ne.Err.Error()
+	}
+	return fmt.Sprintf("cannot parse %q as %s (from %s): %s", e.Value, e.Type, from, detail)
+}
+
+// Unwrap returns the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// decodeErrorPatterns match the messages of the mapstructure errors about a
+// single field, capturing the field's path followed by the error's detail.
+var decodeErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^error decoding '([^']*)': (.*)$`),
+	regexp.MustCompile(`^cannot parse '([^']*)' as \w+: (.*)$`),
+	regexp.MustCompile(`^cannot parse '([^']*)', (.*)$`),
+	regexp.MustCompile(`^'([^']*)' (.*)$`),
+}
+
+// strconvErrorRegexp matches the message of a *strconv.NumError, capturing
+// its detail without the value that it repeats.
+var strconvErrorRegexp = regexp.MustCompile(`^strconv\.\w+: parsing ".*": (.*)$`)
+
+// parseDecodeError splits msg, the message of a mapstructure error about a
+// single field, into the path of the field and the error's detail. ok is false
+// if msg is not of a known form.
+func parseDecodeError(msg string) (path, detail string, ok bool) {
+	for _, re := range decodeErrorPatterns {
+		if m := re.FindStringSubmatch(msg); m != nil && m[1] != "" {
+			detail = m[2]
+			if sm := strconvErrorRegexp.FindStringSubmatch(detail); sm != nil {
+				detail = sm[1]
+			}
+			return m[1], detail, true
+		}
+	}
+	return "", "", false
+}
+
+// decodeError is the detail of an error that mapstructure reported about a
+// single field. It unwraps to the *mapstructure.Error that contains it so
+// that the original error remains available to errors.As.
+type decodeError struct {
+	detail string
+	err    *mapstructure.Error
+}
+
+func (e *decodeError) Error() string {
+	return e.detail
+}
+
+// Unwrap returns the mapstructure error that contains e.
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
 // fieldErrors collects errors for fields of config struct.
 type fieldErrors map[string]error
 
@@ -33,3 +176,19 @@ func (fe fieldErrors) Error() string {
 
 	return strings.TrimSuffix(sb.String(), ", ")
 }
+
+// Unwrap returns the errors of all fields, ordered by field path, so that
+// errors.Is and errors.As see through fieldErrors.
+func (fe fieldErrors) Unwrap() []error {
+	keys := make([]string, 0, len(fe))
+	for key := range fe {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	errs := make([]error, 0, len(keys))
+	for _, key := range keys {
+		errs = append(errs, fe[key])
+	}
+	return errs
+}
@@ -1,8 +1,14 @@
 package fig
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
 )
 
 func Test_fieldErrors_Error(t *testing.T) {
@@ -24,3 +30,110 @@ func Test_fieldErrors_Error(t *testing.T) {
 		t.Fatalf("empty errors returned non-empty string: %s", got)
 	}
 }
+
+func Test_ErrUnknownKeys_Error(t *testing.T) {
+	err := &ErrUnknownKeys{Keys: []string{"logger", "server.tls"}}
+
+	if want := "unknown keys: logger, server.tls"; want != err.Error() {
+		t.Fatalf("want %q, got %q", want, err.Error())
+	}
+}
+
+func Test_fieldErrors_Unwrap(t *testing.T) {
+	fe := fieldErrors{
+		"b": &FieldError{Path: "b", Value: "x", Type: reflect.TypeOf(0), Source: SourceDefault, Err: fmt.Errorf("berr")},
+		"a": ErrFileNotFound,
+	}
+
+	if !errors.Is(fe, ErrFileNotFound) {
+		t.Errorf("errors.Is did not find ErrFileNotFound")
+	}
+	var target *FieldError
+	if !errors.As(fe, &target) || target.Path != "b" {
+		t.Errorf("errors.As did not find the field error, got %v", target)
+	}
+}
+
+func Test_FieldError_Error(t *testing.T) {
+	_, numErr := strconv.Atoi("eighty")
+
+	for _, tc := range []struct {
+		err  *FieldError
+		want string
+	}{
+		{
+			err:  &FieldError{Path: "port", Value: "eighty", Type: reflect.TypeOf(0), Source: SourceEnv, Env: "MYAPP_PORT", Err: numErr},
+			want: `cannot parse "eighty" as int (from env MYAPP_PORT): invalid syntax`,
+		},
+		{
+			err:  &FieldError{Path: "timeout", Value: "5x", Type: reflect.TypeOf(time.Duration(0)), Source: SourceDefault, Err: fmt.Errorf("bad unit")},
+			want: `cannot parse "5x" as time.Duration (from default): bad unit`,
+		},
+		{
+			err:  &FieldError{Path: "pin", Value: Redacted, Type: reflect.TypeOf(0), Source: SourceEnv, Env: "PIN", Err: numErr},
+			want: `cannot parse *** as int (from env PIN)`,
+		},
+	} {
+		if got := tc.err.Error(); got != tc.want {
+			t.Errorf("want %q, got %q", tc.want, got)
+		}
+		if !errors.Is(tc.err, tc.err.Err) {
+			t.Errorf("%v does not unwrap to %v", tc.err, tc.err.Err)
+		}
+	}
+}
+
+// Test_parseDecodeError pins the messages of the errors that mapstructure
+// reports about single fields, which parseDecodeError relies on.
+func Test_parseDecodeError(t *testing.T) {
+	var cfg struct {
+		Timeout time.Duration `fig:"timeout"`
+		Port    int           `fig:"port"`
+		Enabled bool          `fig:"enabled"`
+		Servers []struct {
+			Port int `fig:"port"`
+		} `fig:"servers"`
+	}
+	m := map[string]interface{}{
+		"timeout": "5x",
+		"port":    "eighty",
+		"enabled": []interface{}{1},
+		"servers": []interface{}{map[string]interface{}{"port": "two"}},
+	}
+
+	_, err := defaultFig().decode(m, &cfg)
+	var me *mapstructure.Error
+	if !errors.As(err, &me) {
+		t.Fatalf("want a *mapstructure.Error, got %v", err)
+	}
+	errs := me.Errors
+
+	want := map[string]string{
+		"timeout":         `time: unknown unit "x" in duration "5x"`,
+		"port":            "invalid syntax",
+		"enabled":         "expected type 'bool', got unconvertible type '[]interface {}', value: '[1]'",
+		"servers[0].port": "invalid syntax",
+	}
+	if len(me.Errors) != len(want) {
+		t.Fatalf("want %d errors, got %q", len(want), me.Errors)
+	}
+
+	// negative numbers only overflow unsigned fields without weak typing
+	var unsigned struct{ Count uint }
+	err = mapstructure.Decode(map[string]interface{}{"count": -1}, &unsigned)
+	if !errors.As(err, &me) {
+		t.Fatalf("want a *mapstructure.Error, got %v", err)
+	}
+	want["Count"] = "-1 overflows uint"
+
+	for _, msg := range append(errs, me.Errors...) {
+		path, detail, ok := parseDecodeError(msg)
+		if !ok {
+			t.Errorf("unable to parse %q", msg)
+			continue
+		}
+		if detail != want[path] {
+			t.Errorf("%s: want detail %q, got %q from %q", path, want[path], detail, msg)
+		}
+	}
+}
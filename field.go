@@ -139,13 +139,23 @@ func (f *field) name() string {
 // path is a dot separated path consisting of all the names of
 // the field's ancestors starting from the topmost parent all the
 // way down to the field itself.
-func (f *field) path() (path string) {
+func (f *field) path() string {
+	return f.pathFunc((*field).name)
+}
+
+// pathFunc is like path but uses name to get the names of the
+// field and its ancestors.
+func (f *field) pathFunc(name func(*field) string) (path string) {
 	var visit func(f *field)
 	visit = func(f *field) {
 		if f.parent != nil {
 			visit(f.parent)
 		}
-		path += f.name()
+		// the fields of a squashed struct are treated as fields of its parent
+		if f.squash {
+			return
+		}
+		path += name(f)
 		// if it's a slice/array we don't want a dot before the slice indexer
 		// e.g. we want A[0].B instead of A.[0].B
 		if f.t.Kind() != reflect.Slice && f.t.Kind() != reflect.Array && f.t.Kind() != reflect.Map {
@@ -156,27 +166,225 @@ func (f *field) path() (path string) {
 	return strings.Trim(path, ".")
 }
 
+// envExcluded reports whether the field, or any of its ancestors,
+// is excluded from being set by the environment. Remain fields, which
+// hold the keys of the config file that no other field matches, are
+// always excluded.
+func (f *field) envExcluded() bool {
+	for ; f != nil; f = f.parent {
+		if f.noEnv || f.remain {
+			return true
+		}
+	}
+	return false
+}
+
 // parseTag parses a fields struct tags into a more easy to use structTag.
 // key is the key of the struct tag which contains the field's alt name.
-func parseTag(tag reflect.StructTag, key string) (st structTag) {
+// Malformed parts of the tags are ignored, see parseTagStrict.
+func parseTag(tag reflect.StructTag, key string) structTag {
+	st, _ := parseTagStrict(tag, key)
+	return st
+}
+
+// parseTagStrict is like parseTag but additionally returns an error
+// describing the first malformed part of the tags, such as an unknown
+// validation. The returned structTag is filled regardless.
+func parseTagStrict(tag reflect.StructTag, key string) (st structTag, err error) {
+	fail := func(format string, a ...interface{}) {
+		if err == nil {
+			err = fmt.Errorf(format, a...)
+		}
+	}
+
 	if val, ok := tag.Lookup(key); ok {
-		i := strings.Index(val, ",")
-		if i == -1 {
-			i = len(val)
+		name, flags, _ := strings.Cut(val, ",")
+		st.altName = name
+		// a default flag takes the rest of the tag as a default can contain commas
+		flags, defaultVal, hasDefault := cutFlag(flags, "default=")
+		if hasDefault {
+			st.setDefault = true
+			st.defaultVal = defaultVal
+		}
+		for _, flag := range strings.Split(flags, ",") {
+			switch flag {
+			case "required":
+				st.required = true
+			case "squash":
+				st.squash = true
+			case "secret":
+				st.secret = true
+			case "bytes":
+				st.bytes = true
+			case "hex":
+				st.hex = true
+			case "noenv":
+				st.noEnv = true
+			case "remain":
+				st.remain = true
+			case "relpath":
+				st.relPath = true
+			case "", "omitempty":
+			default:
+				fail("unknown flag %q in %s tag", flag, key)
+			}
 		}
-		st.altName = val[:i]
 	}
 
-	if val := tag.Get("validate"); val == "required" {
-		st.required = true
+	if val, ok := tag.Lookup("validate"); ok {
+		for _, rule := range strings.Split(val, ",") {
+			name, arg, hasArg := strings.Cut(rule, "=")
+			if format, ok := strings.CutPrefix(name, "keys:"); ok {
+				if _, ok := formatValidators[format]; !ok || hasArg {
+					fail("unknown key validation %q in validate tag", rule)
+					continue
+				}
+				st.keyFormats = append(st.keyFormats, format)
+				continue
+			}
+			switch name {
+			case "required":
+				st.required = true
+			case "required_with":
+				st.requiredWith = arg
+			case "required_without":
+				st.requiredWithout = arg
+			case "required_group":
+				st.requiredGroup = arg
+			case "required_nonempty":
+				st.required = true
+				st.requiredNonEmpty = true
+			case "contains":
+				st.contains = append(st.contains, arg)
+			case "excludes":
+				st.excludes = append(st.excludes, arg)
+			case "unique":
+				st.unique = true
+				st.uniqueKey = arg
+			default:
+				if _, ok := formatValidators[name]; ok {
+					st.formats = append(st.formats, name)
+				} else {
+					fail("unknown validation %q in validate tag", name)
+					continue
+				}
+			}
+			switch name {
+			case "required_with", "required_without":
+				if arg == "" {
+					fail("validation %q in validate tag requires a field name", name)
+				}
+			case "required_group":
+				if arg == "" {
+					fail("validation %q in validate tag requires a group name", name)
+				}
+			case "contains", "excludes":
+				if arg == "" {
+					fail("validation %q in validate tag requires a substring", name)
+				}
+			case "unique":
+				if hasArg && arg == "" {
+					fail("validation %q in validate tag requires a field name", name)
+				}
+			default:
+				if hasArg {
+					fail("validation %q in validate tag does not take an argument", name)
+				}
+			}
+		}
 	}
 
 	if val, ok := tag.Lookup("default"); ok {
+		if st.setDefault {
+			fail("default given in both %s and default tags", key)
+		}
 		st.setDefault = true
 		st.defaultVal = val
 	}
 
-	return
+	// env tags of other libraries, such as env:"PORT", are left alone
+	if val, ok := tag.Lookup("env"); ok {
+		if val == "-" {
+			st.noEnv = true
+		} else {
+			fail("env tag must be \"-\", got %q", val)
+		}
+	}
+
+	if val, ok := tag.Lookup("transform"); ok {
+		for _, name := range strings.Split(val, ",") {
+			if _, ok := transforms[name]; !ok {
+				fail("unknown transform %q in transform tag", name)
+			}
+			// unknown transforms are kept so that processing the field fails
+			st.transforms = append(st.transforms, name)
+		}
+	}
+
+	st.timeLayout = tag.Get("timelayout")
+	st.description = tag.Get("desc")
+
+	return st, err
+}
+
+// cutFlag slices flags around the first flag that starts with prefix,
+// returning the flags before it and the remainder of flags after the
+// prefix. If no flag starts with prefix then cutFlag returns flags,
+// "", false.
+func cutFlag(flags, prefix string) (before, value string, found bool) {
+	if strings.HasPrefix(flags, prefix) {
+		return "", flags[len(prefix):], true
+	}
+	if i := strings.Index(flags, ","+prefix); i >= 0 {
+		return flags[:i], flags[i+1+len(prefix):], true
+	}
+	return flags, "", false
+}
+
+// checkTags checks the tags of every field of the struct type t and of
+// any struct types it contains, returning a fieldErrors that contains
+// an error for each field with a malformed tag. key is the key of the
+// struct tag which contains the field's alt name.
+func checkTags(t reflect.Type, key string) error {
+	errs := make(fieldErrors)
+	checkTypeTags(t, key, "", errs, make(map[reflect.Type]bool))
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkTypeTags(t reflect.Type, key, path string, errs fieldErrors, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		st, err := parseTagStrict(sf.Tag, key)
+
+		fieldPath := path
+		if !st.squash {
+			name := sf.Name
+			if st.altName != "" {
+				name = st.altName
+			}
+			fieldPath = strings.TrimPrefix(path+"."+name, ".")
+		}
+
+		if err != nil {
+			errs[fieldPath] = err
+		}
+		checkTypeTags(sf.Type, key, fieldPath, errs, seen)
+	}
 }
 
 // structTag contains information gathered from parsing a field's tags.
@@ -185,4 +393,31 @@ type structTag struct {
 	required   bool   // true if the tag contained a required validation key.
 	setDefault bool   // true if tag contained a default key.
 	defaultVal string // the value of the default key.
+	timeLayout string // the value of the timelayout key, overrides the global layout.
+	squash     bool   // true if the tag contained a squash flag.
+	secret     bool   // true if the tag contained a secret flag.
+	bytes      bool   // true if the tag contained a bytes flag.
+	hex        bool   // true if the tag contained a hex flag.
+	noEnv      bool   // true if the tag contained a noenv flag or an env:"-" tag.
+	remain     bool   // true if the tag contained a remain flag.
+	relPath    bool   // true if the tag contained a relpath flag.
+
+	description string // the value of the desc key, unused by fig itself.
+
+	requiredWith    string // name of a sibling field which, if set, makes this field required.
+	requiredWithout string // name of a sibling field which, if not set, makes this field required.
+	requiredGroup   string // name of a group of sibling fields of which exactly one must be set.
+
+	requiredNonEmpty bool // true if the tag contained a required_nonempty validation, which implies required.
+
+	formats  []string // names of the format validations in the tag, e.g. email.
+	contains []string // substrings which the field's value must contain.
+	excludes []string // substrings which the field's value must not contain.
+
+	unique    bool   // true if the tag contained a unique validation.
+	uniqueKey string // name of the field of the elements which must be unique, the elements themselves if empty.
+
+	transforms []string // names of the transforms in the transform tag, applied in order.
+
+	keyFormats []string // names of the format validations that the keys of a map must pass, e.g. hostname.
 }
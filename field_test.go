@@ -65,6 +65,25 @@ func Test_flattenCfg_Map(t *testing.T) {
 	checkField(t, fields[2], "b", "D[key1].b")
 }
 
+func Test_flattenCfg_Squash(t *testing.T) {
+	type Base struct {
+		A string `fig:"a"`
+	}
+	cfg := struct {
+		Base `fig:",squash"`
+		B    struct {
+			Base `fig:",squash"`
+		} `fig:"b"`
+	}{}
+
+	fields := flattenCfg(&cfg, "fig")
+	if len(fields) != 5 {
+		t.Fatalf("len(fields) == %d, expected %d", len(fields), 5)
+	}
+	checkField(t, fields[1], "a", "a")
+	checkField(t, fields[4], "a", "b.a")
+}
+
 func Test_newStructField(t *testing.T) {
 	cfg := struct {
 		A int `fig:"a" default:"5" validate:"required"`
@@ -176,6 +195,98 @@ func Test_parseTag(t *testing.T) {
 			tagVal: `fig:"c,omitempty"`,
 			want:   structTag{altName: "c"},
 		},
+		{
+			tagVal: `fig:",squash"`,
+			want:   structTag{squash: true},
+		},
+		{
+			tagVal: `fig:"password,secret"`,
+			want:   structTag{altName: "password", secret: true},
+		},
+		{
+			tagVal: `fig:"max_size,bytes" default:"1MiB"`,
+			want:   structTag{altName: "max_size", bytes: true, setDefault: true, defaultVal: "1MiB"},
+		},
+		{
+			tagVal: `fig:"e" validate:"required_with=f"`,
+			want:   structTag{altName: "e", requiredWith: "f"},
+		},
+		{
+			tagVal: `validate:"required_without=Phone"`,
+			want:   structTag{requiredWithout: "Phone"},
+		},
+		{
+			tagVal: `validate:"required_nonempty"`,
+			want:   structTag{required: true, requiredNonEmpty: true},
+		},
+		{
+			tagVal: `fig:"cert_file,relpath"`,
+			want:   structTag{altName: "cert_file", relPath: true},
+		},
+		{
+			tagVal: `fig:"token" validate:"required_group=auth"`,
+			want:   structTag{altName: "token", requiredGroup: "auth"},
+		},
+		{
+			tagVal: `fig:"containers" validate:"unique=name"`,
+			want:   structTag{altName: "containers", unique: true, uniqueKey: "name"},
+		},
+		{
+			tagVal: `validate:"unique"`,
+			want:   structTag{unique: true},
+		},
+		{
+			tagVal: `fig:"endpoints" validate:"keys:hostname,required"`,
+			want:   structTag{altName: "endpoints", required: true, keyFormats: []string{"hostname"}},
+		},
+		{
+			tagVal: `fig:"host" transform:"trim,lower"`,
+			want:   structTag{altName: "host", transforms: []string{"trim", "lower"}},
+		},
+		{
+			tagVal: `fig:"admin" validate:"required,email,hostname"`,
+			want:   structTag{altName: "admin", required: true, formats: []string{"email", "hostname"}},
+		},
+		{
+			tagVal: `fig:"port,required"`,
+			want:   structTag{altName: "port", required: true},
+		},
+		{
+			tagVal: `fig:"level,default=info"`,
+			want:   structTag{altName: "level", setDefault: true, defaultVal: "info"},
+		},
+		{
+			tagVal: `fig:"tags,bytes,default=[1KB,2KB]"`,
+			want:   structTag{altName: "tags", bytes: true, setDefault: true, defaultVal: "[1KB,2KB]"},
+		},
+		{
+			tagVal: `fig:",default="`,
+			want:   structTag{setDefault: true},
+		},
+		{
+			tagVal: `validate:"contains=sslmode=,contains=host,excludes=_"`,
+			want:   structTag{contains: []string{"sslmode=", "host"}, excludes: []string{"_"}},
+		},
+		{
+			tagVal: `fig:"d" default:"2020-01-01" timelayout:"2006-01-02"`,
+			want:   structTag{altName: "d", setDefault: true, defaultVal: "2020-01-01", timeLayout: "2006-01-02"},
+		},
+		{
+			tagVal: `fig:",remain"`,
+			want:   structTag{remain: true},
+		},
+		{
+			tagVal: `fig:"password,noenv"`,
+			want:   structTag{altName: "password", noEnv: true},
+		},
+		{
+			tagVal: `fig:"password" env:"-"`,
+			want:   structTag{altName: "password", noEnv: true},
+		},
+		{
+			tagVal: `fig:"port" desc:"port to listen on, e.g. 8080"`,
+			want:   structTag{altName: "port", description: "port to listen on, e.g. 8080"},
+		},
 	} {
 		t.Run(tc.tagVal, func(t *testing.T) {
 			tag := parseTag(reflect.StructTag(tc.tagVal), "fig")
@@ -186,6 +297,92 @@ func Test_parseTag(t *testing.T) {
 	}
 }
 
+func Test_parseTagStrict(t *testing.T) {
+	for _, tagVal := range []string{
+		``,
+		`fig:"a"`,
+		`fig:"b,omitempty" validate:"required"`,
+		`fig:",squash"`,
+		`fig:"c,secret,bytes" default:"1KB"`,
+		`fig:"d" validate:"required_with=e,email"`,
+		`fig:"e,required,default=a,b"`,
+		`fig:"f,noenv"`,
+		`env:"-"`,
+	} {
+		t.Run(tagVal, func(t *testing.T) {
+			if _, err := parseTagStrict(reflect.StructTag(tagVal), "fig"); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		tagVal string
+		want   string
+	}{
+		{tagVal: `fig:"a,sqash"`, want: `unknown flag "sqash" in fig tag`},
+		{tagVal: `validate:"requird"`, want: `unknown validation "requird" in validate tag`},
+		{tagVal: `validate:"required,"`, want: `unknown validation "" in validate tag`},
+		{tagVal: `validate:"required_with"`, want: `validation "required_with" in validate tag requires a field name`},
+		{tagVal: `validate:"required_group="`, want: `validation "required_group" in validate tag requires a group name`},
+		{tagVal: `validate:"required=true"`, want: `validation "required" in validate tag does not take an argument`},
+		{tagVal: `validate:"contains"`, want: `validation "contains" in validate tag requires a substring`},
+		{tagVal: `validate:"unique="`, want: `validation "unique" in validate tag requires a field name`},
+		{tagVal: `fig:"a,default=1" default:"2"`, want: `default given in both fig and default tags`},
+		{tagVal: `env:"PASSWORD"`, want: `env tag must be "-", got "PASSWORD"`},
+		{tagVal: `validate:"keys:ipv4"`, want: `unknown key validation "keys:ipv4" in validate tag`},
+		{tagVal: `transform:"lower,snake"`, want: `unknown transform "snake" in transform tag`},
+	} {
+		t.Run(tc.tagVal, func(t *testing.T) {
+			_, err := parseTagStrict(reflect.StructTag(tc.tagVal), "fig")
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+			if err.Error() != tc.want {
+				t.Fatalf("want err %q, got %q", tc.want, err.Error())
+			}
+		})
+	}
+}
+
+func Test_checkTags(t *testing.T) {
+	type Node struct {
+		Name     string  `validate:"requird"`
+		Children []*Node `fig:"children"`
+	}
+	type Base struct {
+		Env string `fig:"env,secert"`
+	}
+	type Config struct {
+		Base  `fig:",squash"`
+		Host  string `fig:"host" validate:"required"`
+		Tree  Node   `fig:"tree"`
+		Ports map[string]struct {
+			Port int `fig:"port" validate:"required=1"`
+		} `fig:"ports"`
+	}
+
+	err := checkTags(reflect.TypeOf(Config{}), "fig")
+	if err == nil {
+		t.Fatalf("expected err")
+	}
+
+	fieldErrs := err.(fieldErrors)
+	want := []string{"env", "tree.Name", "ports.port"}
+	if len(fieldErrs) != len(want) {
+		t.Fatalf("want %d errors, got %+v", len(want), fieldErrs)
+	}
+	for _, path := range want {
+		if _, ok := fieldErrs[path]; !ok {
+			t.Errorf("want %s in fieldErrs, got %+v", path, fieldErrs)
+		}
+	}
+
+	if err := checkTags(reflect.TypeOf(Base{}), "yaml"); err != nil {
+		t.Errorf("unexpected err for other tag key: %v", err)
+	}
+}
+
 func checkField(t *testing.T, f *field, name, path string) {
 	t.Helper()
 	if f.name() != name {
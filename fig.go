@@ -1,12 +1,25 @@
 package fig
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,8 +39,39 @@ const (
 	DefaultTag = "fig"
 	// DefaultTimeLayout is the default time layout that fig uses to parse times.
 	DefaultTimeLayout = time.RFC3339
+	// DefaultHTTPTimeout is the default timeout of the HTTP client that fig uses to
+	// fetch a config file from a URL.
+	DefaultHTTPTimeout = 30 * time.Second
 )
 
+const (
+	// EnvConfigFile is the environment variable that holds the path of the config
+	// file when the `FromEnvConfig` option is used.
+	EnvConfigFile = "FIG_CONFIG_FILE"
+	// EnvStrict is the environment variable that enables strict parsing when the
+	// `FromEnvConfig` option is used.
+	EnvStrict = "FIG_STRICT"
+)
+
+// Decoder identifies one of the config file formats supported by fig.
+type Decoder string
+
+const (
+	// DecoderYaml is the yaml file format.
+	DecoderYaml Decoder = ".yaml"
+	// DecoderJSON is the json file format.
+	DecoderJSON Decoder = ".json"
+	// DecoderToml is the toml file format.
+	DecoderToml Decoder = ".toml"
+	// DecoderAuto guesses the format of a config given by `Reader` from its
+	// content, see `Reader` for how it does so.
+	DecoderAuto Decoder = "auto"
+)
+
+// sniffLen is the length of the prefix of a config that DecoderAuto
+// guesses its format from.
+const sniffLen = 4096
+
 // StringUnmarshaler is an interface designed for custom string unmarshaling.
 //
 // This interface is used when a field of a custom type needs to define its own
@@ -36,7 +80,8 @@ const (
 //
 // To use this, the custom type must implement this interface and a corresponding
 // string value should be provided in the configuration. Fig automatically detects
-// this and handles the rest.
+// this and handles the rest. An error returned by UnmarshalString fails the load,
+// unless the LenientUnmarshal option is used.
 //
 // Example usage:
 //
@@ -69,6 +114,89 @@ type StringUnmarshaler interface {
 	UnmarshalString(s string) error
 }
 
+// StringEnum is an interface for StringUnmarshaler types whose values are one of
+// a fixed set of strings, such as the ListenerType of the StringUnmarshaler
+// example. Values returns the strings that are allowed.
+//
+//	func (ListenerType) Values() []string {
+//		return []string{"unix", "tcp", "tls"}
+//	}
+//
+// Fig checks that the default of a field of such a type is one of Values, and
+// reports Values in the FieldInfo of the field.
+type StringEnum interface {
+	Values() []string
+}
+
+// MapDecoder is an interface for config types that decode the values of the
+// config file themselves, for configs whose shape is too dynamic to be described
+// by struct fields alone. Decode is called with the values read from the config
+// file in place of the decoding that fig would otherwise do.
+//
+//	func (c *Config) Decode(vals map[string]interface{}) error {
+//		for name, v := range vals {
+//			...
+//		}
+//		return nil
+//	}
+//
+// The environment, defaults and validations are applied to the decoded struct as
+// usual. As fig cannot tell which fields Decode set, fields are never considered
+// present in the config file and, in strict mode, no key is reported as unknown.
+type MapDecoder interface {
+	Decode(vals map[string]interface{}) error
+}
+
+// enumValues returns the values of t if t, or the type of the elements of
+// t if it's a pointer, slice or array, implements StringEnum.
+func enumValues(t reflect.Type) ([]string, bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if enum, ok := reflect.New(t).Interface().(StringEnum); ok {
+		return enum.Values(), true
+	}
+	return nil, false
+}
+
+// checkEnumDefault checks that each of the default values ss of a field of
+// type t is allowed, if t is a StringEnum.
+func checkEnumDefault(t reflect.Type, ss []string) error {
+	values, ok := enumValues(t)
+	if !ok {
+		return nil
+	}
+	for _, s := range ss {
+		if !slices.Contains(values, s) {
+			return fmt.Errorf("%q is not one of [%s]", s, strings.Join(values, " "))
+		}
+	}
+	return nil
+}
+
+// IsZeroer is an interface for types that define their own zero value.
+//
+// A field whose type implements this interface is considered unset by the
+// required validation, and is set to its default, when IsZero returns true.
+// This allows types such as structs, which are otherwise always considered
+// set, to be required.
+//
+//	type Money struct {
+//		Amount   int64
+//		Currency string
+//	}
+//
+//	func (m Money) IsZero() bool { return m.Currency == "" }
+//
+//	type Config struct {
+//		Budget Money `fig:"budget" validate:"required"`
+//	}
+//
+// Pointer fields are still only considered unset when they are nil.
+type IsZeroer interface {
+	IsZero() bool
+}
+
 // Load reads a configuration file and loads it into the given struct. The
 // parameter `cfg` must be a pointer to a struct.
 //
@@ -113,13 +241,96 @@ func defaultFig() *fig {
 
 type fig struct {
 	filename   string
+	filenames  []string // if set, the filenames tried in order of priority instead of filename.
 	dirs       []string
+	fileSet    bool // true if the filename or dirs were given by an option.
+	envConfig  bool // true if loader settings are read from the FIG_* environment variables.
 	tag        string
 	timeLayout string
 	useEnv     bool
 	useStrict  bool
+	strictTags bool
 	ignoreFile bool
 	envPrefix  string
+	url        string
+	httpClient *http.Client
+
+	onInvalidFile    func(path string, err error) // if set, called for files that fail to decode which are then skipped.
+	maxFileSize      int64                        // maximum size of the config in bytes, if positive.
+	encoding         string                       // character encoding of the config, utf-8 if empty.
+	yamlDoc          *yamlDocSelector             // selects the document of a multi-document yaml config, the first if nil.
+	yamlNode         *yaml.Node                   // if set, receives the node of the yaml document that is loaded.
+	rejectDuplicates bool                         // if set, json configs with duplicate keys are rejected.
+
+	systemUserApp string            // if set, the app whose system and user config files are merged.
+	requireFile   bool              // true if a config file must be found even where it's optional.
+	defaultsFile  string            // if set, the file whose values the config is merged over.
+	defaultsFS    fs.FS             // if set, the file system that defaultsFile is read from.
+	fileDirs      map[string]string // directories of the files that set each value, by lower-cased key path.
+
+	reader        io.Reader
+	readerDecoder Decoder // decoder of reader, if it was not named.
+	readerName    string  // filename of reader, if it was named.
+
+	envPrefixes []string // fallback env prefixes that are tried after envPrefix.
+
+	timeLayoutKey string // key in the config file whose value overrides timeLayout.
+
+	migrations map[int]MigrationFunc // migrations of the config, by the version they migrate from.
+
+	subtree             string // dot separated path of the subtree to load.
+	allowMissingSubtree bool
+
+	mergeEnvSlices   bool
+	envJSON          bool              // if set, struct and map fields accept json objects from the env.
+	envFile          string            // path of a .env file whose variables are used when not set in the env.
+	fieldEnvNames    map[string]bool   // env names of the fields of the cfg being processed, never map entries.
+	fileEnv          map[string]string // variables read from the env file.
+	trimSpace        bool
+	expandDefaults   bool
+	boolWords        bool
+	numericBool      *bool // if set, whether 0 and 1 are accepted as bools by every source.
+	numericSeps      bool  // if set, underscores may separate the digits of numbers in env and defaults.
+	deepRequired     bool
+	envConflicts     bool // if set, a field that env sets to a value different from the file's is an error.
+	noDefaults       bool
+	validateDefaults bool
+
+	envSplitCamelCase bool
+	envUseFieldName   bool // if set, env keys are formed from field names rather than alt names.
+	decodeHooks       []mapstructure.DecodeHookFunc
+
+	defaultCtors    map[reflect.Type]func() interface{}        // constructors of interface defaults, by interface type.
+	defaultResolver func(fieldPath, raw string) (string, bool) // resolves default values before they are parsed.
+
+	normalizeMapKey func(string) string // if set, rewrites the keys of map fields read from the config file.
+
+	presenceAware bool
+	present       map[string]bool // paths of fields provided by the file or env.
+	nullAsEmpty   bool
+	null          map[string]bool // paths of fields that are null in the file, if nullAsEmpty.
+
+	logger func(format string, args ...interface{})
+	tracer func(TraceEvent)
+
+	onUnmarshalErr func(field, val string, err error) // if set, values that fail to unmarshal are reported to it and ignored.
+	unmarshalErrs  []decodeUnmarshalError             // unmarshal errors of the decode in progress.
+
+	postLoad []func(cfg interface{}) error
+	onLoad   []func(LoadInfo)
+	info     LoadInfo // summary of the load in progress.
+}
+
+// LoadInfo is a summary of a successful load which is passed to callbacks
+// registered with the `OnLoad` option.
+type LoadInfo struct {
+	// File is the path of the config file that was loaded, or empty if
+	// no file was loaded.
+	File string
+	// Defaults contains the paths of the fields that were set to their default value.
+	Defaults []string
+	// Env contains the paths of the fields that were set from the environment.
+	Env []string
 }
 
 func (f *fig) Load(cfg interface{}) error {
@@ -127,294 +338,2436 @@ func (f *fig) Load(cfg interface{}) error {
 		return fmt.Errorf("cfg must be a pointer to a struct")
 	}
 
-	vals := make(map[string]interface{})
+	if err := f.applyEnvConfig(); err != nil {
+		return err
+	}
+	if err := f.readEnvFile(); err != nil {
+		return err
+	}
 
-	if !f.ignoreFile {
-		file, err := f.findCfgFile()
-		if err != nil {
+	if f.strictTags {
+		if err := checkTags(reflect.TypeOf(cfg).Elem(), f.tag); err != nil {
 			return err
 		}
+	}
 
-		vals, err = f.decodeFile(file)
-		if err != nil {
+	if f.validateDefaults {
+		if err := f.checkDefaults(reflect.TypeOf(cfg).Elem()); err != nil {
 			return err
 		}
 	}
 
+	vals, file, err := f.readVals()
+	if err != nil {
+		return err
+	}
+	f.info.File = file
+
+	vals, err = f.rootList(vals, reflect.TypeOf(cfg).Elem())
+	if err != nil {
+		return err
+	}
+
+	vals, err = f.applyFieldTags(vals, reflect.TypeOf(cfg).Elem())
+	if err != nil {
+		return err
+	}
+
 	if err := f.decodeMap(vals, cfg); err != nil {
 		return err
 	}
 
-	return f.processCfg(cfg)
-}
+	if err := f.processCfg(cfg); err != nil {
+		return err
+	}
 
-func (f *fig) findCfgFile() (path string, err error) {
-	for _, dir := range f.dirs {
-		path = filepath.Join(dir, f.filename)
-		if fileExists(path) {
-			return
+	for _, fn := range f.postLoad {
+		if err := fn(cfg); err != nil {
+			return err
 		}
 	}
-	return "", fmt.Errorf("%s: %w", f.filename, ErrFileNotFound)
-}
 
-// decodeFile reads the file and unmarshalls it using a decoder based on the file extension.
-func (f *fig) decodeFile(file string) (map[string]interface{}, error) {
-	fd, err := os.Open(file)
-	if err != nil {
-		return nil, err
+	for _, fn := range f.onLoad {
+		fn(f.info)
 	}
-	defer fd.Close()
 
-	vals := make(map[string]interface{})
+	return nil
+}
 
-	switch filepath.Ext(file) {
-	case ".yaml", ".yml":
-		if err := yaml.NewDecoder(fd).Decode(&vals); err != nil {
-			return nil, err
-		}
-	case ".json":
-		if err := json.NewDecoder(fd).Decode(&vals); err != nil {
-			return nil, err
-		}
-	case ".toml":
-		if err := toml.NewDecoder(fd).Decode(&vals); err != nil {
-			return nil, err
-		}
-	default:
-		return nil, fmt.Errorf("unsupported file extension %s", filepath.Ext(f.filename))
+// LoadAll is like Load but loads the config file into each of cfgs, reading
+// and decoding the file only once. Each struct in cfgs is processed
+// independently of the others.
+//
+//	var (
+//	  db     DBConfig
+//	  server ServerConfig
+//	)
+//	err := fig.LoadAll([]interface{}{&db, &server}, fig.File("app.yaml"))
+//
+// In strict mode a key in the config file is only reported as unknown if none of
+// the structs contain a corresponding field. Errors that occur while processing
+// a struct are prefixed with its type. Callbacks registered with `PostLoad` and
+// `OnLoad` are called once for each struct, in the order they appear in cfgs.
+func LoadAll(cfgs []interface{}, options ...Option) error {
+	fig := defaultFig()
+
+	for _, opt := range options {
+		opt(fig)
 	}
 
-	return vals, nil
+	return fig.LoadAll(cfgs)
 }
 
-// decodeMap decodes a map of values into result using the mapstructure library.
-func (f *fig) decodeMap(m map[string]interface{}, result interface{}) error {
-	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		WeaklyTypedInput: true,
-		Result:           result,
-		TagName:          f.tag,
-		ErrorUnused:      f.useStrict,
-		DecodeHook: mapstructure.ComposeDecodeHookFunc(
-			mapstructure.StringToTimeDurationHookFunc(),
-			mapstructure.StringToTimeHookFunc(f.timeLayout),
-			stringToRegexpHookFunc(),
-			stringToStringUnmarshalerHook(),
-		),
-	})
-	if err != nil {
+func (f *fig) LoadAll(cfgs []interface{}) error {
+	if err := f.applyEnvConfig(); err != nil {
+		return err
+	}
+	if err := f.readEnvFile(); err != nil {
 		return err
 	}
-	return dec.Decode(m)
-}
 
-// stringToRegexpHookFunc returns a DecodeHookFunc that converts strings to regexp.Regexp.
-func stringToRegexpHookFunc() mapstructure.DecodeHookFunc {
-	return func(
-		f reflect.Type,
-		t reflect.Type,
-		data interface{}) (interface{}, error) {
-		if f.Kind() != reflect.String {
-			return data, nil
+	for _, cfg := range cfgs {
+		if !isStructPtr(cfg) {
+			return fmt.Errorf("cfg must be a pointer to a struct, got %T", cfg)
 		}
-		if t != reflect.TypeOf(&regexp.Regexp{}) {
-			return data, nil
+		if f.strictTags {
+			if err := checkTags(reflect.TypeOf(cfg).Elem(), f.tag); err != nil {
+				return fmt.Errorf("%T: %w", cfg, err)
+			}
+		}
+		if f.validateDefaults {
+			if err := f.checkDefaults(reflect.TypeOf(cfg).Elem()); err != nil {
+				return fmt.Errorf("%T: %w", cfg, err)
+			}
 		}
-		//nolint:forcetypeassert
-		return regexp.Compile(data.(string))
 	}
-}
 
-// stringToStringUnmarshalerHook returns a DecodeHookFunc that executes a custom method which
-// satisfies the StringUnmarshaler interface on custom types.
-func stringToStringUnmarshalerHook() mapstructure.DecodeHookFunc {
-	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
-		if f.Kind() != reflect.String {
-			return data, nil
-		}
+	vals, file, err := f.readVals()
+	if err != nil {
+		return err
+	}
 
-		ds, ok := data.(string)
-		if !ok {
-			return data, nil
-		}
+	// every cfg gets its own copy of f so that state gathered while
+	// loading one struct does not leak into the others
+	figs := make([]*fig, len(cfgs))
+	unused := make([][]string, len(cfgs))
 
-		if reflect.PointerTo(t).Implements(reflect.TypeOf((*StringUnmarshaler)(nil)).Elem()) {
-			val := reflect.New(t).Interface()
+	for i, cfg := range cfgs {
+		g := *f
+		g.present = nil
+		g.null = nil
+		g.info = LoadInfo{File: file}
+		figs[i] = &g
 
-			if unmarshaler, ok := val.(StringUnmarshaler); ok {
-				err := unmarshaler.UnmarshalString(ds)
-				if err != nil {
-					return nil, err
-				}
+		m, err := g.rootList(copyValue(vals).(map[string]interface{}), reflect.TypeOf(cfg).Elem())
+		if err != nil {
+			return fmt.Errorf("%T: %w", cfg, err)
+		}
+		m, err = g.applyFieldTags(m, reflect.TypeOf(cfg).Elem())
+		if err != nil {
+			return fmt.Errorf("%T: %w", cfg, err)
+		}
 
-				return reflect.ValueOf(val).Elem().Interface(), nil
-			}
+		unused[i], err = g.decodeMapUnused(m, cfg)
+		if err != nil {
+			return fmt.Errorf("%T: %w", cfg, err)
 		}
+	}
 
-		return data, nil
+	if f.useStrict {
+		if keys := unknownKeys(unused); len(keys) > 0 {
+			return &ErrUnknownKeys{Keys: keys}
+		}
 	}
-}
 
-// processCfg processes a cfg struct after it has been loaded from
-// the config file, by validating required fields and setting defaults
-// where applicable.
-func (f *fig) processCfg(cfg interface{}) error {
-	fields := flattenCfg(cfg, f.tag)
-	errs := make(fieldErrors)
+	for i, cfg := range cfgs {
+		if err := figs[i].processCfg(cfg); err != nil {
+			return fmt.Errorf("%T: %w", cfg, err)
+		}
 
-	for _, field := range fields {
-		if err := f.processField(field); err != nil {
-			errs[field.path()] = err
+		for _, fn := range f.postLoad {
+			if err := fn(cfg); err != nil {
+				return fmt.Errorf("%T: %w", cfg, err)
+			}
 		}
-	}
 
-	if len(errs) > 0 {
-		return errs
+		for _, fn := range f.onLoad {
+			fn(figs[i].info)
+		}
 	}
 
 	return nil
 }
 
-// processField processes a single field and is called by processCfg
-// for each field in cfg.
-func (f *fig) processField(field *field) error {
-	if field.required && field.setDefault {
-		return fmt.Errorf("field cannot have both a required validation and a default value")
-	}
-
-	if f.useEnv {
-		if err := f.setFromEnv(field.v, field.path()); err != nil {
-			return fmt.Errorf("unable to set from env: %w", err)
-		}
+// applyEnvConfig applies the loader settings given by the FIG_* environment
+// variables if the FromEnvConfig option is used. Settings configured by
+// other options take precedence.
+func (f *fig) applyEnvConfig() error {
+	if !f.envConfig {
+		return nil
 	}
 
-	if field.required && isZero(field.v) {
-		return fmt.Errorf("required validation failed")
+	if path := os.Getenv(EnvConfigFile); path != "" && !f.fileSet {
+		f.filename = filepath.Base(path)
+		f.filenames = nil
+		f.dirs = []string{filepath.Dir(path)}
+		f.logf("using config file %s from %s", path, EnvConfigFile)
 	}
 
-	if field.setDefault && isZero(field.v) {
-		if err := f.setDefaultValue(field.v, field.defaultVal); err != nil {
-			return fmt.Errorf("unable to set default: %w", err)
+	if val := os.Getenv(EnvStrict); val != "" && !f.useStrict {
+		strict, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvStrict, err)
 		}
+		f.useStrict = strict
 	}
 
 	return nil
 }
 
-func (f *fig) setFromEnv(fv reflect.Value, key string) error {
-	key = f.formatEnvKey(key)
-	if val, ok := os.LookupEnv(key); ok {
-		return f.setValue(fv, val)
+// EnvKeys returns the sorted names of the environment variables that fig
+// looks up when loading cfg with `UseEnv(prefix)` and the given options,
+// without loading anything. cfg must be a pointer to a struct.
+//
+//	keys, err := fig.EnvKeys(&cfg, "myapp", fig.EnvSplitCamelCase())
+//	// [MYAPP_LOG_LEVEL MYAPP_SERVER_HOST ...]
+//
+// Options that affect the names, such as `EnvSplitCamelCase` and `Tag`, are
+// honoured, as are fallback prefixes given with `EnvPrefixes` after prefix.
+// Fields of struct slices are only included for the elements that cfg already
+// contains, and fields whose tag excludes them from the environment are omitted.
+func EnvKeys(cfg interface{}, prefix string, options ...Option) ([]string, error) {
+	if !isStructPtr(cfg) {
+		return nil, fmt.Errorf("cfg must be a pointer to a struct")
 	}
-	return nil
-}
 
-func (f *fig) formatEnvKey(key string) string {
-	// loggers[0].level --> loggers_0_level
-	key = strings.NewReplacer(".", "_", "[", "_", "]", "").Replace(key)
-	if f.envPrefix != "" {
-		key = fmt.Sprintf("%s_%s", f.envPrefix, key)
+	fig := defaultFig()
+	for _, opt := range options {
+		opt(fig)
 	}
-	return strings.ToUpper(key)
-}
+	fig.envPrefix = prefix
 
-// setDefaultValue calls setValue but disallows booleans from
-// being set.
-func (f *fig) setDefaultValue(fv reflect.Value, val string) error {
-	if fv.Kind() == reflect.Bool {
-		return fmt.Errorf("unsupported type: %v", fv.Kind())
+	seen := make(map[string]bool)
+	for _, field := range flattenCfg(cfg, fig.tag) {
+		if field.envExcluded() {
+			continue
+		}
+		key := fig.envPath(field)
+		seen[fig.formatEnvKey(key)] = true
+		for _, p := range fig.envPrefixes {
+			seen[formatEnvKeyPrefix(key, p)] = true
+		}
 	}
-	return f.setValue(fv, val)
-}
 
-// setValue sets fv to val. it attempts to convert val to the correct
-// type based on the field's kind. if conversion fails an error is
-// returned. If fv satisfies the StringUnmarshaler interface it will
-// execute the corresponding StringUnmarshaler.UnmarshalString method
-// on the value.
-// fv must be settable else this panics.
-func (f *fig) setValue(fv reflect.Value, val string) error {
-	if ok, err := trySetFromStringUnmarshaler(fv, val); err != nil {
-		return err
-	} else if ok {
-		return nil
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys, nil
+}
 
-	switch fv.Kind() {
-	case reflect.Ptr:
-		if fv.IsNil() {
-			fv.Set(reflect.New(fv.Type().Elem()))
+// readVals reads the values of the config file, or the config url if
+// one is set, and returns them along with the file's location. If no
+// file should be read then an empty map and location are returned.
+func (f *fig) readVals() (vals map[string]interface{}, file string, err error) {
+	vals = make(map[string]interface{})
+
+	if f.reader != nil {
+		if f.readerName != "" {
+			vals, err = f.decodeNamed(f.reader, f.readerName)
+		} else {
+			vals, err = f.decodeReader(f.reader, string(f.readerDecoder))
 		}
-		return f.setValue(fv.Elem(), val)
-	case reflect.Slice:
-		if err := f.setSlice(fv, val); err != nil {
-			return err
+		if err != nil {
+			return nil, "", err
 		}
-	case reflect.Bool:
-		b, err := strconv.ParseBool(val)
+		file = f.readerName
+		f.logf("loaded config from reader")
+		f.trace(TraceFileLoaded, file, "reader")
+	} else if f.url != "" {
+		vals, err = f.decodeURL(f.url)
 		if err != nil {
-			return err
+			return nil, "", err
 		}
-		fv.SetBool(b)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if _, ok := fv.Interface().(time.Duration); ok {
-			d, err := time.ParseDuration(val)
-			if err != nil {
-				return err
-			}
-			fv.Set(reflect.ValueOf(d))
-		} else {
-			i, err := strconv.ParseInt(val, 10, 64)
-			if err != nil {
-				return err
-			}
-			fv.SetInt(i)
+		file = f.url
+		f.logf("loaded config from %s", file)
+		f.trace(TraceFileLoaded, file, "url")
+	} else if !f.ignoreFile && f.systemUserApp != "" {
+		vals, file, err = f.decodeSystemThenUser()
+		if err != nil {
+			return nil, "", err
 		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		i, err := strconv.ParseUint(val, 10, 64)
+	} else if !f.ignoreFile && f.onInvalidFile != nil {
+		vals, file, err = f.decodeFirstValidFile()
 		if err != nil {
-			return err
+			return nil, "", err
 		}
-		fv.SetUint(i)
-	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(val, 64)
+		f.logf("loaded config file %s", file)
+		f.trace(TraceFileLoaded, file, "")
+	} else if !f.ignoreFile {
+		file, err = f.findCfgFile()
 		if err != nil {
-			return err
+			return nil, "", err
 		}
-		fv.SetFloat(f)
-	case reflect.String:
-		fv.SetString(val)
-	case reflect.Struct: // struct is only allowed a default in the special case where it's a time.Time
-		if _, ok := fv.Interface().(time.Time); ok {
-			t, err := time.Parse(f.timeLayout, val)
-			if err != nil {
-				return err
-			}
-			fv.Set(reflect.ValueOf(t))
-		} else if _, ok := fv.Interface().(regexp.Regexp); ok {
+
+		vals, err = f.decodeFile(file)
+		if err != nil {
+			return nil, "", err
+		}
+		f.logf("loaded config file %s", file)
+		f.trace(TraceFileLoaded, file, "")
+	} else {
+		f.logf("not loading a config file")
+	}
+
+	vals, err = f.migrate(vals)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the values of SystemThenUser are recorded per file as they're decoded
+	if file != "" && f.reader == nil && f.url == "" && f.systemUserApp == "" {
+		f.recordFileDir(vals, filepath.Dir(file))
+	}
+
+	if f.defaultsFile != "" {
+		// the yaml options concern the config rather than its defaults
+		g := *f
+		g.yamlDoc, g.yamlNode = nil, nil
+		var base map[string]interface{}
+		if f.defaultsFS != nil {
+			base, err = g.decodeFSFile(f.defaultsFS, f.defaultsFile)
+		} else {
+			base, err = g.decodeFile(f.defaultsFile)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("defaults file: %w", err)
+		}
+		f.logf("loaded defaults file %s", f.defaultsFile)
+		f.trace(TraceFileLoaded, f.defaultsFile, "defaults")
+		// a defaults file that is empty or null decodes to a nil map
+		if base == nil {
+			base = make(map[string]interface{})
+		}
+		// paths in a file system have no directory on disk to resolve against
+		dir := ""
+		if f.defaultsFS == nil {
+			dir = filepath.Dir(f.defaultsFile)
+		}
+		cfgDirs := f.fileDirs
+		f.fileDirs = nil
+		f.recordFileDir(base, dir)
+		for k, d := range cfgDirs {
+			f.fileDirs[k] = d
+		}
+		mergeMaps(base, vals)
+		vals = base
+	}
+
+	if f.timeLayoutKey != "" {
+		if err := f.timeLayoutFromVals(vals); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if f.subtree != "" {
+		vals, err = f.selectSubtree(vals)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return vals, file, nil
+}
+
+// unknownKeys returns the sorted keys that are unused by every struct,
+// given the keys left unused by each struct. A key is considered unused
+// by a struct if the key itself or one of its ancestors is unused.
+func unknownKeys(unused [][]string) []string {
+	covers := func(keys []string, key string) bool {
+		for _, k := range keys {
+			if key == k || strings.HasPrefix(key, k+".") || strings.HasPrefix(key, k+"[") {
+				return true
+			}
+		}
+		return false
+	}
+
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, keys := range unused {
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			all := true
+			for _, other := range unused {
+				if !covers(other, key) {
+					all = false
+					break
+				}
+			}
+			if all {
+				unknown = append(unknown, key)
+			}
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// searchFile reports whether the config file at path exists.
+func (f *fig) searchFile(path string) bool {
+	if fileExists(path) {
+		f.trace(TraceFileSearched, path, "found")
+		return true
+	}
+	f.trace(TraceFileSearched, path, "not found")
+	return false
+}
+
+// names returns the filenames of the config file in order of priority.
+func (f *fig) names() []string {
+	if len(f.filenames) > 0 {
+		return f.filenames
+	}
+	return []string{f.filename}
+}
+
+// cfgPaths returns the paths at which the config file is searched, in
+// order. Every directory is searched for a filename before the next
+// filename is tried.
+func (f *fig) cfgPaths() []string {
+	var paths []string
+	for _, name := range f.names() {
+		for _, dir := range f.dirs {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	return paths
+}
+
+func (f *fig) findCfgFile() (path string, err error) {
+	for _, path = range f.cfgPaths() {
+		if f.searchFile(path) {
+			return
+		}
+	}
+	return "", fmt.Errorf("%s: %w", strings.Join(f.names(), ", "), ErrFileNotFound)
+}
+
+// decodeFirstValidFile is like findCfgFile followed by decodeFile, except that
+// a file that fails to decode is reported to the invalid file callback and
+// the search continues in the next directory.
+func (f *fig) decodeFirstValidFile() (map[string]interface{}, string, error) {
+	var lastErr error
+	for _, path := range f.cfgPaths() {
+		if !f.searchFile(path) {
+			continue
+		}
+		vals, err := f.decodeFile(path)
+		if err != nil {
+			f.logf("skipping invalid config file %s: %v", path, err)
+			f.onInvalidFile(path, err)
+			lastErr = err
+			continue
+		}
+		return vals, path, nil
+	}
+	if lastErr != nil {
+		return nil, "", fmt.Errorf("%s: no valid file found: %w", strings.Join(f.names(), ", "), lastErr)
+	}
+	return nil, "", fmt.Errorf("%s: %w", strings.Join(f.names(), ", "), ErrFileNotFound)
+}
+
+// systemConfigDir is the directory that contains the system-wide config
+// directories of apps.
+var systemConfigDir = "/etc"
+
+// decodeSystemThenUser decodes the app's system config file followed by
+// its user config file, merging the values of the user file over those
+// of the system file. Files that do not exist are skipped, although it
+// fails with ErrFileNotFound if neither exists and requireFile is set.
+// It returns the path of the last file that was decoded, if any. If several
+// filenames are configured then the first one that exists is used
+// in each of the two directories.
+func (f *fig) decodeSystemThenUser() (map[string]interface{}, string, error) {
+	dirs := []string{filepath.Join(systemConfigDir, f.systemUserApp)}
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, f.systemUserApp))
+	}
+
+	vals := make(map[string]interface{})
+	var file string
+	for _, dir := range dirs {
+		g := *f
+		g.dirs = []string{dir}
+		path, err := g.findCfgFile()
+		if err != nil {
+			continue
+		}
+		m, err := f.decodeFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		mergeMaps(vals, m)
+		f.recordFileDir(m, filepath.Dir(path))
+		file = path
+		f.logf("loaded config file %s", path)
+		f.trace(TraceFileLoaded, path, "")
+	}
+	if file == "" && f.requireFile {
+		return nil, "", fmt.Errorf("%s: %w", strings.Join(dirs, ", "), ErrFileNotFound)
+	}
+	if file == "" {
+		f.logf("no system or user config file found for %s", f.systemUserApp)
+	}
+	return vals, file, nil
+}
+
+// decodeFile reads the file and unmarshalls it using a decoder based on the file extension.
+// If the file has a `.gz` extension then it is decompressed and the decoder is picked based
+// on the extension that precedes it.
+func (f *fig) decodeFile(file string) (map[string]interface{}, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	return f.decodeNamed(fd, file)
+}
+
+// decodeFSFile is like decodeFile but reads the file named name from fsys.
+func (f *fig) decodeFSFile(fsys fs.FS, name string) (map[string]interface{}, error) {
+	fd, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	return f.decodeNamed(fd, name)
+}
+
+// decodeNamed unmarshalls the contents of r using the decoder that corresponds
+// to the extension of name. If name ends in .gz then r is decompressed first and
+// the decoder is picked based on the preceding extension.
+func (f *fig) decodeNamed(r io.Reader, name string) (map[string]interface{}, error) {
+	ext := filepath.Ext(name)
+	if ext == ".gz" {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress %s: %w", name, err)
+		}
+		defer gz.Close()
+
+		r = gz
+		ext = filepath.Ext(strings.TrimSuffix(name, ext))
+	}
+
+	return f.decodeReader(r, ext)
+}
+
+// decodeURL fetches the file at rawURL and unmarshalls it using a decoder based on the
+// extension of the URL's path.
+func (f *fig) decodeURL(rawURL string) (map[string]interface{}, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := f.httpClient
+	if client == nil {
+		client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unable to fetch config %s: unexpected status %s", u.Redacted(), resp.Status)
+	}
+
+	return f.decodeReader(resp.Body, path.Ext(u.Path))
+}
+
+// decodeReader unmarshalls the contents of r using the decoder that corresponds to the
+// file extension ext.
+func (f *fig) decodeReader(r io.Reader, ext string) (vals map[string]interface{}, err error) {
+	if f.maxFileSize > 0 {
+		// read one byte past the limit to tell a file of exactly the limit apart from a larger one
+		lr := &io.LimitedReader{R: r, N: f.maxFileSize + 1}
+		defer func() {
+			if lr.N == 0 {
+				vals, err = nil, fmt.Errorf("config exceeds the maximum size of %d bytes", f.maxFileSize)
+			}
+		}()
+		r = lr
+	}
+
+	if f.encoding != "" {
+		table, err := charsetTable(f.encoding)
+		if err != nil {
+			return nil, err
+		}
+		if table != nil {
+			r = &charsetReader{r: r, table: table}
+		}
+	}
+
+	if ext == string(DecoderAuto) {
+		br := bufio.NewReaderSize(r, sniffLen)
+		prefix, _ := br.Peek(sniffLen) // a shorter prefix is returned for a shorter config
+		ext = string(sniffDecoder(prefix))
+		f.logf("guessed config format %s", ext)
+		r = br
+	}
+
+	vals = make(map[string]interface{})
+
+	switch ext {
+	case ".yaml", ".yml":
+		if vals, err = f.decodeYAML(r); err != nil {
+			return nil, err
+		}
+		// yaml mappings with non-string keys are decoded into map[interface{}]interface{}
+		vals = stringifyKeys(vals).(map[string]interface{})
+	case ".json":
+		if f.rejectDuplicates {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkJSONDuplicates(data); err != nil {
+				return nil, err
+			}
+			r = bytes.NewReader(data)
+		}
+		var data interface{}
+		if err := json.NewDecoder(r).Decode(&data); err != nil {
+			return nil, err
+		}
+		switch data := data.(type) {
+		case map[string]interface{}:
+			vals = data
+		case []interface{}:
+			vals[rootListKey] = data
+		case nil:
+			vals = nil
+		default:
+			return nil, fmt.Errorf("json: cannot decode %T into the config", data)
+		}
+	case ".toml":
+		if err := toml.NewDecoder(r).Decode(&vals); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file extension %s", ext)
+	}
+
+	return vals, nil
+}
+
+// yamlDocSelector selects a document of a multi-document yaml config,
+// either by its index or by the value of one of its top-level keys.
+type yamlDocSelector struct {
+	index      int
+	key, value string // if key is set the document is selected by key rather than index.
+}
+
+func (s *yamlDocSelector) match(i int, doc map[string]interface{}) bool {
+	if s.key == "" {
+		return i == s.index
+	}
+	v, ok := doc[s.key]
+	return ok && fmt.Sprint(v) == s.value
+}
+
+func (s *yamlDocSelector) String() string {
+	if s.key == "" {
+		return fmt.Sprintf("at index %d", s.index)
+	}
+	return fmt.Sprintf("with %s %q", s.key, s.value)
+}
+
+// decodeYAML decodes the yaml document in r that is selected by the
+// YAMLDocument or YAMLDocumentWhere option, or the first document of r
+// if neither was given. The node of the document is stored in the node
+// given to RetainYAMLNode, if any.
+func (f *fig) decodeYAML(r io.Reader) (map[string]interface{}, error) {
+	dec := yaml.NewDecoder(r)
+	for i := 0; ; i++ {
+		var node yaml.Node
+		vals := make(map[string]interface{})
+		err := dec.Decode(&node)
+		if err == nil && len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+			var list []interface{}
+			if err = node.Decode(&list); err == nil {
+				vals[rootListKey] = list
+			}
+		} else if err == nil {
+			err = node.Decode(&vals)
+		}
+
+		if f.yamlDoc == nil {
+			if err != nil {
+				return nil, err
+			}
+		} else if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no yaml document %s", f.yamlDoc)
+		} else if err != nil {
+			return nil, fmt.Errorf("yaml document %d: %w", i, err)
+		} else if !f.yamlDoc.match(i, vals) {
+			continue
+		}
+
+		if f.yamlNode != nil {
+			*f.yamlNode = node
+		}
+		return vals, nil
+	}
+}
+
+// rootListKey is the key under which decodeReader stores the list of a
+// config whose top level is a list rather than a mapping.
+const rootListKey = "\x00list"
+
+// rootList moves the list of a config whose top level is a list to the key
+// of the only slice or array field of the struct type t, leaving vals as is
+// if the config is a mapping.
+func (f *fig) rootList(vals map[string]interface{}, t reflect.Type) (map[string]interface{}, error) {
+	list, ok := vals[rootListKey]
+	if !ok {
+		return vals, nil
+	}
+
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Slice && ft.Kind() != reflect.Array {
+			continue
+		}
+		name := sf.Name
+		if tag := parseTag(sf.Tag, f.tag); tag.altName != "" {
+			name = tag.altName
+		}
+		names = append(names, name)
+	}
+	if len(names) != 1 {
+		return nil, fmt.Errorf("config is a list, which can only be loaded into a struct with a single slice field, %s has %d", t, len(names))
+	}
+
+	delete(vals, rootListKey)
+	vals[names[0]] = list
+	return vals, nil
+}
+
+// fileFieldErrors converts err, returned by mapstructure while decoding the
+// values m of the config file into result, into a fieldErrors that holds a
+// *FieldError for each field whose value could not be decoded, which
+// unwraps to err. err is returned as-is if any of its errors is not about
+// a known field.
+func (f *fig) fileFieldErrors(err error, m map[string]interface{}, result interface{}) error {
+	var me *mapstructure.Error
+	if !errors.As(err, &me) {
+		return err
+	}
+
+	errs := make(fieldErrors)
+	for _, msg := range me.Errors {
+		path, detail, ok := parseDecodeError(msg)
+		if !ok {
+			return err
+		}
+		val, t, secret := f.lookupPath(m, reflect.TypeOf(result), path)
+		if t == nil {
+			return err
+		}
+		fe := &FieldError{
+			Path:   path,
+			Value:  fmt.Sprint(val),
+			Type:   t,
+			Source: SourceFile,
+			File:   f.info.File,
+			Err:    &decodeError{detail: detail, err: me},
+		}
+		if secret {
+			fe.Value = Redacted
+		}
+		errs[path] = fe
+	}
+	return errs
+}
+
+// lookupPath returns the value at path in vals, which are decoded into a
+// value of type t, along with the type at path and whether a field along
+// the path is secret. Keys are matched regardless of case as mapstructure
+// does. The returned type is nil if path does not lead to a field of t.
+func (f *fig) lookupPath(vals interface{}, t reflect.Type, path string) (interface{}, reflect.Type, bool) {
+	secret := false
+	for _, step := range splitPath(path) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if strings.HasPrefix(step, "[") {
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			default:
+				return nil, nil, false
+			}
+			key := strings.TrimSuffix(strings.TrimPrefix(step, "["), "]")
+			switch v := vals.(type) {
+			case []interface{}:
+				if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(v) {
+					vals = v[i]
+				} else {
+					vals = nil
+				}
+			case map[string]interface{}:
+				vals = v[key]
+			default:
+				vals = nil
+			}
+			continue
+		}
+
+		sf, ok := f.structFieldByKey(t, step)
+		if !ok {
+			return nil, nil, false
+		}
+		t = sf.Type
+		secret = secret || parseTag(sf.Tag, f.tag).secret
+		m, _ := vals.(map[string]interface{})
+		vals, _ = lookupKey(m, step)
+	}
+	return vals, t, secret
+}
+
+// structFieldByKey returns the field of the struct type t, or of the structs
+// squashed into it, that is decoded from key.
+func (f *fig) structFieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag := parseTag(sf.Tag, f.tag)
+		if tag.squash {
+			st := sf.Type
+			for st.Kind() == reflect.Ptr {
+				st = st.Elem()
+			}
+			if sf, ok := f.structFieldByKey(st, key); ok {
+				return sf, true
+			}
+			continue
+		}
+		name := sf.Name
+		if tag.altName != "" {
+			name = tag.altName
+		}
+		if strings.EqualFold(name, key) {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// decodeMap decodes a map of values into result using the mapstructure library.
+func (f *fig) decodeMap(m map[string]interface{}, result interface{}) error {
+	unused, err := f.decodeMapUnused(m, result)
+	if err != nil {
+		return err
+	}
+
+	if f.useStrict && len(unused) > 0 {
+		sort.Strings(unused)
+		return &ErrUnknownKeys{Keys: unused}
+	}
+
+	return nil
+}
+
+// decodeMapUnused is like decodeMap but instead of checking for unknown
+// keys in strict mode it returns the keys of m that were not decoded
+// into result.
+func (f *fig) decodeMapUnused(m map[string]interface{}, result interface{}) ([]string, error) {
+	if d, ok := result.(MapDecoder); ok {
+		return nil, d.Decode(m)
+	}
+
+	md, err := f.decode(m, result)
+	if err != nil {
+		return nil, f.fileFieldErrors(err, m, result)
+	}
+
+	for _, key := range md.Keys {
+		f.markPresent(key)
+		f.trace(TraceKeyDecoded, key, "")
+	}
+
+	if f.nullAsEmpty {
+		if err := f.markNulls(m, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return md.Unused, nil
+}
+
+// markNulls records the paths of the fields of result whose value in m
+// is null. The null values of m are decoded into a new value of the type
+// of result so that keys are matched to fields exactly as they are when
+// decoding m itself.
+func (f *fig) markNulls(m map[string]interface{}, result interface{}) error {
+	nulls := nullsOnly(m)
+	if nulls == nil {
+		return nil
+	}
+
+	var md mapstructure.Metadata
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ZeroFields: true,
+		Metadata:   &md,
+		Result:     reflect.New(reflect.TypeOf(result).Elem()).Interface(),
+		TagName:    f.tag,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(nulls); err != nil {
+		return err
+	}
+
+	if f.null == nil {
+		f.null = make(map[string]bool)
+	}
+	for _, key := range md.Keys {
+		f.null[key] = true
+	}
+	return nil
+}
+
+// decode decodes m into result using the mapstructure library and returns
+// the metadata of the decode.
+func (f *fig) decode(m map[string]interface{}, result interface{}) (md mapstructure.Metadata, err error) {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		Metadata:         &md,
+		Result:           result,
+		TagName:          f.tag,
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(f.decodeHookFuncs()...),
+	})
+	if err != nil {
+		return md, err
+	}
+	err = dec.Decode(m)
+	if err == nil {
+		f.reportUnmarshalErrs(result)
+	}
+	f.unmarshalErrs = nil
+	return md, err
+}
+
+// decodeHookFuncs returns the decode hooks used by decodeMap, which are
+// fig's own hooks followed by any user-supplied hooks.
+func (f *fig) decodeHookFuncs() []mapstructure.DecodeHookFunc {
+	hooks := []mapstructure.DecodeHookFunc{
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToTimeHookFunc(f.timeLayout),
+		stringToRegexpHookFunc(),
+		f.stringToStringUnmarshalerHook(),
+		stringToScannerHookFunc(),
+		stringToTimeNameHookFunc(),
+		toRawMessageHookFunc(),
+		sliceToSetHookFunc(),
+		nativeTimeHookFunc(f.timeLayout),
+	}
+	if f.trimSpace {
+		hooks = append([]mapstructure.DecodeHookFunc{trimSpaceHookFunc()}, hooks...)
+	}
+	if f.boolWords || f.numericBool != nil {
+		hooks = append(hooks, f.stringToBoolHookFunc())
+	}
+	if f.numericBool != nil {
+		hooks = append(hooks, f.numberToBoolHookFunc())
+	}
+	return append(hooks, f.decodeHooks...)
+}
+
+// logf logs a diagnostic message using the logger set by the WithLogger
+// option, if any.
+func (f *fig) logf(format string, args ...interface{}) {
+	if f.logger != nil {
+		f.logger(format, args...)
+	}
+}
+
+// markPresent records that the field at path was explicitly provided
+// by a config source.
+func (f *fig) markPresent(path string) {
+	if f.present == nil {
+		f.present = make(map[string]bool)
+	}
+	f.present[path] = true
+}
+
+// needsDefault reports whether field should be set to its default value.
+// That is the case if it's zero, except for bools which are defaulted only
+// if they were not provided by a config source, as false is also zero.
+func (f *fig) needsDefault(field *field) bool {
+	if field.v.Kind() == reflect.Bool {
+		return !f.present[field.path()] && !field.v.Bool()
+	}
+	// user types define their own zero value, nil pointers are left to isZero
+	if k := field.v.Kind(); k != reflect.Ptr && k != reflect.Interface {
+		if z, ok := asZeroer(field.v); ok {
+			return z.IsZero()
+		}
+	}
+	// structs are never considered zero by isZero as they're always set. Only
+	// check fields declared as structs, as pointers and interfaces holding a
+	// struct are dereferenced by flattenField.
+	if field.st.Type != nil && field.st.Type.Kind() == reflect.Struct {
+		return field.v.IsZero()
+	}
+	// arrays are never considered zero by isZero as their length is fixed
+	if field.v.Kind() == reflect.Array {
+		return field.v.IsZero()
+	}
+	return isZero(field.v)
+}
+
+// isSet reports whether field has been set. If presence-awareness
+// is enabled then a field is set if it was provided by any config
+// source, even if to its zero value. Otherwise a field is set if
+// it's not zero.
+func (f *fig) isSet(field *field) bool {
+	if f.presenceAware {
+		return f.present[field.path()]
+	}
+	return !isZero(field.v)
+}
+
+// timeLayoutFromVals sets the time layout to the value of the time layout
+// key in vals, if present, and removes the key from vals.
+func (f *fig) timeLayoutFromVals(vals map[string]interface{}) error {
+	v, ok := vals[f.timeLayoutKey]
+	if !ok {
+		return nil
+	}
+	layout, ok := v.(string)
+	if !ok || layout == "" {
+		return fmt.Errorf("%s: time layout must be a non-empty string, got %v", f.timeLayoutKey, v)
+	}
+	f.logf("using time layout %q from key %s", layout, f.timeLayoutKey)
+	f.timeLayout = layout
+	delete(vals, f.timeLayoutKey)
+	return nil
+}
+
+// recordFileDir records dir as the directory of the file that set each of
+// the values in vals, which replaces the directory of a file recorded
+// before. Values are recorded down to the maps they're in, so the value of
+// a list is recorded as a whole.
+func (f *fig) recordFileDir(vals map[string]interface{}, dir string) {
+	if f.fileDirs == nil {
+		f.fileDirs = make(map[string]string)
+	}
+	var walk func(m map[string]interface{}, prefix string)
+	walk = func(m map[string]interface{}, prefix string) {
+		for k, v := range m {
+			path := strings.ToLower(k)
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(sub, path)
+				continue
+			}
+			f.fileDirs[path] = dir
+		}
+	}
+	walk(vals, "")
+}
+
+// fileDirOf returns the directory of the file that set the value of the
+// field at path, or of the list that the field is part of. It returns ""
+// if no file set the value or if the file has no directory on disk.
+func (f *fig) fileDirOf(path string) string {
+	p := strings.ToLower(path)
+	if f.subtree != "" {
+		p = strings.ToLower(f.subtree) + "." + p
+	}
+	for {
+		if dir, ok := f.fileDirs[p]; ok {
+			return dir
+		}
+		i := strings.LastIndexAny(p, ".[")
+		if i < 0 {
+			return ""
+		}
+		p = p[:i]
+	}
+}
+
+// selectSubtree returns the map found under the dot separated subtree
+// path in vals. If no value exists at that path then an error wrapping
+// ErrSubtreeNotFound is returned, unless missing subtrees are allowed
+// in which case an empty map is returned.
+func (f *fig) selectSubtree(vals map[string]interface{}) (map[string]interface{}, error) {
+	m := vals
+	for _, key := range strings.Split(f.subtree, ".") {
+		v, ok := lookupKey(m, key)
+		if !ok || v == nil {
+			if f.allowMissingSubtree {
+				f.logf("subtree %s not found, using an empty subtree", f.subtree)
+				return make(map[string]interface{}), nil
+			}
+			return nil, fmt.Errorf("%s: %w", f.subtree, ErrSubtreeNotFound)
+		}
+		if m, ok = v.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s: subtree is of type %T, expected a map", f.subtree, v)
+		}
+	}
+	return m, nil
+}
+
+// applyFieldTags walks vals alongside the struct type t and converts values
+// whose target field carries a tag that alters how it is parsed (such as a
+// per-field time layout), so that they can be decoded by decodeMap.
+func (f *fig) applyFieldTags(vals map[string]interface{}, t reflect.Type) (map[string]interface{}, error) {
+	v, err := f.applyFieldTag(vals, t, structTag{}, "")
+	if err != nil {
+		return nil, err
+	}
+	m, _ := v.(map[string]interface{})
+	return m, nil
+}
+
+// applyFieldTag converts data, which is destined for a value of type t that
+// is governed by the field tag st, and then recurses into its children.
+func (f *fig) applyFieldTag(data interface{}, t reflect.Type, st structTag, path string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if s, ok := data.(string); ok && st.timeLayout != "" && t == reflect.TypeOf(time.Time{}) {
+		tm, err := time.Parse(st.timeLayout, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return tm, nil
+	}
+
+	if s, ok := data.(string); ok && st.bytes {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			b, err := parseBytes(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return b, nil
+		}
+	}
+
+	if st.hex && isByteSeqType(t) && data != nil {
+		// e.g. yaml decodes an unquoted 0001 as a number
+		s, ok := data.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: hex value must be a string, got %v", path, data)
+		}
+		v, err := decodeHex(t, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return v.Interface(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return data, nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+			tag := parseTag(sf.Tag, f.tag)
+			if tag.squash {
+				if _, err := f.applyFieldTag(m, sf.Type, tag, path); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			name := sf.Name
+			if tag.altName != "" {
+				name = tag.altName
+			}
+			for key, val := range m {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				v, err := f.applyFieldTag(val, sf.Type, tag, strings.TrimPrefix(path+"."+name, "."))
+				if err != nil {
+					return nil, err
+				}
+				m[key] = v
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := data.([]interface{})
+		if !ok {
+			return data, nil
+		}
+		for i := range s {
+			v, err := f.applyFieldTag(s[i], t.Elem(), st, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = v
+		}
+	case reflect.Map:
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return data, nil
+		}
+		if f.normalizeMapKey != nil {
+			m = normalizeKeys(m, f.normalizeMapKey)
+			data = m
+		}
+		for key, val := range m {
+			v, err := f.applyFieldTag(val, t.Elem(), st, fmt.Sprintf("%s[%s]", path, key))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+	}
+
+	return data, nil
+}
+
+// normalizeKeys returns a copy of m with every key rewritten by fn. Keys
+// that are rewritten into the same key are folded into one entry in the
+// sorted order of the original keys: maps are merged, and any other value
+// replaces the value of the keys before it.
+func normalizeKeys(m map[string]interface{}, fn func(string) string) map[string]interface{} {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nm := make(map[string]interface{}, len(m))
+	for _, key := range keys {
+		nk := fn(key)
+		prev, isMap := nm[nk].(map[string]interface{})
+		if val, ok := m[key].(map[string]interface{}); ok && isMap {
+			mergeMaps(prev, val)
+			continue
+		}
+		nm[nk] = m[key]
+	}
+	return nm
+}
+
+// trimSpaceHookFunc returns a DecodeHookFunc that trims leading and trailing
+// white space from strings that are decoded into string values.
+func trimSpaceHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.String {
+			return data, nil
+		}
+		if s, ok := data.(string); ok {
+			return strings.TrimSpace(s), nil
+		}
+		return data, nil
+	}
+}
+
+// stringToBoolHookFunc returns a DecodeHookFunc that converts strings to bools
+// using parseBool.
+func (f *fig) stringToBoolHookFunc() mapstructure.DecodeHookFunc {
+	return func(from reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || t.Kind() != reflect.Bool {
+			return data, nil
+		}
+		s, ok := data.(string)
+		if !ok {
+			return data, nil
+		}
+		b, err := f.parseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(b).Convert(t).Interface(), nil
+	}
+}
+
+// numberToBoolHookFunc returns a DecodeHookFunc that converts the numbers
+// 0 and 1 to bools if numeric bools are allowed, and rejects numbers that
+// are decoded into bools otherwise. Without it any non-zero number is
+// weakly decoded as true.
+func (f *fig) numberToBoolHookFunc() mapstructure.DecodeHookFunc {
+	return func(from reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if t.Kind() != reflect.Bool {
+			return data, nil
+		}
+		var n float64
+		switch from.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(reflect.ValueOf(data).Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(reflect.ValueOf(data).Uint())
+		case reflect.Float32, reflect.Float64:
+			n = reflect.ValueOf(data).Float()
+		default:
+			return data, nil
+		}
+		if !*f.numericBool || (n != 0 && n != 1) {
+			return nil, fmt.Errorf("invalid bool %v", data)
+		}
+		return reflect.ValueOf(n == 1).Convert(t).Interface(), nil
+	}
+}
+
+// stringToRegexpHookFunc returns a DecodeHookFunc that converts strings to
+// regexp.Regexp and *regexp.Regexp.
+func stringToRegexpHookFunc() mapstructure.DecodeHookFunc {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+		if t != regexpType && t != regexpType.Elem() {
+			return data, nil
+		}
+		//nolint:forcetypeassert
+		re, err := regexp.Compile(data.(string))
+		if err != nil {
+			return nil, err
+		}
+		if t == regexpType.Elem() {
+			return *re, nil
+		}
+		return re, nil
+	}
+}
+
+// stringToTimeNameHookFunc returns a DecodeHookFunc that converts the names
+// of weekdays and months to time.Weekday and time.Month values.
+func stringToTimeNameHookFunc() mapstructure.DecodeHookFunc {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data interface{}) (interface{}, error) {
+		// data is of a named string type if an earlier hook converted it
+		s, ok := data.(string)
+		if !ok {
+			return data, nil
+		}
+		v, ok, err := timeNameValue(t, s)
+		if !ok {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+}
+
+var (
+	weekdayType = reflect.TypeOf(time.Weekday(0))
+	monthType   = reflect.TypeOf(time.Month(0))
+)
+
+// timeNameValue parses s into a value of t if t is time.Weekday or time.Month.
+// s is either the full or three letter name of the weekday or month, in any
+// case, or its number. ok is false if t is neither of these types.
+func timeNameValue(t reflect.Type, s string) (v reflect.Value, ok bool, err error) {
+	var (
+		first, last int
+		name        func(i int) string
+	)
+	switch t {
+	case weekdayType:
+		first, last = int(time.Sunday), int(time.Saturday)
+		name = func(i int) string { return time.Weekday(i).String() }
+	case monthType:
+		first, last = int(time.January), int(time.December)
+		name = func(i int) string { return time.Month(i).String() }
+	default:
+		return reflect.Value{}, false, nil
+	}
+
+	s = strings.TrimSpace(s)
+	for i := first; i <= last; i++ {
+		if n := name(i); strings.EqualFold(s, n) || strings.EqualFold(s, n[:3]) {
+			return reflect.ValueOf(i).Convert(t), true, nil
+		}
+	}
+	if i, err := strconv.Atoi(s); err == nil && i >= first && i <= last {
+		return reflect.ValueOf(i).Convert(t), true, nil
+	}
+	return reflect.Value{}, true, fmt.Errorf("invalid %s %q", strings.ToLower(t.Name()), s)
+}
+
+// stringToScannerHookFunc returns a DecodeHookFunc that converts strings
+// and numbers to big.Rat values and to structs that implement fmt.Scanner.
+func stringToScannerHookFunc() mapstructure.DecodeHookFunc {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data interface{}) (interface{}, error) {
+		if t.Kind() != reflect.Struct {
+			return data, nil
+		}
+		switch f.Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			return data, nil
+		}
+		sv, ok, err := scanValue(t, fmt.Sprint(data))
+		if !ok {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return sv.Interface(), nil
+	}
+}
+
+var (
+	bigRatType  = reflect.TypeOf(big.Rat{})
+	scannerType = reflect.TypeOf((*fmt.Scanner)(nil)).Elem()
+)
+
+// scanValue parses val into a new value of type t. A big.Rat is parsed
+// with SetString, so it accepts fractions such as "1/3" as well as
+// decimals, and any other type whose pointer implements fmt.Scanner is
+// scanned from val, which must hold nothing else after the value. ok is
+// false if t is neither.
+func scanValue(t reflect.Type, val string) (v reflect.Value, ok bool, err error) {
+	if t == bigRatType {
+		r, ok := new(big.Rat).SetString(val)
+		if !ok {
+			return reflect.Value{}, true, fmt.Errorf("invalid rational number %q", val)
+		}
+		return reflect.ValueOf(r).Elem(), true, nil
+	}
+
+	if !reflect.PointerTo(t).Implements(scannerType) {
+		return reflect.Value{}, false, nil
+	}
+	pv := reflect.New(t)
+	r := strings.NewReader(val)
+	if _, err := fmt.Fscan(r, pv.Interface()); err != nil {
+		return reflect.Value{}, true, fmt.Errorf("could not scan %q: %w", val, err)
+	}
+	if rest, _ := io.ReadAll(r); strings.TrimSpace(string(rest)) != "" {
+		return reflect.Value{}, true, fmt.Errorf("could not scan %q: unexpected %q after value", val, strings.TrimSpace(string(rest)))
+	}
+	return pv.Elem(), true, nil
+}
+
+// setStructFromFlow sets the fields of the struct sv from val, a flow
+// style mapping such as "{host:localhost,port:8080}". Values are
+// converted into the type of their field in the same way as values in a
+// config file.
+func (f *fig) setStructFromFlow(sv reflect.Value, val string) error {
+	m, err := parseFlow(val)
+	if err != nil {
+		return err
+	}
+
+	// decode into a copy so that sv is left as-is on error
+	pv := reflect.New(sv.Type())
+	md, err := f.decode(m, pv.Interface())
+	if err != nil {
+		return err
+	}
+	if len(md.Unused) > 0 {
+		sort.Strings(md.Unused)
+		return fmt.Errorf("unknown keys: %s", strings.Join(md.Unused, ", "))
+	}
+	sv.Set(pv.Elem())
+
+	return nil
+}
+
+// isSetType reports whether t is a set, i.e. a map whose values are
+// empty structs such as map[string]struct{}.
+func isSetType(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0
+}
+
+// sliceToSetHookFunc returns a DecodeHookFunc that converts lists into
+// sets, with each element of the list becoming a key of the set.
+func sliceToSetHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if (f.Kind() != reflect.Slice && f.Kind() != reflect.Array) || !isSetType(t) {
+			return data, nil
+		}
+		list := reflect.ValueOf(data)
+		set := make(map[interface{}]interface{}, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			elem := list.Index(i)
+			for elem.Kind() == reflect.Interface && !elem.IsNil() {
+				elem = elem.Elem()
+			}
+			if !elem.IsValid() || !elem.Comparable() || elem.Kind() == reflect.Interface {
+				return nil, fmt.Errorf("set elements must be scalars, got %s", elem.Kind())
+			}
+			set[elem.Interface()] = map[string]interface{}{}
+		}
+		return set, nil
+	}
+}
+
+// rawMessageType is the type of json.RawMessage.
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// toRawMessageHookFunc returns a DecodeHookFunc that converts any value to
+// json.RawMessage by encoding it to json.
+func toRawMessageHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if t != rawMessageType || f == rawMessageType {
+			return data, nil
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(b), nil
+	}
+}
+
+// nativeTimeHookFunc returns a DecodeHookFunc that reconciles timestamps
+// that were decoded natively by the yaml and toml decoders, rather than
+// as strings, with the type of the field. Local toml dates and times are
+// converted to time.Time in UTC, and timestamps that are decoded into
+// string fields are formatted using layout.
+func nativeTimeHookFunc(layout string) mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if t.Kind() == reflect.String {
+			switch d := data.(type) {
+			case time.Time:
+				return d.Format(layout), nil
+			case toml.LocalDateTime, toml.LocalDate, toml.LocalTime:
+				return d.(fmt.Stringer).String(), nil
+			}
+			return data, nil
+		}
+
+		if t != timeType {
+			return data, nil
+		}
+		switch d := data.(type) {
+		case toml.LocalDateTime:
+			return d.AsTime(time.UTC), nil
+		case toml.LocalDate:
+			return d.AsTime(time.UTC), nil
+		case toml.LocalTime:
+			return time.Date(0, 1, 1, d.Hour, d.Minute, d.Second, d.Nanosecond, time.UTC), nil
+		}
+		return data, nil
+	}
+}
+
+// stringToStringUnmarshalerHook returns a DecodeHookFunc that executes a custom method which
+// satisfies the StringUnmarshaler interface on custom types. If lenient unmarshaling is
+// enabled then a value that fails to unmarshal is recorded and replaced by the zero value.
+func (f *fig) stringToStringUnmarshalerHook() mapstructure.DecodeHookFunc {
+	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
+		data := from.Interface()
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+
+		ds, ok := data.(string)
+		if !ok {
+			return data, nil
+		}
+
+		t := to.Type()
+		if reflect.PointerTo(t).Implements(reflect.TypeOf((*StringUnmarshaler)(nil)).Elem()) {
+			val := reflect.New(t).Interface()
+
+			if unmarshaler, ok := val.(StringUnmarshaler); ok {
+				err := unmarshalString(unmarshaler, ds)
+				if err != nil && f.onUnmarshalErr != nil {
+					f.unmarshalErrs = append(f.unmarshalErrs, decodeUnmarshalError{to: to, unmarshalError: unmarshalError{val: ds, err: err}})
+					return reflect.Zero(t).Interface(), nil
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				return reflect.ValueOf(val).Elem().Interface(), nil
+			}
+		}
+
+		return data, nil
+	}
+}
+
+// processCfg processes a cfg struct after it has been loaded from
+// the config file, by validating required fields and setting defaults
+// where applicable.
+func (f *fig) processCfg(cfg interface{}) error {
+	fields := flattenCfg(cfg, f.tag)
+	errs := make(fieldErrors)
+
+	if f.useEnv {
+		f.fieldEnvNames = f.envNames(fields)
+	}
+
+	for _, field := range fields {
+		if err := f.processFieldRecover(field); err != nil {
+			errs[field.path()] = err
+		}
+	}
+
+	for _, field := range fields {
+		if _, ok := errs[field.path()]; ok {
+			continue
+		}
+		if err := f.validateRelations(field, fields); err != nil {
+			errs[field.path()] = err
+		}
+		if err := f.validateDeepRequired(field); err != nil {
+			errs[field.path()] = err
+		}
+		if err := f.validateUnique(field); err != nil {
+			errs[field.path()] = err
+		}
+		if err := validateKeys(field); err != nil {
+			errs[field.path()] = err
+		}
+	}
+	f.validateGroups(fields, errs)
+
+	if f.tracer != nil {
+		for _, field := range fields {
+			if !field.hasValidation() {
+				continue
+			}
+			if err, ok := errs[field.path()]; ok {
+				f.trace(TraceValidation, field.path(), err.Error())
+			} else {
+				f.trace(TraceValidation, field.path(), "passed")
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// processFieldRecover is like processField but turns a panic raised while
+// processing the field, such as by a buggy custom unmarshaler, into an error
+// so that the remaining fields are still processed.
+func (f *fig) processFieldRecover(field *field) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return f.processField(field)
+}
+
+// processField processes a single field and is called by processCfg
+// for each field in cfg.
+func (f *fig) processField(field *field) error {
+	if field.required && field.setDefault {
+		return fmt.Errorf("field cannot have both a required validation and a default value")
+	}
+
+	// only values from a config file are relative to its directory
+	if dir := f.fileDirOf(field.path()); field.relPath && f.present[field.path()] && dir != "" {
+		if err := resolvePaths(field.v, dir); err != nil {
+			return err
+		}
+	}
+
+	// map entries are not settable, they're set from the env through their map
+	if f.useEnv && !field.envExcluded() && field.v.CanSet() {
+		var fileVal reflect.Value
+		if f.envConflicts && f.present[field.path()] {
+			fileVal = reflect.New(field.v.Type()).Elem()
+			fileVal.Set(field.v)
+		}
+		ok, err := f.setFromEnv(field.v, f.envPath(field), field.structTag)
+		if f.ignoreUnmarshalErr(field, err) {
+			ok, err = false, nil
+		}
+		if err != nil {
+			return fieldError(field, err)
+		}
+		if ok && fileVal.IsValid() && !equalValues(fileVal, field.v) {
+			if field.secret {
+				return fmt.Errorf("env conflicts with the value in the config file")
+			}
+			return fmt.Errorf("env sets %v, conflicting with %v in the config file", dumpValue(field.v, f.tag), dumpValue(fileVal, f.tag))
+		}
+		// with EnvJSON the entries of a map compose with a json object given for the whole map
+		if (!ok || f.envJSON) && envMapSupported(field.v) {
+			set, err := f.setMapFromEnv(field.v, f.envPath(field), field.structTag)
+			if err != nil {
+				return fieldError(field, err)
+			}
+			ok = ok || set
+		}
+		if ok {
+			f.markPresent(field.path())
+			f.info.Env = append(f.info.Env, field.path())
+		}
+	}
+
+	if field.required && !f.isSet(field) {
+		return fmt.Errorf("required validation failed")
+	}
+
+	if field.setDefault && !f.noDefaults && !f.null[field.path()] && f.needsDefault(field) {
+		val := field.defaultVal
+		if f.defaultResolver != nil {
+			if s, ok := f.defaultResolver(field.path(), val); ok {
+				val = s
+			}
+		}
+		if err := f.setDefaultValue(field.v, val, field.structTag); f.ignoreUnmarshalErr(field, err) {
+			return transformAndValidate(field)
+		} else if err != nil {
+			return fieldError(field, &FieldError{Value: val, Type: field.t, Source: SourceDefault, Err: err})
+		}
+		f.logf("%s: set to default value", field.path())
+		if field.secret {
+			val = Redacted
+		}
+		f.trace(TraceDefault, field.path(), val)
+		f.info.Defaults = append(f.info.Defaults, field.path())
+	}
+
+	return transformAndValidate(field)
+}
+
+// transformAndValidate applies the transforms of the field's tag to its
+// value, which the string validations of the field then check. Elements
+// of slices and maps share the tag of their parent, which transforms them.
+func transformAndValidate(field *field) error {
+	if len(field.transforms) > 0 && field.sliceIdx < 0 && field.mapKey == nil {
+		if err := transformValue(field.v, field.transforms); err != nil {
+			return err
+		}
+	}
+	return validateStrings(field)
+}
+
+// fieldError fills in the path of fd, and redacts the value if fd is
+// secret, in err if it's a *FieldError. err is returned.
+func fieldError(fd *field, err error) error {
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		fe.Path = fd.path()
+		if fd.secret {
+			fe.Value = Redacted
+		}
+	}
+	return err
+}
+
+// ignoreUnmarshalErr reports whether err, returned while setting fd, is
+// the error of a StringUnmarshaler that should be ignored because lenient
+// unmarshaling is enabled, in which case it is passed to the callback
+// registered with LenientUnmarshal.
+func (f *fig) ignoreUnmarshalErr(fd *field, err error) bool {
+	var ue *unmarshalError
+	if f.onUnmarshalErr == nil || !errors.As(err, &ue) {
+		return false
+	}
+	f.onUnmarshalErr(fd.path(), ue.val, ue.err)
+	return true
+}
+
+// checkDefaults parses the default value of every field in t, and in
+// the types of its fields, into a throwaway value. It returns the
+// errors of the defaults that fail to parse keyed by field path.
+func (f *fig) checkDefaults(t reflect.Type) error {
+	errs := make(fieldErrors)
+	f.checkTypeDefaults(t, "", errs, make(map[reflect.Type]bool))
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (f *fig) checkTypeDefaults(t reflect.Type, path string, errs fieldErrors, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		st := parseTag(sf.Tag, f.tag)
+
+		fieldPath := path
+		if !st.squash {
+			name := sf.Name
+			if st.altName != "" {
+				name = st.altName
+			}
+			fieldPath = strings.TrimPrefix(path+"."+name, ".")
+		}
+
+		if st.setDefault {
+			val := st.defaultVal
+			if f.defaultResolver != nil {
+				if s, ok := f.defaultResolver(fieldPath, val); ok {
+					val = s
+				}
+			}
+			if err := f.setDefaultValue(reflect.New(sf.Type).Elem(), val, st); err != nil {
+				if st.secret {
+					val = Redacted
+				}
+				errs[fieldPath] = &FieldError{Path: fieldPath, Value: val, Type: sf.Type, Source: SourceDefault, Err: err}
+			}
+		}
+		f.checkTypeDefaults(sf.Type, fieldPath, errs, seen)
+	}
+}
+
+// setFromEnv sets fv from the environment variable that corresponds
+// to key, if one exists. It reports whether the variable existed.
+func (f *fig) setFromEnv(fv reflect.Value, key string, st structTag) (bool, error) {
+	val, name, ok := f.lookupEnv(key)
+	if !ok {
+		return false, nil
+	}
+
+	var err error
+	switch {
+	case f.mergeEnvSlices && fv.Kind() == reflect.Slice && (strings.HasPrefix(val, "+[") || strings.HasPrefix(val, "-[")):
+		err = f.mergeSlice(fv, val, st)
+	case f.envJSON && strings.HasPrefix(strings.TrimSpace(val), "{") && envJSONSupported(fv.Type()):
+		err = f.setFromJSON(fv, val)
+	default:
+		err = f.setValue(fv, val, st)
+	}
+	if err != nil {
+		return true, &FieldError{Value: val, Type: fv.Type(), Source: SourceEnv, Env: name, Err: err}
+	}
+	return true, nil
+}
+
+// envJSONSupported reports whether a field of type t can be set from a
+// json object in the environment, which is the case for structs, maps
+// and pointers to them.
+func envJSONSupported(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType && t != regexpType.Elem() && t != bigRatType
+	case reflect.Map:
+		return !isSetType(t)
+	}
+	return false
+}
+
+// setFromJSON decodes val, a json object, over the struct or map fv. Keys
+// missing from val leave the existing value of their field or entry
+// as-is. Values are converted in the same way as values in a config file.
+func (f *fig) setFromJSON(fv reflect.Value, val string) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(val), &m); err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+
+	// the fields of a struct pointed to by fv are set in place so that
+	// they can still be overridden by the environment afterwards
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			pv := reflect.New(fv.Type().Elem())
+			if err := f.decodeJSONObject(m, pv.Elem()); err != nil {
+				return err
+			}
+			fv.Set(pv)
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	return f.decodeJSONObject(m, fv)
+}
+
+// decodeJSONObject decodes m over the struct or map v. v is left as-is
+// on error.
+func (f *fig) decodeJSONObject(m map[string]interface{}, v reflect.Value) error {
+	cp := reflect.New(v.Type())
+	if v.Kind() == reflect.Map {
+		mv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			mv.SetMapIndex(iter.Key(), iter.Value())
+		}
+		cp.Elem().Set(mv)
+	} else {
+		cp.Elem().Set(v)
+	}
+
+	md, err := f.decode(m, cp.Interface())
+	if err != nil {
+		return err
+	}
+	if len(md.Unused) > 0 {
+		sort.Strings(md.Unused)
+		return fmt.Errorf("unknown keys: %s", strings.Join(md.Unused, ", "))
+	}
+	v.Set(cp.Elem())
+
+	return nil
+}
+
+// envMapSupported reports whether the entries of the map fv can be set
+// from the environment, which is the case if fv is settable and both its
+// keys and values can be parsed from a string by setValue.
+func envMapSupported(fv reflect.Value) bool {
+	if fv.Kind() != reflect.Map || !fv.CanSet() {
+		return false
+	}
+	switch fv.Type().Key().Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return false
+	}
+	elem := fv.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	switch elem.Kind() {
+	case reflect.Struct:
+		return elem == timeType || elem == regexpType.Elem()
+	case reflect.Map, reflect.Interface, reflect.Chan, reflect.Func:
+		return false
+	}
+	return true
+}
+
+// setMapFromEnv sets entries of the map fv from the environment variables
+// whose names consist of the env key of path, followed by an underscore
+// and the entry's key. Keys are lower-cased. If a key is given with more
+// than one env prefix then the earlier prefix wins. It reports whether
+// any entry was set.
+func (f *fig) setMapFromEnv(fv reflect.Value, path string, st structTag) (bool, error) {
+	prefixes := []string{f.formatEnvKey(path) + "_"}
+	for _, prefix := range f.envPrefixes {
+		prefixes = append(prefixes, formatEnvKeyPrefix(path, prefix)+"_")
+	}
+
+	type envVar struct{ name, val string }
+	entries := make(map[string]envVar)
+	for i := len(prefixes) - 1; i >= 0; i-- {
+		for _, kv := range f.environ() {
+			name, val, _ := strings.Cut(kv, "=")
+			// e.g. MYAPP_LIMITS_ENABLED belongs to a field limits_enabled next to a map limits
+			if f.fieldEnvNames[name] {
+				continue
+			}
+			if key := strings.TrimPrefix(name, prefixes[i]); key != name && key != "" {
+				f.logf("%s: found env %s", path, name)
+				f.trace(TraceEnvHit, path, name)
+				entries[strings.ToLower(key)] = envVar{name: name, val: val}
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return false, nil
+	}
+
+	// build the entries in a copy so that fv is left as-is if one fails to parse
+	m := reflect.MakeMapWithSize(fv.Type(), fv.Len()+len(entries))
+	iter := fv.MapRange()
+	for iter.Next() {
+		m.SetMapIndex(iter.Key(), iter.Value())
+	}
+	for key, env := range entries {
+		k := reflect.New(fv.Type().Key()).Elem()
+		if err := f.setValue(k, key, structTag{}); err != nil {
+			return false, &FieldError{Value: key, Type: k.Type(), Source: SourceEnv, Env: env.name, Err: err}
+		}
+		v := reflect.New(fv.Type().Elem()).Elem()
+		if err := f.setValue(v, env.val, st); err != nil {
+			return false, &FieldError{Value: env.val, Type: v.Type(), Source: SourceEnv, Env: env.name, Err: err}
+		}
+		m.SetMapIndex(k, v)
+	}
+	fv.Set(m)
+	return true, nil
+}
+
+// envNames returns the names of the env variables that can set any of
+// fields, leaving out those of fields within map entries. setMapFromEnv
+// skips these names so that the variable of a field is never taken for
+// an entry of a sibling map.
+func (f *fig) envNames(fields []*field) map[string]bool {
+	names := make(map[string]bool)
+outer:
+	for _, fd := range fields {
+		for p := fd; p != nil; p = p.parent {
+			if p.mapKey != nil {
+				continue outer
+			}
+		}
+		path := f.envPath(fd)
+		names[f.formatEnvKey(path)] = true
+		for _, prefix := range f.envPrefixes {
+			names[formatEnvKeyPrefix(path, prefix)] = true
+		}
+	}
+	return names
+}
+
+// envPath returns the path of fd that is used to form its
+// environment key.
+func (f *fig) envPath(fd *field) string {
+	if !f.envSplitCamelCase && !f.envUseFieldName {
+		return fd.path()
+	}
+	return fd.pathFunc(func(fd *field) string {
+		if fd.sliceIdx >= 0 || fd.mapKey != nil || (fd.altName != "" && !f.envUseFieldName) {
+			return fd.name()
+		}
+		if f.envSplitCamelCase {
+			return splitCamelCase(fd.st.Name)
+		}
+		return fd.st.Name
+	})
+}
+
+// mergeSlice merges val into sv instead of replacing it. val must be a Go slice
+// formatted as a string prefixed by either a `+`, in which case its elements
+// are appended to sv, or a `-`, in which case its elements are removed from sv.
+// sv must be settable else this panics.
+func (f *fig) mergeSlice(sv reflect.Value, val string, st structTag) error {
+	elems := reflect.New(sv.Type()).Elem()
+	if err := f.setSlice(elems, val[1:], st); err != nil {
+		return err
+	}
+
+	if val[0] == '+' {
+		sv.Set(reflect.AppendSlice(sv, elems))
+		return nil
+	}
+
+	slice := reflect.MakeSlice(sv.Type(), 0, sv.Len())
+	for i := 0; i < sv.Len(); i++ {
+		remove := false
+		for j := 0; j < elems.Len(); j++ {
+			if reflect.DeepEqual(sv.Index(i).Interface(), elems.Index(j).Interface()) {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			slice = reflect.Append(slice, sv.Index(i))
+		}
+	}
+	sv.Set(slice)
+	return nil
+}
+
+// lookupEnv retrieves the value and the name of the environment variable
+// that corresponds to key. Each of the env prefixes is tried in order
+// and the first variable that exists is returned.
+func (f *fig) lookupEnv(key string) (val, name string, ok bool) {
+	name = f.formatEnvKey(key)
+	if val, ok := f.getenv(name); ok {
+		f.logf("%s: found env %s", key, name)
+		f.trace(TraceEnvHit, key, name)
+		return val, name, true
+	}
+	for _, prefix := range f.envPrefixes {
+		name := formatEnvKeyPrefix(key, prefix)
+		if val, ok := f.getenv(name); ok {
+			f.logf("%s: found env %s using fallback prefix %s", key, name, prefix)
+			f.trace(TraceEnvHit, key, name)
+			return val, name, true
+		}
+	}
+	if f.tracer != nil {
+		names := []string{name}
+		for _, prefix := range f.envPrefixes {
+			names = append(names, formatEnvKeyPrefix(key, prefix))
+		}
+		f.trace(TraceEnvMiss, key, strings.Join(names, ", "))
+	}
+	return "", "", false
+}
+
+func (f *fig) formatEnvKey(key string) string {
+	return formatEnvKeyPrefix(key, f.envPrefix)
+}
+
+// formatEnvKeyPrefix formats key into an environment key
+// with the given prefix.
+func formatEnvKeyPrefix(key, prefix string) string {
+	// loggers[0].level --> loggers_0_level
+	key = strings.NewReplacer(".", "_", "[", "_", "]", "").Replace(key)
+	if prefix != "" {
+		key = fmt.Sprintf("%s_%s", prefix, key)
+	}
+	return strings.ToUpper(key)
+}
+
+// setDefaultValue calls setValue with the default val. If default
+// expansion is enabled then environment variable references are
+// expanded in val, per element if fv is a slice.
+func (f *fig) setDefaultValue(fv reflect.Value, val string, st structTag) error {
+	if fv.Kind() == reflect.Interface {
+		if ctor, ok := f.defaultCtors[fv.Type()]; ok {
+			def := ctor()
+			v := reflect.ValueOf(def)
+			if !v.IsValid() || !v.Type().AssignableTo(fv.Type()) {
+				return fmt.Errorf("registered default of type %T does not implement %s", def, fv.Type())
+			}
+			fv.Set(v)
+			return nil
+		}
+	}
+
+	if f.expandDefaults {
+		if fv.Kind() == reflect.Slice && fv.Type() != rawMessageType {
+			ss := stringSlice(val)
+			for i := range ss {
+				s, err := f.expandEnv(ss[i])
+				if err != nil {
+					return err
+				}
+				ss[i] = s
+			}
+			if err := checkEnumDefault(fv.Type(), ss); err != nil {
+				return err
+			}
+			return f.setSliceElems(fv, ss, st)
+		}
+
+		s, err := f.expandEnv(val)
+		if err != nil {
+			return err
+		}
+		val = s
+	}
+
+	ss := []string{val}
+	if k := fv.Kind(); k == reflect.Slice || k == reflect.Array {
+		ss = stringSlice(val)
+	}
+	if err := checkEnumDefault(fv.Type(), ss); err != nil {
+		return err
+	}
+
+	return f.setValue(fv, val, st)
+}
+
+// setValue sets fv to val. it attempts to convert val to the correct
+// type based on the field's kind. if conversion fails an error is
+// returned. If fv satisfies the StringUnmarshaler interface it will
+// execute the corresponding StringUnmarshaler.UnmarshalString method
+// on the value. st holds the tag of the field that fv belongs to and
+// may alter how val is parsed (e.g. a per-field time layout).
+// fv must be settable else this panics.
+func (f *fig) setValue(fv reflect.Value, val string, st structTag) error {
+	if ok, err := trySetFromStringUnmarshaler(fv, val); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+
+	if st.hex && isByteSeqType(fv.Type()) {
+		v, err := decodeHex(fv.Type(), val)
+		if err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if fv.IsNil() {
+			// allocate into a temporary so that fv is left nil if val fails to parse
+			pv := reflect.New(fv.Type().Elem())
+			if err := f.setValue(pv.Elem(), val, st); err != nil {
+				return err
+			}
+			fv.Set(pv)
+			return nil
+		}
+		return f.setValue(fv.Elem(), val, st)
+	case reflect.Slice:
+		if fv.Type() == rawMessageType {
+			if !json.Valid([]byte(val)) {
+				return fmt.Errorf("invalid json %q", val)
+			}
+			fv.SetBytes([]byte(val))
+			return nil
+		}
+		if err := f.setSlice(fv, val, st); err != nil {
+			return err
+		}
+	case reflect.Array:
+		// elements are set positionally so val must give every element
+		ss := stringSlice(val)
+		if len(ss) != fv.Len() {
+			return fmt.Errorf("%d values given for array of length %d", len(ss), fv.Len())
+		}
+		arr := reflect.New(fv.Type()).Elem()
+		for i, s := range ss {
+			if err := f.setValue(arr.Index(i), s, st); err != nil {
+				return err
+			}
+		}
+		fv.Set(arr)
+	case reflect.Map:
+		if !isSetType(fv.Type()) {
+			return fmt.Errorf("unsupported type %s", fv.Kind())
+		}
+		set := reflect.MakeMap(fv.Type())
+		empty := reflect.New(fv.Type().Elem()).Elem()
+		for _, s := range stringSlice(val) {
+			key := reflect.New(fv.Type().Key()).Elem()
+			if err := f.setValue(key, s, st); err != nil {
+				return err
+			}
+			set.SetMapIndex(key, empty)
+		}
+		fv.Set(set)
+	case reflect.Bool:
+		b, err := f.parseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// numbers never contain spaces, so any around them (e.g. "[1, 2]") are ignored
+		val = strings.TrimSpace(val)
+		if v, ok, err := timeNameValue(fv.Type(), val); ok {
+			if err != nil {
+				return err
+			}
+			fv.Set(v)
+		} else if _, ok := fv.Interface().(time.Duration); ok {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return err
+			}
+			fv.Set(reflect.ValueOf(d))
+		} else if st.bytes {
+			b, err := parseBytes(val)
+			if err != nil {
+				return err
+			}
+			if b > math.MaxInt64 || fv.OverflowInt(int64(b)) {
+				return fmt.Errorf("byte size %s overflows %s", val, fv.Type())
+			}
+			fv.SetInt(int64(b))
+		} else {
+			val, err := f.stripNumericSeps(val)
+			if err != nil {
+				return err
+			}
+			i, err := strconv.ParseInt(val, intBase(val), fv.Type().Bits())
+			if err != nil {
+				return err
+			}
+			fv.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val = strings.TrimSpace(val)
+		if st.bytes {
+			b, err := parseBytes(val)
+			if err != nil {
+				return err
+			}
+			if fv.OverflowUint(b) {
+				return fmt.Errorf("byte size %s overflows %s", val, fv.Type())
+			}
+			fv.SetUint(b)
+			return nil
+		}
+		val, err := f.stripNumericSeps(val)
+		if err != nil {
+			return err
+		}
+		i, err := strconv.ParseUint(val, intBase(val), fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		val, err := f.stripNumericSeps(strings.TrimSpace(val))
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.String:
+		if f.trimSpace {
+			val = strings.TrimSpace(val)
+		}
+		fv.SetString(val)
+	case reflect.Struct: // struct is only allowed a default in the special case where it's a time.Time
+		if _, ok := fv.Interface().(time.Time); ok {
+			layout := f.timeLayout
+			if st.timeLayout != "" {
+				layout = st.timeLayout
+			}
+			t, err := time.Parse(layout, val)
+			if err != nil {
+				return err
+			}
+			fv.Set(reflect.ValueOf(t))
+		} else if _, ok := fv.Interface().(regexp.Regexp); ok {
 			re, err := regexp.Compile(val)
 			if err != nil {
 				return err
 			}
 			fv.Set(reflect.ValueOf(*re))
+		} else if sv, ok, err := scanValue(fv.Type(), val); ok {
+			if err != nil {
+				return err
+			}
+			fv.Set(sv)
+		} else if strings.HasPrefix(strings.TrimSpace(val), "{") && fv.CanAddr() {
+			return f.setStructFromFlow(fv, val)
 		} else {
 			return fmt.Errorf("unsupported type %s", fv.Kind())
 		}
 	default:
-		return fmt.Errorf("unsupported type %s", fv.Kind())
+		if !fv.IsValid() {
+			return fmt.Errorf("unsupported type %s", fv.Kind())
+		}
+		sv, ok, err := scanValue(fv.Type(), val)
+		if !ok {
+			return fmt.Errorf("unsupported type %s", fv.Kind())
+		}
+		if err != nil {
+			return err
+		}
+		fv.Set(sv)
 	}
 	return nil
 }
 
+// stripNumericSeps returns val with the underscores that separate its
+// digits removed if numeric separators are allowed, otherwise it returns
+// val unchanged.
+func (f *fig) stripNumericSeps(val string) (string, error) {
+	if !f.numericSeps || !strings.Contains(val, "_") {
+		return val, nil
+	}
+	return removeDigitSeparators(val)
+}
+
+// intBase returns the base in which the integer val is parsed. That is 0
+// if val, after an optional sign, starts with the prefix of a hexadecimal,
+// octal or binary literal such as 0x1f, 0o755 or 0b1010, and 10 otherwise
+// so that leading zeros such as in 08080 are decimal. Values that contain
+// underscores, which base 0 would accept even if numeric separators are
+// not allowed, are always parsed in base 10.
+func intBase(val string) int {
+	s := val
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if len(s) < 2 || s[0] != '0' || !strings.ContainsRune("xXoObB", rune(s[1])) || strings.Contains(val, "_") {
+		return 10
+	}
+	return 0
+}
+
+// parseBool returns the boolean value represented by val. If bool
+// words are enabled then in addition to the values accepted by
+// strconv.ParseBool, val may be one of yes/no, on/off and
+// enabled/disabled in any case. If numeric bools are disallowed
+// then 0 and 1 are rejected.
+func (f *fig) parseBool(val string) (bool, error) {
+	if f.numericBool != nil && !*f.numericBool && (val == "0" || val == "1") {
+		return false, fmt.Errorf("invalid bool %q", val)
+	}
+	if f.boolWords {
+		switch strings.ToLower(val) {
+		case "yes", "on", "enabled":
+			return true, nil
+		case "no", "off", "disabled":
+			return false, nil
+		}
+	}
+	return strconv.ParseBool(val)
+}
+
 // setSlice val to sv. val should be a Go slice formatted as a string
 // (e.g. "[1,2]") and sv must be a slice value. if conversion of val
 // to a slice fails then an error is returned.
 // sv must be settable else this panics.
-func (f *fig) setSlice(sv reflect.Value, val string) error {
-	ss := stringSlice(val)
+func (f *fig) setSlice(sv reflect.Value, val string, st structTag) error {
+	return f.setSliceElems(sv, stringSlice(val), st)
+}
+
+// setSliceElems sets sv to a slice whose elements are each
+// converted from the corresponding string in ss.
+// sv must be settable else this panics.
+func (f *fig) setSliceElems(sv reflect.Value, ss []string, st structTag) error {
 	slice := reflect.MakeSlice(sv.Type(), len(ss), cap(ss))
 	for i, s := range ss {
-		if err := f.setValue(slice.Index(i), s); err != nil {
+		if err := f.setValue(slice.Index(i), s, st); err != nil {
 			return err
 		}
 	}
@@ -422,6 +2775,59 @@ func (f *fig) setSlice(sv reflect.Value, val string) error {
 	return nil
 }
 
+// unmarshalError is the error of a StringUnmarshaler that failed to
+// unmarshal val.
+type unmarshalError struct {
+	val string
+	err error
+}
+
+func (e *unmarshalError) Error() string {
+	return fmt.Sprintf("could not unmarshal string %q: %v", e.val, e.err)
+}
+
+func (e *unmarshalError) Unwrap() error {
+	return e.err
+}
+
+// decodeUnmarshalError is an unmarshalError that occurred while decoding
+// the config file into the value to, and was recorded because lenient
+// unmarshaling is enabled.
+type decodeUnmarshalError struct {
+	to reflect.Value
+	unmarshalError
+}
+
+// reportUnmarshalErrs passes the unmarshal errors recorded while decoding
+// into result to the callback registered with LenientUnmarshal, along with
+// the path of the field of result that each error occurred in. The path is
+// empty if the field cannot be found, as is the case for map entries.
+func (f *fig) reportUnmarshalErrs(result interface{}) {
+	if len(f.unmarshalErrs) == 0 {
+		return
+	}
+
+	type key struct {
+		addr uintptr
+		t    reflect.Type
+	}
+	paths := make(map[key]string)
+	for _, fd := range flattenCfg(result, f.tag) {
+		if fd.v.CanAddr() {
+			paths[key{fd.v.UnsafeAddr(), fd.v.Type()}] = fd.path()
+		}
+	}
+
+	for _, e := range f.unmarshalErrs {
+		var path string
+		if e.to.CanAddr() {
+			path = paths[key{e.to.UnsafeAddr(), e.to.Type()}]
+		}
+		f.onUnmarshalErr(path, e.val, e.err)
+	}
+	f.unmarshalErrs = nil
+}
+
 // trySetFromStringUnmarshaler takes a value fv which is expected to implement the
 // StringUnmarshaler interface and attempts to unmarshal the string val into the field.
 // If the value does not implement the interface, or an error occurs during the unmarshal,
@@ -431,9 +2837,9 @@ func trySetFromStringUnmarshaler(fv reflect.Value, val string) (bool, error) {
 	if fv.IsValid() && reflect.PointerTo(fv.Type()).Implements(reflect.TypeOf((*StringUnmarshaler)(nil)).Elem()) {
 		vi := reflect.New(fv.Type()).Interface()
 		if unmarshaler, ok := vi.(StringUnmarshaler); ok {
-			err := unmarshaler.UnmarshalString(val)
+			err := unmarshalString(unmarshaler, val)
 			if err != nil {
-				return false, fmt.Errorf("could not unmarshal string %q: %w", val, err)
+				return false, &unmarshalError{val: val, err: err}
 			}
 
 			fv.Set(reflect.ValueOf(vi).Elem())
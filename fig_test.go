@@ -1,14 +1,25 @@
 package fig
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -175,7 +186,7 @@ func validPodConfig() Pod {
 }
 
 func Test_fig_Load(t *testing.T) {
-	for _, f := range []string{"pod.yaml", "pod.json", "pod.toml"} {
+	for _, f := range []string{"pod.yaml", "pod.json", "pod.toml", "pod.yaml.gz", "pod.json.gz", "pod.toml.gz"} {
 		t.Run(f, func(t *testing.T) {
 			var cfg Pod
 			err := Load(&cfg, File(f), Dirs(filepath.Join("testdata", "valid")))
@@ -342,6 +353,81 @@ func Test_fig_Load_Defaults(t *testing.T) {
 	})
 }
 
+func Test_fig_Load_PointerDefaults(t *testing.T) {
+	type Config struct {
+		Timeout *time.Duration `fig:"timeout" default:"30s"`
+		Pattern *regexp.Regexp `fig:"pattern" default:"[a-z]+"`
+		Ratio   *float64       `fig:"ratio" default:"0.75"`
+		Build   *time.Time     `fig:"build" default:"2020-01-01T12:00:00Z"`
+	}
+
+	t.Run("nil pointers are allocated and set", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, IgnoreFile())
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if cfg.Timeout == nil || *cfg.Timeout != 30*time.Second {
+			t.Errorf("cfg.Timeout == %v, expected %v", cfg.Timeout, 30*time.Second)
+		}
+		if cfg.Pattern == nil || cfg.Pattern.String() != "[a-z]+" {
+			t.Errorf("cfg.Pattern == %v, expected %v", cfg.Pattern, "[a-z]+")
+		}
+		if cfg.Ratio == nil || *cfg.Ratio != 0.75 {
+			t.Errorf("cfg.Ratio == %v, expected %v", cfg.Ratio, 0.75)
+		}
+		if want := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC); cfg.Build == nil || !cfg.Build.Equal(want) {
+			t.Errorf("cfg.Build == %v, expected %v", cfg.Build, want)
+		}
+	})
+
+	t.Run("non-nil pointers are not overridden", func(t *testing.T) {
+		timeout := time.Minute
+		ratio := 0.5
+
+		var cfg Config
+		cfg.Timeout = &timeout
+		cfg.Ratio = &ratio
+		cfg.Pattern = regexp.MustCompile(".*")
+
+		err := Load(&cfg, IgnoreFile())
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if *cfg.Timeout != time.Minute {
+			t.Errorf("cfg.Timeout == %v, expected %v", *cfg.Timeout, time.Minute)
+		}
+		if *cfg.Ratio != 0.5 {
+			t.Errorf("cfg.Ratio == %v, expected %v", *cfg.Ratio, 0.5)
+		}
+		if cfg.Pattern.String() != ".*" {
+			t.Errorf("cfg.Pattern == %v, expected %v", cfg.Pattern, ".*")
+		}
+	})
+
+	t.Run("bad defaults leave pointers nil", func(t *testing.T) {
+		var cfg struct {
+			Timeout *time.Duration `fig:"timeout" default:"30 seconds"`
+			Pattern *regexp.Regexp `fig:"pattern" default:"[a-"`
+			Ratio   *float64       `fig:"ratio" default:"three quarters"`
+		}
+
+		err := Load(&cfg, IgnoreFile())
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+
+		if fieldErrs := err.(fieldErrors); len(fieldErrs) != 3 {
+			t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", 3, fieldErrs)
+		}
+		if cfg.Timeout != nil || cfg.Pattern != nil || cfg.Ratio != nil {
+			t.Errorf("expected nil pointers, got %v %v %v", cfg.Timeout, cfg.Pattern, cfg.Ratio)
+		}
+	})
+}
+
 func Test_fig_Load_RequiredAndDefaults(t *testing.T) {
 	for _, f := range []string{"server.yaml", "server.json", "server.toml"} {
 		t.Run(f, func(t *testing.T) {
@@ -398,23 +484,53 @@ func Test_fig_Load_UseStrict(t *testing.T) {
 				t.Fatalf("expected err")
 			}
 
-			want := []string{
-				"has invalid keys: logger",
-			}
-
-			fieldErrs := err.(*mapstructure.Error)
+			want := []string{"logger"}
 
-			if len(want) != len(fieldErrs.Errors) {
-				t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", len(want), fieldErrs)
+			var unknown *ErrUnknownKeys
+			if !errors.As(err, &unknown) {
+				t.Fatalf("expected err %T, got %T: %v", unknown, err, err)
 			}
 
-			for i, err := range fieldErrs.Errors {
-				if !strings.Contains(err, want[i]) {
-					t.Errorf("want %s in fieldErrs, got %+v", want[i], err)
-				}
+			if !reflect.DeepEqual(want, unknown.Keys) {
+				t.Errorf("want keys %+v, got %+v", want, unknown.Keys)
 			}
 		})
 	}
+
+	t.Run("nested keys", func(t *testing.T) {
+		fig := defaultFig()
+		fig.useStrict = true
+
+		m := map[string]interface{}{
+			"host": "0.0.0.0",
+			"tls":  true,
+			"logger": map[string]interface{}{
+				"log_level": "debug",
+				"format":    "json",
+			},
+		}
+
+		var cfg struct {
+			Host   string `fig:"host"`
+			Logger struct {
+				LogLevel string `fig:"log_level"`
+			} `fig:"logger"`
+		}
+
+		err := fig.decodeMap(m, &cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+
+		var unknown *ErrUnknownKeys
+		if !errors.As(err, &unknown) {
+			t.Fatalf("expected err %T, got %T: %v", unknown, err, err)
+		}
+
+		if want := []string{"logger.format", "tls"}; !reflect.DeepEqual(want, unknown.Keys) {
+			t.Errorf("want keys %+v, got %+v", want, unknown.Keys)
+		}
+	})
 }
 
 func Test_fig_Load_WithOptions(t *testing.T) {
@@ -536,760 +652,6071 @@ func Test_fig_Load_IgnoreFile(t *testing.T) {
 	}
 }
 
-func Test_fig_findCfgFile(t *testing.T) {
-	t.Run("finds existing file", func(t *testing.T) {
+func Test_fig_Load_PresenceAware(t *testing.T) {
+	type Server struct {
+		Port    int    `fig:"port" validate:"required"`
+		Debug   bool   `fig:"debug" validate:"required"`
+		Host    string `fig:"host" validate:"required"`
+		Timeout int    `fig:"timeout" validate:"required"`
+	}
+
+	m := map[string]interface{}{
+		"port": 0,
+		"host": nil,
+	}
+
+	t.Run("zero values satisfy required", func(t *testing.T) {
 		fig := defaultFig()
-		fig.filename = "pod.yaml"
-		fig.dirs = []string{".", "testdata", filepath.Join("testdata", "valid")}
+		fig.presenceAware = true
+		fig.useEnv = true
+		fig.envPrefix = "myapp"
 
-		file, err := fig.findCfgFile()
-		if err != nil {
+		os.Clearenv()
+		setenv(t, "MYAPP_DEBUG", "false")
+
+		var cfg Server
+		if err := fig.decodeMap(m, &cfg); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
 
-		want := filepath.Join("testdata", "valid", "pod.yaml")
-		if want != file {
-			t.Fatalf("want file %s, got %s", want, file)
+		err := fig.processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+
+		want := []string{"host", "timeout"}
+
+		fieldErrs := err.(fieldErrors)
+
+		if len(want) != len(fieldErrs) {
+			t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", len(want), fieldErrs)
+		}
+
+		for _, field := range want {
+			if _, ok := fieldErrs[field]; !ok {
+				t.Errorf("want %s in fieldErrs, got %+v", field, fieldErrs)
+			}
 		}
 	})
 
-	t.Run("non-existing file returns ErrFileNotFound", func(t *testing.T) {
+	t.Run("zero values fail required if disabled", func(t *testing.T) {
 		fig := defaultFig()
-		fig.filename = "nope.nope"
-		fig.dirs = []string{".", "testdata", filepath.Join("testdata", "valid")}
 
-		file, err := fig.findCfgFile()
+		var cfg Server
+		if err := fig.decodeMap(m, &cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		err := fig.processCfg(&cfg)
 		if err == nil {
-			t.Fatalf("expected err, got file %s", file)
+			t.Fatalf("expected err")
 		}
-		if !errors.Is(err, ErrFileNotFound) {
-			t.Errorf("expected err %v, got %v", ErrFileNotFound, err)
+
+		if fieldErrs := err.(fieldErrors); len(fieldErrs) != 4 {
+			t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", 4, fieldErrs)
+		}
+	})
+
+	t.Run("nested paths are tracked", func(t *testing.T) {
+		for _, f := range []string{"pod.yaml", "pod.json", "pod.toml"} {
+			t.Run(f, func(t *testing.T) {
+				var cfg Pod
+				err := Load(&cfg, File(f), Dirs(filepath.Join("testdata", "valid")), PresenceAware())
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+			})
 		}
 	})
 }
 
-func Test_fig_decodeFile(t *testing.T) {
-	fig := defaultFig()
+// toggle is an on/off flag whose zero value means unset, so that an
+// explicit off is not replaced by a default.
+type toggle uint8
 
-	for _, f := range []string{"bad.yaml", "bad.json", "bad.toml"} {
-		t.Run(f, func(t *testing.T) {
-			file := filepath.Join("testdata", "invalid", f)
-			if !fileExists(file) {
-				t.Fatalf("test file %s does not exist", file)
+const (
+	toggleUnset toggle = iota
+	toggleOff
+	toggleOn
+)
+
+func (tg *toggle) UnmarshalString(v string) error {
+	switch v {
+	case "on":
+		*tg = toggleOn
+	case "off":
+		*tg = toggleOff
+	default:
+		return fmt.Errorf("invalid toggle %q", v)
+	}
+	return nil
+}
+
+func Test_fig_Load_BoolDefaults(t *testing.T) {
+	type Config struct {
+		Color   bool `fig:"color" default:"true"`
+		Verbose bool `fig:"verbose" default:"true"`
+		Cache   bool `fig:"cache" default:"true"`
+		Debug   bool `fig:"debug" default:"false"`
+	}
+
+	for _, tc := range []struct {
+		Name    string
+		Options []Option
+	}{
+		{Name: "default"},
+		{Name: "presence-aware", Options: []Option{PresenceAware()}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			os.Clearenv()
+			setenv(t, "VERBOSE", "false")
+
+			options := append([]Option{Reader(strings.NewReader("color: false\n"), DecoderYaml), UseEnv("")}, tc.Options...)
+
+			var cfg Config
+			if err := Load(&cfg, options...); err != nil {
+				t.Fatalf("unexpected err: %v", err)
 			}
-			_, err := fig.decodeFile(file)
-			if err == nil {
-				t.Errorf("received nil error")
+
+			if want := (Config{Color: false, Verbose: false, Cache: true, Debug: false}); want != cfg {
+				t.Errorf("want %+v, got %+v", want, cfg)
 			}
 		})
 	}
 
-	t.Run("unsupported file extension", func(t *testing.T) {
-		file := filepath.Join("testdata", "invalid", "list.hcl")
-		if !fileExists(file) {
-			t.Fatalf("test file %s does not exist", file)
+	t.Run("bad default", func(t *testing.T) {
+		var cfg struct {
+			Color bool `fig:"color" default:"maybe"`
 		}
-		_, err := fig.decodeFile(file)
-		if err == nil {
-			t.Fatal("received nil error")
+		if err := Load(&cfg, IgnoreFile()); err == nil {
+			t.Fatalf("expected err")
 		}
-		if !strings.Contains(err.Error(), "unsupported") {
-			t.Errorf("err == %v, expected unsupported file extension", err)
+	})
+
+	t.Run("flag-like types", func(t *testing.T) {
+		type Flag bool
+		var cfg struct {
+			Named   Flag   `fig:"named" default:"true"`
+			Ptr     *bool  `fig:"ptr" default:"true"`
+			NilPtr  *bool  `fig:"nil_ptr" default:"true"`
+			Toggle  toggle `fig:"toggle" default:"on"`
+			Dormant toggle `fig:"dormant" default:"on"`
+		}
+
+		data := "named: false\nptr: false\ntoggle: off\n"
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if bool(cfg.Named) || cfg.Ptr == nil || *cfg.Ptr || cfg.NilPtr == nil || !*cfg.NilPtr {
+			t.Errorf("bool types: got %+v", cfg)
+		}
+		if cfg.Toggle != toggleOff || cfg.Dormant != toggleOn {
+			t.Errorf("toggles: got %v and %v", cfg.Toggle, cfg.Dormant)
 		}
 	})
 
-	t.Run("file does not exist", func(t *testing.T) {
-		_, err := fig.decodeFile("casperthefriendlygho.st")
-		if err == nil {
-			t.Fatal("received nil error")
+	t.Run("env false is honored without a file", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "MYAPP_COLOR", "false")
+
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), UseEnv("myapp")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Color || !cfg.Verbose {
+			t.Errorf("unexpected cfg: %+v", cfg)
 		}
 	})
 }
 
-func Test_fig_decodeMap(t *testing.T) {
-	fig := defaultFig()
-	fig.tag = "fig"
-
-	m := map[string]interface{}{
-		"log_level": "debug",
-		"severity":  "5",
-		"server": map[string]interface{}{
-			"ports":         []int{443, 80},
-			"secure":        1,
-			"listener_type": "tls",
-		},
+func Test_fig_Load_WithLogger(t *testing.T) {
+	type Config struct {
+		Kind     string `fig:"kind"`
+		Replicas int    `fig:"replicas" default:"3"`
+		Host     string `fig:"host"`
+		Token    string `fig:"token"`
 	}
 
-	var cfg struct {
-		Level    string `fig:"log_level"`
-		Severity int    `fig:"severity" validate:"required"`
-		Server   struct {
-			Ports    []string `fig:"ports" default:"[443]"`
-			Secure   bool
-			Listener ListenerType `fig:"listener_type" default:"unix"`
-		} `fig:"server"`
-	}
+	os.Clearenv()
+	setenv(t, "APP_HOST", "db")
+	setenv(t, "LEGACY_TOKEN", "secret")
 
-	if err := fig.decodeMap(m, &cfg); err != nil {
-		t.Fatalf("unexpected err: %v", err)
+	var msgs []string
+	logger := func(format string, args ...interface{}) {
+		msgs = append(msgs, fmt.Sprintf(format, args...))
 	}
 
-	if cfg.Level != "debug" {
-		t.Errorf("cfg.Level: want %s, got %s", "debug", cfg.Level)
+	var cfg Config
+	err := Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")), EnvPrefixes("app", "legacy"), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
 	}
 
-	if cfg.Severity != 5 {
-		t.Errorf("cfg.Severity: want %d, got %d", 5, cfg.Severity)
+	want := []string{
+		"loaded config file " + filepath.Join("testdata", "valid", "pod.yaml"),
+		"replicas: set to default value",
+		"host: found env APP_HOST",
+		"token: found env LEGACY_TOKEN using fallback prefix legacy",
 	}
-
-	if reflect.DeepEqual([]int{443, 80}, cfg.Server.Ports) {
-		t.Errorf("cfg.Server.Ports: want %+v, got %+v", []int{443, 80}, cfg.Server.Ports)
+	for _, w := range want {
+		found := false
+		for _, msg := range msgs {
+			found = found || msg == w
+		}
+		if !found {
+			t.Errorf("want message %q, got %q", w, msgs)
+		}
 	}
-
-	if cfg.Server.Secure == false {
-		t.Error("cfg.Server.Secure == false")
+	for _, msg := range msgs {
+		if strings.Contains(msg, "secret") {
+			t.Errorf("message contains env value: %q", msg)
+		}
 	}
 
-	if cfg.Server.Listener != ListenerTLS {
-		t.Errorf("cfg.Server.Listener: want: %d, got: %d", ListenerTLS, cfg.Server.Listener)
-	}
+	t.Run("no logger", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), UseEnv("app")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
 }
 
-func Test_fig_processCfg(t *testing.T) {
-	t.Run("slice elements set by env", func(t *testing.T) {
-		fig := defaultFig()
-		fig.tag = "fig"
-		fig.useEnv = true
+func Test_fig_Load_OnLoad(t *testing.T) {
+	type Server struct {
+		Host   string `fig:"host" default:"127.0.0.1"`
+		Ports  []int  `fig:"ports" default:"[80,443]"`
+		Logger struct {
+			LogLevel string `fig:"log_level"`
+			Tag      string `fig:"tag" validate:"required"`
+		} `fig:"logger"`
+	}
 
+	t.Run("called on success", func(t *testing.T) {
 		os.Clearenv()
-		setenv(t, "A_0_B", "b0")
-		setenv(t, "A_1_B", "b1")
-		setenv(t, "A_0_C", "9000")
+		setenv(t, "MYAPP_LOGGER_TAG", "main")
 
-		cfg := struct {
-			A []struct {
-				B string `validate:"required"`
-				C int    `default:"5"`
-			}
-		}{}
-		cfg.A = []struct {
-			B string `validate:"required"`
-			C int    `default:"5"`
-		}{{B: "boo"}, {B: "boo"}}
+		var infos []LoadInfo
 
-		err := fig.processCfg(&cfg)
+		var cfg Server
+		err := Load(&cfg,
+			File("server.yaml"),
+			Dirs(filepath.Join("testdata", "valid")),
+			UseEnv("myapp"),
+			OnLoad(func(info LoadInfo) { infos = append(infos, info) }),
+		)
 		if err != nil {
-			t.Fatalf("processCfg() returned unexpected error: %v", err)
-		}
-		if cfg.A[0].B != "b0" {
-			t.Errorf("cfg.A[0].B == %s, expected %s", cfg.A[0].B, "b0")
+			t.Fatalf("unexpected err: %v", err)
 		}
-		if cfg.A[1].B != "b1" {
-			t.Errorf("cfg.A[1].B == %s, expected %s", cfg.A[1].B, "b1")
+
+		want := LoadInfo{
+			File:     filepath.Join("testdata", "valid", "server.yaml"),
+			Defaults: []string{"ports"},
+			Env:      []string{"logger.tag"},
 		}
-		if cfg.A[0].C != 9000 {
-			t.Errorf("cfg.A[0].C == %d, expected %d", cfg.A[0].C, 9000)
+
+		if len(infos) != 1 {
+			t.Fatalf("OnLoad called %d times, expected 1", len(infos))
 		}
-		if cfg.A[1].C != 5 {
-			t.Errorf("cfg.A[1].C == %d, expected %d", cfg.A[1].C, 5)
+		if !reflect.DeepEqual(want, infos[0]) {
+			t.Errorf("\nwant %+v\ngot %+v", want, infos[0])
 		}
 	})
 
-	t.Run("embedded struct set by env", func(t *testing.T) {
-		fig := defaultFig()
-		fig.useEnv = true
-		fig.tag = "fig"
+	t.Run("not called on failure", func(t *testing.T) {
+		os.Clearenv()
 
-		type A struct {
-			B string
-		}
-		type C struct {
-			D *int
+		called := false
+
+		var cfg Server
+		err := Load(&cfg,
+			File("server.yaml"),
+			Dirs(filepath.Join("testdata", "valid")),
+			OnLoad(func(LoadInfo) { called = true }),
+		)
+		if err == nil {
+			t.Fatalf("expected err")
 		}
-		type F struct {
-			A
-			C `fig:"cc"`
+		if called {
+			t.Errorf("OnLoad called on failed load")
 		}
-		cfg := F{}
+	})
+}
 
+// logFormat is a StringEnum.
+type logFormat string
+
+func (l *logFormat) UnmarshalString(v string) error {
+	if !slices.Contains(l.Values(), v) {
+		return fmt.Errorf("unknown log format: %s", v)
+	}
+	*l = logFormat(v)
+	return nil
+}
+
+func (logFormat) Values() []string {
+	return []string{"text", "json"}
+}
+
+func Test_fig_Load_StringEnum(t *testing.T) {
+	t.Run("valid defaults", func(t *testing.T) {
+		var cfg struct {
+			Format  logFormat   `fig:"format" default:"json"`
+			Formats []logFormat `fig:"formats" default:"[text,json]"`
+		}
+		if err := Load(&cfg, IgnoreFile()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Format != "json" || !reflect.DeepEqual(cfg.Formats, []logFormat{"text", "json"}) {
+			t.Errorf("defaults not set, got %+v", cfg)
+		}
+	})
+
+	for _, tc := range []struct {
+		name    string
+		cfg     interface{}
+		options []Option
+	}{
+		{name: "scalar", cfg: &struct {
+			Format logFormat `fig:"format" default:"xml"`
+		}{}},
+		{name: "pointer", cfg: &struct {
+			Format *logFormat `fig:"format" default:"xml"`
+		}{}},
+		{name: "slice element", cfg: &struct {
+			Format []logFormat `fig:"format" default:"[json,xml]"`
+		}{}},
+		{name: "validated up front", cfg: &struct {
+			Format logFormat `fig:"format" default:"xml"`
+		}{Format: "text"}, options: []Option{ValidateDefaults()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Load(tc.cfg, append([]Option{IgnoreFile()}, tc.options...)...)
+			if err == nil || !strings.Contains(err.Error(), `format: `) || !strings.Contains(err.Error(), `"xml" is not one of [text json]`) {
+				t.Fatalf("want invalid default err, got %v", err)
+			}
+		})
+	}
+}
+
+func Test_fig_Load_Sets(t *testing.T) {
+	type Config struct {
+		Tags    map[string]struct{} `fig:"tags"`
+		Ports   map[int]struct{}    `fig:"ports" default:"[80,443]"`
+		Regions map[string]struct{} `fig:"regions" default:"[eu,us]"`
+		Users   map[string]struct{} `fig:"users"`
+	}
+
+	data := "tags: [a, b, a]\nports:\n  - 8080\n"
+
+	os.Clearenv()
+	setenv(t, "USERS", "[alice,bob]")
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{
+		Tags:    map[string]struct{}{"a": {}, "b": {}},
+		Ports:   map[int]struct{}{8080: {}},
+		Regions: map[string]struct{}{"eu": {}, "us": {}},
+		Users:   map[string]struct{}{"alice": {}, "bob": {}},
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	t.Run("dumped as a list", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Dump(&cfg, &buf, DecoderJSON); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("unable to unmarshal dump: %v", err)
+		}
+		if want := []interface{}{"a", "b"}; !reflect.DeepEqual(want, got["tags"]) {
+			t.Errorf("want tags dumped as %v, got %v", want, got["tags"])
+		}
+	})
+
+	t.Run("elements must be scalars", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("tags: [[a]]\n"), DecoderYaml))
+		if err == nil || !strings.Contains(err.Error(), "set elements must be scalars") {
+			t.Fatalf("expected scalar err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_LenientUnmarshal(t *testing.T) {
+	type Config struct {
+		Listener  ListenerType            `fig:"listener"`
+		Fallback  *ListenerType           `fig:"fallback"`
+		Backup    ListenerType            `fig:"backup" default:"quic"`
+		Upstream  ListenerType            `fig:"upstream" default:"tls"`
+		Listeners map[string]ListenerType `fig:"listeners"`
+	}
+
+	data := "listener: udp\nfallback: unix\nupstream: sctp\nlisteners:\n  a: tcp\n  b: dccp\n"
+
+	t.Run("strict by default", func(t *testing.T) {
+		os.Clearenv()
+
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml))
+		if err == nil || !strings.Contains(err.Error(), "unknown listener type: udp") {
+			t.Fatalf("expected unmarshal err, got %v", err)
+		}
+	})
+
+	t.Run("errors reported", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "FALLBACK", "ipx")
+
+		var got []string
+		var cfg Config
+		err := Load(&cfg,
+			Reader(strings.NewReader(data), DecoderYaml),
+			UseEnv(""),
+			LenientUnmarshal(func(field, val string, err error) {
+				got = append(got, fmt.Sprintf("%s=%s: %v", field, val, err))
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		sort.Strings(got)
+		want := []string{
+			"=dccp: unknown listener type: dccp",
+			"backup=quic: unknown listener type: quic",
+			"fallback=ipx: unknown listener type: ipx",
+			"listener=udp: unknown listener type: udp",
+			"upstream=sctp: unknown listener type: sctp",
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("\nwant %q\ngot  %q", want, got)
+		}
+
+		unix := ListenerUnix
+		wantCfg := Config{
+			Listener:  ListenerUnix,
+			Fallback:  &unix,
+			Backup:    ListenerUnix,
+			Upstream:  ListenerTLS,
+			Listeners: map[string]ListenerType{"a": ListenerTCP, "b": ListenerUnix},
+		}
+		if !reflect.DeepEqual(wantCfg, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", wantCfg, cfg)
+		}
+	})
+}
+
+func Test_fig_Load_RelPath(t *testing.T) {
+	type Config struct {
+		CertFile string   `fig:"cert_file,relpath"`
+		KeyFile  *string  `fig:"key_file,relpath"`
+		CAs      []string `fig:"cas,relpath"`
+		Abs      string   `fig:"abs,relpath"`
+		Env      string   `fig:"env,relpath"`
+		Default  string   `fig:"default,relpath" default:"data"`
+		Plain    string   `fig:"plain"`
+	}
+
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "abs.pem")
+	data := fmt.Sprintf("cert_file: certs/server.pem\nkey_file: server.key\ncas: [a.pem, %s]\nabs: %s\nenv: file.pem\nplain: rel\n", abs, abs)
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(data), 0o600); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+
+	os.Clearenv()
+	setenv(t, "ENV", "env.pem")
+
+	var cfg Config
+	if err := Load(&cfg, Dirs(dir), UseEnv("")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	keyFile := filepath.Join(dir, "server.key")
+	want := Config{
+		CertFile: filepath.Join(dir, "certs", "server.pem"),
+		KeyFile:  &keyFile,
+		CAs:      []string{filepath.Join(dir, "a.pem"), abs},
+		Abs:      abs,
+		Env:      "env.pem",
+		Default:  "data",
+		Plain:    "rel",
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	t.Run("reader is unaffected", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.CertFile != "certs/server.pem" {
+			t.Errorf("want cert_file left as is, got %q", cfg.CertFile)
+		}
+	})
+
+	t.Run("defaults file", func(t *testing.T) {
+		type Config struct {
+			CertFile string `fig:"cert_file,relpath"`
+			KeyFile  string `fig:"key_file,relpath"`
+			TLS      struct {
+				CA string `fig:"ca,relpath"`
+			} `fig:"tls"`
+		}
+
+		defaultsDir, cfgDir := t.TempDir(), t.TempDir()
+		defaults := filepath.Join(defaultsDir, "defaults.yaml")
+		if err := os.WriteFile(defaults, []byte("cert_file: default.pem\nkey_file: default.key\ntls:\n  ca: ca.pem\n"), 0o600); err != nil {
+			t.Fatalf("unable to write defaults: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(cfgDir, "config.yaml"), []byte("cert_file: server.pem\n"), 0o600); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+
+		var cfg Config
+		if err := Load(&cfg, Dirs(cfgDir), DefaultsFile(defaults)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := filepath.Join(cfgDir, "server.pem"); cfg.CertFile != want {
+			t.Errorf("want cert_file %q, got %q", want, cfg.CertFile)
+		}
+		if want := filepath.Join(defaultsDir, "default.key"); cfg.KeyFile != want {
+			t.Errorf("want key_file %q, got %q", want, cfg.KeyFile)
+		}
+		if want := filepath.Join(defaultsDir, "ca.pem"); cfg.TLS.CA != want {
+			t.Errorf("want tls.ca %q, got %q", want, cfg.TLS.CA)
+		}
+
+		t.Run("fs", func(t *testing.T) {
+			fsys := fstest.MapFS{"defaults.yaml": {Data: []byte("key_file: default.key\n")}}
+			var cfg Config
+			if err := Load(&cfg, Dirs(cfgDir), DefaultsFS(fsys, "defaults.yaml")); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if want := filepath.Join(cfgDir, "server.pem"); cfg.CertFile != want {
+				t.Errorf("want cert_file %q, got %q", want, cfg.CertFile)
+			}
+			if cfg.KeyFile != "default.key" {
+				t.Errorf("want key_file left as is, got %q", cfg.KeyFile)
+			}
+		})
+	})
+
+	t.Run("system then user", func(t *testing.T) {
+		systemDir, userDir := t.TempDir(), t.TempDir()
+		defer func(dir string) { systemConfigDir = dir }(systemConfigDir)
+		systemConfigDir = systemDir
+		t.Setenv("XDG_CONFIG_HOME", userDir)
+
+		for dir, data := range map[string]string{
+			systemDir: "cert_file: system.pem\nkey_file: system.key\n",
+			userDir:   "cert_file: user.pem\n",
+		} {
+			if err := os.MkdirAll(filepath.Join(dir, "myapp"), 0o700); err != nil {
+				t.Fatalf("unable to create dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "myapp", "config.yaml"), []byte(data), 0o600); err != nil {
+				t.Fatalf("unable to write config: %v", err)
+			}
+		}
+
+		var cfg Config
+		if err := Load(&cfg, SystemThenUser("myapp")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := filepath.Join(userDir, "myapp", "user.pem"); cfg.CertFile != want {
+			t.Errorf("want cert_file %q, got %q", want, cfg.CertFile)
+		}
+		if cfg.KeyFile == nil || *cfg.KeyFile != filepath.Join(systemDir, "myapp", "system.key") {
+			t.Errorf("want key_file in %s, got %v", systemDir, cfg.KeyFile)
+		}
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		var cfg struct {
+			Port int `fig:"port,relpath"`
+		}
+		if err := os.WriteFile(filepath.Join(dir, "port.yaml"), []byte("port: 80\n"), 0o600); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+		err := Load(&cfg, Dirs(dir), File("port.yaml"))
+		if err == nil || !strings.Contains(err.Error(), "relpath is not supported on type int") {
+			t.Fatalf("expected relpath err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_PostLoad(t *testing.T) {
+	type Config struct {
+		Host string `fig:"host" default:"LocalHost"`
+		Path string `fig:"path" default:"data"`
+	}
+
+	t.Run("mutates cfg", func(t *testing.T) {
+		var order []string
+
+		var cfg Config
+		err := Load(&cfg,
+			IgnoreFile(),
+			PostLoad(func(cfg interface{}) error {
+				order = append(order, "first")
+				c := cfg.(*Config)
+				c.Host = strings.ToLower(c.Host)
+				return nil
+			}),
+			OnLoad(func(LoadInfo) { order = append(order, "onload") }),
+			PostLoad(func(cfg interface{}) error {
+				order = append(order, "second")
+				cfg.(*Config).Path = filepath.Join("/srv", cfg.(*Config).Path)
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if want := (Config{Host: "localhost", Path: filepath.Join("/srv", "data")}); cfg != want {
+			t.Errorf("want %+v, got %+v", want, cfg)
+		}
+		if want := []string{"first", "second", "onload"}; !reflect.DeepEqual(want, order) {
+			t.Errorf("want callbacks called in order %v, got %v", want, order)
+		}
+	})
+
+	t.Run("error fails the load", func(t *testing.T) {
+		boom := errors.New("boom")
+		called := false
+
+		var cfg Config
+		err := Load(&cfg,
+			IgnoreFile(),
+			PostLoad(func(interface{}) error { return boom }),
+			PostLoad(func(interface{}) error { called = true; return nil }),
+			OnLoad(func(LoadInfo) { called = true }),
+		)
+		if !errors.Is(err, boom) {
+			t.Fatalf("want err %v, got %v", boom, err)
+		}
+		if called {
+			t.Errorf("callbacks called after PostLoad failed")
+		}
+	})
+
+	t.Run("each struct of LoadAll", func(t *testing.T) {
+		var seen []interface{}
+
+		var a, b Config
+		err := LoadAll([]interface{}{&a, &b},
+			IgnoreFile(),
+			PostLoad(func(cfg interface{}) error {
+				seen = append(seen, cfg)
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(seen) != 2 || seen[0] != &a || seen[1] != &b {
+			t.Errorf("want PostLoad called with &a then &b, got %v", seen)
+		}
+	})
+}
+
+func Test_fig_Load_TrimSpace(t *testing.T) {
+	type Config struct {
+		Host   string   `fig:"host"`
+		Name   string   `fig:"name"`
+		Level  string   `fig:"level" default:" info "`
+		Tags   []string `fig:"tags" default:"[ a , b ]"`
+		Labels []string `fig:"labels"`
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Trim bool
+		Want Config
+	}{
+		{
+			Name: "enabled",
+			Trim: true,
+			Want: Config{Host: "0.0.0.0", Name: "app", Level: "info", Tags: []string{"a", "b"}, Labels: []string{"x", "y"}},
+		},
+		{
+			Name: "disabled",
+			Trim: false,
+			Want: Config{Host: " 0.0.0.0\n", Name: "\tapp ", Level: " info ", Tags: []string{" a ", " b "}, Labels: []string{" x", "y "}},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			fig := defaultFig()
+			fig.trimSpace = tc.Trim
+			fig.useEnv = true
+
+			os.Clearenv()
+			setenv(t, "NAME", "\tapp ")
+
+			m := map[string]interface{}{
+				"host":   " 0.0.0.0\n",
+				"labels": []interface{}{" x", "y "},
+			}
+
+			var cfg Config
+			if err := fig.decodeMap(m, &cfg); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if err := fig.processCfg(&cfg); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if !reflect.DeepEqual(tc.Want, cfg) {
+				t.Errorf("\nwant %+q\ngot %+q", tc.Want, cfg)
+			}
+		})
+	}
+}
+
+func Test_fig_Load_NamedScalarTypes(t *testing.T) {
+	type Port int
+	type Level string
+	type Percentage float64
+	type Weight uint8
+
+	type Config struct {
+		Ports     []Port     `fig:"ports" default:"[80,443]"`
+		Level     Level      `fig:"level" default:"info"`
+		Threshold Percentage `fig:"threshold" default:"0.75"`
+		Weights   []Weight   `fig:"weights"`
+		Admin     *Port      `fig:"admin" default:"9000"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_LEVEL", "debug")
+	setenv(t, "APP_WEIGHTS", "[1,2,3]")
+
+	var cfg Config
+	err := Load(&cfg, IgnoreFile(), UseEnv("app"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	admin := Port(9000)
+	want := Config{
+		Ports:     []Port{80, 443},
+		Level:     "debug",
+		Threshold: 0.75,
+		Weights:   []Weight{1, 2, 3},
+		Admin:     &admin,
+	}
+
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot %+v", want, cfg)
+	}
+}
+
+func Test_fig_Load_NoFileDescriptorLeak(t *testing.T) {
+	countFDs := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skipf("unable to count open file descriptors: %v", err)
+		}
+		return len(entries)
+	}
+
+	before := countFDs()
+
+	for i := 0; i < 500; i++ {
+		var pod Pod
+		_ = Load(&pod, File("pod.yaml.gz"), Dirs(filepath.Join("testdata", "valid")))
+
+		for _, f := range []string{"bad.yaml", "bad.json", "bad.toml", "bad.yaml.gz", "list.hcl"} {
+			var cfg struct{}
+			if err := Load(&cfg, File(f), Dirs(".", "testdata", filepath.Join("testdata", "invalid"))); err == nil {
+				t.Fatalf("expected err loading %s", f)
+			}
+		}
+	}
+
+	if after := countFDs(); after > before {
+		t.Fatalf("open file descriptors grew from %d to %d", before, after)
+	}
+}
+
+func Test_fig_Load_URL(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir(filepath.Join("testdata", "valid"))))
+	defer srv.Close()
+
+	for _, f := range []string{"pod.yaml", "pod.json", "pod.toml"} {
+		t.Run(f, func(t *testing.T) {
+			var cfg Pod
+			err := Load(&cfg, URL(srv.URL+"/"+f+"?version=2"), HTTPClient(srv.Client()))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			want := validPodConfig()
+
+			if !reflect.DeepEqual(want, cfg) {
+				t.Errorf("\nwant %+v\ngot %+v", want, cfg)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		var cfg Pod
+		err := Load(&cfg, URL(srv.URL+"/nope.yaml"))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if !strings.Contains(err.Error(), "404") {
+			t.Errorf("expected status in err, got %v", err)
+		}
+	})
+
+	t.Run("network error", func(t *testing.T) {
+		client := &http.Client{Timeout: time.Millisecond}
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+		}))
+		defer slow.Close()
+
+		var cfg Pod
+		err := Load(&cfg, URL(slow.URL+"/pod.yaml"), HTTPClient(client))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if !strings.Contains(err.Error(), "unable to fetch config") {
+			t.Errorf("expected fetch err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_Reader(t *testing.T) {
+	type Pod struct {
+		Kind     string `fig:"kind"`
+		Metadata struct {
+			Name string `fig:"name" validate:"required"`
+		} `fig:"metadata"`
+	}
+
+	open := func(t *testing.T, name string) io.Reader {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join("testdata", "valid", name))
+		if err != nil {
+			t.Fatalf("unable to read %s: %v", name, err)
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, tc := range []struct {
+		File    string
+		Decoder Decoder
+	}{
+		{File: "pod.yaml", Decoder: DecoderYaml},
+		{File: "pod.json", Decoder: DecoderJSON},
+		{File: "pod.toml", Decoder: DecoderToml},
+	} {
+		t.Run(tc.File, func(t *testing.T) {
+			var cfg Pod
+			if err := Load(&cfg, Reader(open(t, tc.File), tc.Decoder)); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if cfg.Kind != "Pod" || cfg.Metadata.Name != "redis" {
+				t.Errorf("unexpected cfg: %+v", cfg)
+			}
+		})
+	}
+
+	for _, name := range []string{"pod.yaml", "pod.json", "pod.toml", "pod.yaml.gz", "pod.toml.gz"} {
+		t.Run("named "+name, func(t *testing.T) {
+			var (
+				cfg  Pod
+				info LoadInfo
+			)
+			onLoad := OnLoad(func(i LoadInfo) { info = i })
+			if err := Load(&cfg, ReaderNamed(open(t, name), "uploads/"+name), onLoad); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if cfg.Kind != "Pod" || cfg.Metadata.Name != "redis" {
+				t.Errorf("unexpected cfg: %+v", cfg)
+			}
+			if info.File != "uploads/"+name {
+				t.Errorf("info.File == %s, expected %s", info.File, "uploads/"+name)
+			}
+		})
+	}
+
+	t.Run("reader takes precedence over file", func(t *testing.T) {
+		var cfg Pod
+		err := Load(&cfg, File("nope.yaml"), Reader(strings.NewReader("kind: Pod\nmetadata:\n  name: x\n"), DecoderYaml))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+
+	t.Run("unknown extension", func(t *testing.T) {
+		var cfg Pod
+		err := Load(&cfg, ReaderNamed(strings.NewReader("kind = Pod"), "config.ini"))
+		if err == nil || !strings.Contains(err.Error(), "unsupported file extension .ini") {
+			t.Fatalf("expected unsupported extension err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_LoadAll(t *testing.T) {
+	type Meta struct {
+		Kind     string `fig:"kind"`
+		Metadata struct {
+			Name   string `fig:"name" validate:"required"`
+			Master bool   `fig:"master"`
+		} `fig:"metadata"`
+	}
+
+	type Spec struct {
+		Kind string `fig:"kind" default:"Deployment"`
+		Spec struct {
+			Containers []struct {
+				Name  string `fig:"name"`
+				Image string `fig:"image"`
+			} `fig:"containers"`
+		} `fig:"spec"`
+		Replicas int `fig:"replicas" default:"3"`
+	}
+
+	dirs := Dirs(filepath.Join("testdata", "valid"))
+
+	t.Run("loads each struct", func(t *testing.T) {
+		var (
+			meta  Meta
+			spec  Spec
+			files []string
+		)
+
+		onLoad := OnLoad(func(info LoadInfo) {
+			files = append(files, info.File)
+		})
+
+		err := LoadAll([]interface{}{&meta, &spec}, File("pod.yaml"), dirs, onLoad)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if meta.Kind != "Pod" || meta.Metadata.Name != "redis" || !meta.Metadata.Master {
+			t.Errorf("unexpected meta: %+v", meta)
+		}
+		if spec.Kind != "Pod" || spec.Replicas != 3 {
+			t.Errorf("unexpected spec: %+v", spec)
+		}
+		if len(spec.Spec.Containers) != 1 || spec.Spec.Containers[0].Image != "redis:5.0.4" {
+			t.Errorf("unexpected spec containers: %+v", spec.Spec.Containers)
+		}
+
+		want := []string{filepath.Join("testdata", "valid", "pod.yaml"), filepath.Join("testdata", "valid", "pod.yaml")}
+		if !reflect.DeepEqual(want, files) {
+			t.Errorf("want OnLoad files %+v, got %+v", want, files)
+		}
+	})
+
+	t.Run("structs do not share decoded values", func(t *testing.T) {
+		type Date struct {
+			When time.Time `fig:"when" timelayout:"2006-01-02"`
+		}
+		type Raw struct {
+			When string `fig:"when"`
+		}
+
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("when: \"2020-12-25\"\n"), 0o600); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+
+		var (
+			date Date
+			raw  Raw
+		)
+		if err := LoadAll([]interface{}{&date, &raw}, Dirs(dir)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if want := time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC); !date.When.Equal(want) {
+			t.Errorf("date.When == %v, expected %v", date.When, want)
+		}
+		if raw.When != "2020-12-25" {
+			t.Errorf("raw.When == %q, expected %q", raw.When, "2020-12-25")
+		}
+	})
+
+	t.Run("strict reports keys unknown to all structs", func(t *testing.T) {
+		type Metadata struct {
+			Metadata struct {
+				Name string `fig:"name"`
+			} `fig:"metadata"`
+		}
+		type Other struct {
+			APIVersion string `fig:"apiVersion"`
+			Kind       string `fig:"kind"`
+			Spec       map[string]interface{}
+		}
+
+		var (
+			meta  Metadata
+			other Other
+		)
+		err := LoadAll([]interface{}{&meta, &other}, File("pod.yaml"), dirs, UseStrict())
+
+		var unknown *ErrUnknownKeys
+		if !errors.As(err, &unknown) {
+			t.Fatalf("expected err %T, got %v", unknown, err)
+		}
+		if want := []string{"metadata.master"}; !reflect.DeepEqual(want, unknown.Keys) {
+			t.Errorf("want keys %+v, got %+v", want, unknown.Keys)
+		}
+	})
+
+	t.Run("error is prefixed with struct type", func(t *testing.T) {
+		var (
+			meta Meta
+			req  struct {
+				Host string `fig:"host" validate:"required"`
+			}
+		)
+		err := LoadAll([]interface{}{&meta, &req}, File("pod.yaml"), dirs)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if !strings.Contains(err.Error(), "struct") || !strings.Contains(err.Error(), "host") {
+			t.Errorf("unexpected err: %v", err)
+		}
+		if meta.Metadata.Name != "redis" {
+			t.Errorf("expected first struct to be loaded, got %+v", meta)
+		}
+	})
+
+	t.Run("non struct pointer", func(t *testing.T) {
+		var meta Meta
+		if err := LoadAll([]interface{}{&meta, meta}, IgnoreFile()); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_NonStringMapKeys(t *testing.T) {
+	type Config struct {
+		Ports    map[int]string          `fig:"ports"`
+		Flags    map[bool]string         `fig:"flags"`
+		Holidays map[int]time.Time       `fig:"holidays" timelayout:"2006-01-02"`
+		Limits   map[uint8]int64         `fig:"limits"`
+		Raw      map[int]json.RawMessage `fig:"raw"`
+	}
+
+	data := `
+ports:
+  80: http
+  443: https
+flags:
+  true: on
+  false: off
+holidays:
+  2024: "2024-12-25"
+limits:
+  1: 10
+raw:
+  1:
+    2: x
+`
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseStrict()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if want := map[int]string{80: "http", 443: "https"}; !reflect.DeepEqual(want, cfg.Ports) {
+		t.Errorf("cfg.Ports == %+v, expected %+v", cfg.Ports, want)
+	}
+	if want := map[bool]string{true: "on", false: "off"}; !reflect.DeepEqual(want, cfg.Flags) {
+		t.Errorf("cfg.Flags == %+v, expected %+v", cfg.Flags, want)
+	}
+	if want := time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC); !cfg.Holidays[2024].Equal(want) {
+		t.Errorf("cfg.Holidays == %+v, expected %v at 2024", cfg.Holidays, want)
+	}
+	if want := map[uint8]int64{1: 10}; !reflect.DeepEqual(want, cfg.Limits) {
+		t.Errorf("cfg.Limits == %+v, expected %+v", cfg.Limits, want)
+	}
+	if want := `{"2":"x"}`; string(cfg.Raw[1]) != want {
+		t.Errorf("cfg.Raw[1] == %s, expected %s", cfg.Raw[1], want)
+	}
+
+	t.Run("invalid key", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("ports:\n  http: 80\n"), DecoderYaml))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_NativeTimes(t *testing.T) {
+	type Config struct {
+		Offset    time.Time `fig:"offset"`
+		Local     time.Time `fig:"local"`
+		Date      time.Time `fig:"date"`
+		Clock     time.Time `fig:"clock"`
+		Custom    time.Time `fig:"custom"`
+		Formatted string    `fig:"formatted"`
+		Day       string    `fig:"day"`
+	}
+
+	data := `
+offset = 1979-05-27T07:32:00-07:00
+local = 1979-05-27T07:32:00
+date = 1979-05-27
+clock = 07:32:00
+custom = "27/05/1979"
+formatted = 1979-05-27T07:32:00Z
+day = 1979-05-27
+`
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderToml), TimeLayout("02/01/2006")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{
+		Offset:    time.Date(1979, 5, 27, 7, 32, 0, 0, time.FixedZone("", -7*60*60)),
+		Local:     time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+		Date:      time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC),
+		Clock:     time.Date(0, 1, 1, 7, 32, 0, 0, time.UTC),
+		Custom:    time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC),
+		Formatted: "27/05/1979",
+		Day:       "1979-05-27",
+	}
+
+	for _, tc := range []struct {
+		Name      string
+		Want, Got time.Time
+	}{
+		{"offset", want.Offset, cfg.Offset},
+		{"local", want.Local, cfg.Local},
+		{"date", want.Date, cfg.Date},
+		{"clock", want.Clock, cfg.Clock},
+		{"custom", want.Custom, cfg.Custom},
+	} {
+		if !tc.Want.Equal(tc.Got) {
+			t.Errorf("%s: want %v, got %v", tc.Name, tc.Want, tc.Got)
+		}
+	}
+	if want.Formatted != cfg.Formatted {
+		t.Errorf("formatted: want %q, got %q", want.Formatted, cfg.Formatted)
+	}
+	if want.Day != cfg.Day {
+		t.Errorf("day: want %q, got %q", want.Day, cfg.Day)
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		var cfg struct {
+			Native time.Time `fig:"native"`
+			Custom time.Time `fig:"custom"`
+			Day    string    `fig:"day"`
+		}
+		data := "native: 1979-05-27T07:32:00Z\ncustom: 27/05/1979\nday: 1979-05-27\n"
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), TimeLayout("02/01/2006")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC); !want.Equal(cfg.Native) {
+			t.Errorf("native: want %v, got %v", want, cfg.Native)
+		}
+		if want := time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC); !want.Equal(cfg.Custom) {
+			t.Errorf("custom: want %v, got %v", want, cfg.Custom)
+		}
+		if cfg.Day != "27/05/1979" {
+			t.Errorf("day: want %q, got %q", "27/05/1979", cfg.Day)
+		}
+	})
+}
+
+func Test_fig_Load_TimeLayoutFromKey(t *testing.T) {
+	type Config struct {
+		Release  time.Time   `fig:"release"`
+		Holidays []time.Time `fig:"holidays"`
+		Built    time.Time   `fig:"built" default:"2020-01-02"`
+		Stamp    time.Time   `fig:"stamp" timelayout:"2006"`
+	}
+
+	data := `
+_time_layout: "2006-01-02"
+release: "2024-03-01"
+holidays: ["2024-12-25", "2024-12-26"]
+stamp: "2019"
+`
+
+	var cfg Config
+	err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), TimeLayoutFromKey("_time_layout"), UseStrict())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	want := Config{
+		Release:  date(2024, 3, 1),
+		Holidays: []time.Time{date(2024, 12, 25), date(2024, 12, 26)},
+		Built:    date(2020, 1, 2),
+		Stamp:    date(2019, 1, 1),
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	t.Run("missing key uses layout", func(t *testing.T) {
+		var cfg struct {
+			Release time.Time `fig:"release"`
+		}
+		err := Load(&cfg, Reader(strings.NewReader(`release: "2024-03-01T10:00:00Z"`), DecoderYaml), TimeLayoutFromKey("_time_layout"))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !cfg.Release.Equal(want) {
+			t.Errorf("cfg.Release == %v, expected %v", cfg.Release, want)
+		}
+	})
+
+	t.Run("non-string layout", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("_time_layout: 2006\n"), DecoderYaml), TimeLayoutFromKey("_time_layout"))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_Subtree(t *testing.T) {
+	type Metadata struct {
+		Name string `fig:"name" validate:"required"`
+	}
+
+	for _, f := range []string{"pod.yaml", "pod.json", "pod.toml"} {
+		t.Run(f, func(t *testing.T) {
+			var cfg Metadata
+			err := Load(&cfg, File(f), Dirs(filepath.Join("testdata", "valid")), Subtree("metadata"), UseStrict())
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+
+			var unknown *ErrUnknownKeys
+			if !errors.As(err, &unknown) {
+				t.Fatalf("expected err %T, got %T: %v", unknown, err, err)
+			}
+			if want := []string{"master"}; !reflect.DeepEqual(want, unknown.Keys) {
+				t.Errorf("want keys %+v, got %+v", want, unknown.Keys)
+			}
+
+			cfg = Metadata{}
+			err = Load(&cfg, File(f), Dirs(filepath.Join("testdata", "valid")), Subtree("metadata"))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			want := Metadata{Name: "redis"}
+			if !reflect.DeepEqual(want, cfg) {
+				t.Errorf("\nwant %+v\ngot %+v", want, cfg)
+			}
+		})
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		fig := defaultFig()
+		fig.subtree = "services.Database"
+
+		m := map[string]interface{}{
+			"services": map[string]interface{}{
+				"database": map[string]interface{}{"host": "db"},
+			},
+		}
+
+		got, err := fig.selectSubtree(m)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := map[string]interface{}{"host": "db"}; !reflect.DeepEqual(want, got) {
+			t.Errorf("want %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		var cfg Metadata
+		err := Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")), Subtree("metadata.labels"))
+		if !errors.Is(err, ErrSubtreeNotFound) {
+			t.Fatalf("expected err %v, got %v", ErrSubtreeNotFound, err)
+		}
+	})
+
+	t.Run("allow missing", func(t *testing.T) {
+		var cfg struct {
+			App string `fig:"app" default:"redis"`
+		}
+		err := Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")), Subtree("metadata.labels"), AllowMissingSubtree())
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.App != "redis" {
+			t.Errorf("cfg.App == %s, expected %s", cfg.App, "redis")
+		}
+	})
+
+	t.Run("not a map", func(t *testing.T) {
+		var cfg Metadata
+		err := Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")), Subtree("kind"))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_MaxFileSize(t *testing.T) {
+	info, err := os.Stat(filepath.Join("testdata", "valid", "pod.yaml"))
+	if err != nil {
+		t.Fatalf("unable to stat file: %v", err)
+	}
+	size := info.Size()
+
+	for _, tc := range []struct {
+		Name    string
+		File    string
+		Max     int64
+		WantErr bool
+	}{
+		{Name: "unlimited", File: "pod.yaml"},
+		{Name: "exactly at limit", File: "pod.yaml", Max: size},
+		{Name: "over limit", File: "pod.yaml", Max: size - 1, WantErr: true},
+		{Name: "much smaller limit", File: "pod.json", Max: 16, WantErr: true},
+		{Name: "decompressed size is limited", File: "pod.yaml.gz", Max: size - 1, WantErr: true},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			var cfg Pod
+			err := Load(&cfg, File(tc.File), Dirs(filepath.Join("testdata", "valid")), MaxFileSize(tc.Max))
+			if tc.WantErr {
+				if err == nil || !strings.Contains(err.Error(), "exceeds the maximum size") {
+					t.Fatalf("expected size err, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+
+	t.Run("reader", func(t *testing.T) {
+		var cfg struct {
+			Name string `fig:"name"`
+		}
+		err := Load(&cfg, Reader(strings.NewReader(`{"name": "a very long name"}`), DecoderJSON), MaxFileSize(10))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_DefaultsFile(t *testing.T) {
+	type Config struct {
+		Level  string `fig:"level"`
+		Server struct {
+			Host string `fig:"host"`
+			Port int    `fig:"port" default:"8080"`
+			TLS  bool   `fig:"tls"`
+		} `fig:"server"`
+		Peers []string `fig:"peers"`
+	}
+
+	dir := t.TempDir()
+	defaults := filepath.Join(dir, "defaults.yaml")
+	if err := os.WriteFile(defaults, []byte("level: info\nserver:\n  host: 0.0.0.0\n  tls: true\npeers: [a, b]\n"), 0o600); err != nil {
+		t.Fatalf("unable to write defaults: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("server:\n  host: example.com\npeers: [c]\n"), 0o600); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+
+	t.Run("config merged over defaults", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Dirs(dir), DefaultsFile(defaults)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var want Config
+		want.Level = "info"
+		want.Server.Host = "example.com"
+		want.Server.Port = 8080
+		want.Server.TLS = true
+		want.Peers = []string{"c"}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+		}
+	})
+
+	t.Run("loaded with IgnoreFile", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), DefaultsFile(defaults)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Server.Host != "0.0.0.0" || cfg.Level != "info" {
+			t.Errorf("want values of the defaults file, got %+v", cfg)
+		}
+	})
+
+	t.Run("null defaults file", func(t *testing.T) {
+		for name, data := range map[string]string{"null.yaml": "null\n", "tilde.yaml": "~\n", "null.json": "null"} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatalf("unable to write defaults: %v", err)
+			}
+
+			var cfg Config
+			if err := Load(&cfg, Dirs(dir), DefaultsFile(path)); err != nil {
+				t.Fatalf("%s: unexpected err: %v", name, err)
+			}
+			if cfg.Server.Host != "example.com" || cfg.Server.Port != 8080 {
+				t.Errorf("%s: want values of the config, got %+v", name, cfg)
+			}
+		}
+	})
+
+	t.Run("missing defaults file", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Dirs(dir), DefaultsFile(filepath.Join(dir, "nope.yaml")))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected not exist err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_DefaultsFS(t *testing.T) {
+	type Config struct {
+		Level string `fig:"level"`
+		Port  int    `fig:"port"`
+		Debug bool   `fig:"debug"`
+	}
+
+	fsys := fstest.MapFS{
+		"defaults/defaults.yaml": {Data: []byte("level: info\nport: 8080\n")},
+		"defaults.toml":          {Data: []byte("level = \"warn\"\n")},
+		"defaults.ini":           {Data: []byte("level=info\n")},
+	}
+
+	t.Run("config merged over defaults", func(t *testing.T) {
+		var cfg Config
+		data := "port: 9090\ndebug: true\n"
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), DefaultsFS(fsys, "defaults/defaults.yaml")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := (Config{Level: "info", Port: 9090, Debug: true}); want != cfg {
+			t.Errorf("want %+v, got %+v", want, cfg)
+		}
+	})
+
+	t.Run("decoder picked by extension", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), DefaultsFS(fsys, "defaults.toml")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Level != "warn" {
+			t.Errorf("want level warn, got %+v", cfg)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), DefaultsFS(fsys, "defaults.ini")); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("missing defaults file", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, IgnoreFile(), DefaultsFS(fsys, "nope.yaml"))
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected not exist err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_SystemThenUser(t *testing.T) {
+	type Config struct {
+		Level  string   `fig:"level" default:"info"`
+		Port   int      `fig:"port" default:"80"`
+		Peers  []string `fig:"peers"`
+		Server struct {
+			Host string `fig:"host"`
+			TLS  bool   `fig:"tls"`
+		} `fig:"server"`
+	}
+
+	systemDir, userDir := t.TempDir(), t.TempDir()
+	defer func(dir string) { systemConfigDir = dir }(systemConfigDir)
+	systemConfigDir = systemDir
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+
+	write := func(t *testing.T, dir, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, "myapp", "config.yaml")
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("unable to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+		return path
+	}
+
+	t.Run("neither file", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, SystemThenUser("myapp")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Level != "info" || cfg.Port != 80 {
+			t.Errorf("unexpected cfg: %+v", cfg)
+		}
+	})
+
+	t.Run("neither file but required", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, SystemThenUser("myapp"), RequireFile())
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Fatalf("want ErrFileNotFound, got %v", err)
+		}
+	})
+
+	systemFile := write(t, systemDir, "level: warn\nport: 8080\npeers: [a, b]\nserver:\n  host: example.com\n  tls: true\n")
+
+	t.Run("system only", func(t *testing.T) {
+		var (
+			cfg  Config
+			info LoadInfo
+		)
+		err := Load(&cfg, SystemThenUser("myapp"), OnLoad(func(i LoadInfo) { info = i }))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Level != "warn" || cfg.Port != 8080 || cfg.Server.Host != "example.com" {
+			t.Errorf("unexpected cfg: %+v", cfg)
+		}
+		if info.File != systemFile {
+			t.Errorf("want file %s, got %s", systemFile, info.File)
+		}
+	})
+
+	t.Run("system only but required", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, SystemThenUser("myapp"), RequireFile()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Level != "warn" {
+			t.Errorf("unexpected cfg: %+v", cfg)
+		}
+	})
+
+	userFile := write(t, userDir, "level: debug\npeers: [c]\nserver:\n  host: localhost\n")
+
+	t.Run("user over system", func(t *testing.T) {
+		var (
+			cfg  Config
+			info LoadInfo
+		)
+		err := Load(&cfg, SystemThenUser("myapp"), OnLoad(func(i LoadInfo) { info = i }))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var want Config
+		want.Level = "debug"
+		want.Port = 8080
+		want.Peers = []string{"c"}
+		want.Server.Host = "localhost"
+		want.Server.TLS = true
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+		}
+		if info.File != userFile {
+			t.Errorf("want file %s, got %s", userFile, info.File)
+		}
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		write(t, userDir, "level: [")
+		var cfg Config
+		if err := Load(&cfg, SystemThenUser("myapp")); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_SkipInvalidFiles(t *testing.T) {
+	type Config struct {
+		Kind string `fig:"kind" validate:"required"`
+	}
+
+	type skipped struct {
+		path string
+		err  error
+	}
+
+	t.Run("continues to next dir", func(t *testing.T) {
+		var (
+			cfg  Config
+			skip []skipped
+		)
+		err := Load(&cfg,
+			File("bad.yaml"),
+			Dirs(filepath.Join("testdata", "invalid"), t.TempDir(), filepath.Join("testdata", "valid")),
+			SkipInvalidFiles(func(path string, err error) { skip = append(skip, skipped{path, err}) }),
+		)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if !strings.Contains(err.Error(), "no valid file found") {
+			t.Errorf("unexpected err: %v", err)
+		}
+		if len(skip) != 1 || skip[0].path != filepath.Join("testdata", "invalid", "bad.yaml") || skip[0].err == nil {
+			t.Errorf("unexpected skipped files: %+v", skip)
+		}
+
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("kind: Pod\n"), 0o600); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+
+		skip = nil
+		err = Load(&cfg,
+			File("bad.yaml"),
+			Dirs(filepath.Join("testdata", "invalid"), dir),
+			SkipInvalidFiles(func(path string, err error) { skip = append(skip, skipped{path, err}) }),
+		)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Kind != "Pod" {
+			t.Errorf("cfg.Kind == %s, expected %s", cfg.Kind, "Pod")
+		}
+		if len(skip) != 1 {
+			t.Errorf("unexpected skipped files: %+v", skip)
+		}
+	})
+
+	t.Run("no file found", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, File("nope.yaml"), SkipInvalidFiles(func(string, error) {}))
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Fatalf("expected err %v, got %v", ErrFileNotFound, err)
+		}
+	})
+
+	t.Run("fails without option", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, File("bad.yaml"), Dirs(filepath.Join("testdata", "invalid"), filepath.Join("testdata", "valid")))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_findCfgFile(t *testing.T) {
+	t.Run("finds existing file", func(t *testing.T) {
+		fig := defaultFig()
+		fig.filename = "pod.yaml"
+		fig.dirs = []string{".", "testdata", filepath.Join("testdata", "valid")}
+
+		file, err := fig.findCfgFile()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		want := filepath.Join("testdata", "valid", "pod.yaml")
+		if want != file {
+			t.Fatalf("want file %s, got %s", want, file)
+		}
+	})
+
+	t.Run("non-existing file returns ErrFileNotFound", func(t *testing.T) {
+		fig := defaultFig()
+		fig.filename = "nope.nope"
+		fig.dirs = []string{".", "testdata", filepath.Join("testdata", "valid")}
+
+		file, err := fig.findCfgFile()
+		if err == nil {
+			t.Fatalf("expected err, got file %s", file)
+		}
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("expected err %v, got %v", ErrFileNotFound, err)
+		}
+	})
+
+	t.Run("tries filenames in order of priority", func(t *testing.T) {
+		fig := defaultFig()
+		fig.filenames = []string{"nope.yaml", "server.yaml", "pod.yaml"}
+		fig.dirs = []string{".", "testdata", filepath.Join("testdata", "valid")}
+
+		file, err := fig.findCfgFile()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		want := filepath.Join("testdata", "valid", "server.yaml")
+		if want != file {
+			t.Fatalf("want file %s, got %s", want, file)
+		}
+	})
+
+	t.Run("no filename found returns ErrFileNotFound", func(t *testing.T) {
+		fig := defaultFig()
+		fig.filenames = []string{"nope.yaml", "nope.json"}
+		fig.dirs = []string{"testdata"}
+
+		_, err := fig.findCfgFile()
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Fatalf("expected err %v, got %v", ErrFileNotFound, err)
+		}
+		if want := "nope.yaml, nope.json: file not found"; err.Error() != want {
+			t.Errorf("want err %q, got %q", want, err.Error())
+		}
+	})
+}
+
+func Test_fig_decodeFile(t *testing.T) {
+	fig := defaultFig()
+
+	for _, f := range []string{"bad.yaml", "bad.json", "bad.toml"} {
+		t.Run(f, func(t *testing.T) {
+			file := filepath.Join("testdata", "invalid", f)
+			if !fileExists(file) {
+				t.Fatalf("test file %s does not exist", file)
+			}
+			_, err := fig.decodeFile(file)
+			if err == nil {
+				t.Errorf("received nil error")
+			}
+		})
+	}
+
+	t.Run("unsupported file extension", func(t *testing.T) {
+		file := filepath.Join("testdata", "invalid", "list.hcl")
+		if !fileExists(file) {
+			t.Fatalf("test file %s does not exist", file)
+		}
+		_, err := fig.decodeFile(file)
+		if err == nil {
+			t.Fatal("received nil error")
+		}
+		if !strings.Contains(err.Error(), "unsupported") {
+			t.Errorf("err == %v, expected unsupported file extension", err)
+		}
+	})
+
+	t.Run("corrupt gzip", func(t *testing.T) {
+		file := filepath.Join("testdata", "invalid", "bad.yaml.gz")
+		if !fileExists(file) {
+			t.Fatalf("test file %s does not exist", file)
+		}
+		_, err := fig.decodeFile(file)
+		if err == nil {
+			t.Fatal("received nil error")
+		}
+		if !strings.Contains(err.Error(), "decompress") {
+			t.Errorf("err == %v, expected decompress error", err)
+		}
+	})
+
+	t.Run("unsupported file extension inside gzip", func(t *testing.T) {
+		file := filepath.Join("testdata", "invalid", "list.hcl.gz")
+		if !fileExists(file) {
+			t.Fatalf("test file %s does not exist", file)
+		}
+		_, err := fig.decodeFile(file)
+		if err == nil {
+			t.Fatal("received nil error")
+		}
+		if !strings.Contains(err.Error(), "unsupported file extension .hcl") {
+			t.Errorf("err == %v, expected unsupported file extension", err)
+		}
+	})
+
+	t.Run("file does not exist", func(t *testing.T) {
+		_, err := fig.decodeFile("casperthefriendlygho.st")
+		if err == nil {
+			t.Fatal("received nil error")
+		}
+	})
+}
+
+func Test_fig_decodeMap(t *testing.T) {
+	fig := defaultFig()
+	fig.tag = "fig"
+
+	m := map[string]interface{}{
+		"log_level": "debug",
+		"severity":  "5",
+		"server": map[string]interface{}{
+			"ports":         []int{443, 80},
+			"secure":        1,
+			"listener_type": "tls",
+		},
+	}
+
+	var cfg struct {
+		Level    string `fig:"log_level"`
+		Severity int    `fig:"severity" validate:"required"`
+		Server   struct {
+			Ports    []string `fig:"ports" default:"[443]"`
+			Secure   bool
+			Listener ListenerType `fig:"listener_type" default:"unix"`
+		} `fig:"server"`
+	}
+
+	if err := fig.decodeMap(m, &cfg); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if cfg.Level != "debug" {
+		t.Errorf("cfg.Level: want %s, got %s", "debug", cfg.Level)
+	}
+
+	if cfg.Severity != 5 {
+		t.Errorf("cfg.Severity: want %d, got %d", 5, cfg.Severity)
+	}
+
+	if reflect.DeepEqual([]int{443, 80}, cfg.Server.Ports) {
+		t.Errorf("cfg.Server.Ports: want %+v, got %+v", []int{443, 80}, cfg.Server.Ports)
+	}
+
+	if cfg.Server.Secure == false {
+		t.Error("cfg.Server.Secure == false")
+	}
+
+	if cfg.Server.Listener != ListenerTLS {
+		t.Errorf("cfg.Server.Listener: want: %d, got: %d", ListenerTLS, cfg.Server.Listener)
+	}
+}
+
+func Test_fig_applyFieldTags(t *testing.T) {
+	fig := defaultFig()
+
+	type Config struct {
+		Date      time.Time   `fig:"date" timelayout:"2006-01-02"`
+		Timestamp time.Time   `fig:"timestamp"`
+		Holidays  []time.Time `fig:"holidays" timelayout:"01/02"`
+		Nested    struct {
+			Built *time.Time `fig:"built" timelayout:"2006"`
+		} `fig:"nested"`
+	}
+
+	t.Run("converts fields with a time layout", func(t *testing.T) {
+		m := map[string]interface{}{
+			"date":      "2020-12-25",
+			"timestamp": "2020-12-25T10:00:00Z",
+			"holidays":  []interface{}{"12/25", "01/01"},
+			"nested": map[string]interface{}{
+				"built": "2019",
+			},
+		}
+
+		vals, err := fig.applyFieldTags(m, reflect.TypeOf(Config{}))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var cfg Config
+		if err := fig.decodeMap(vals, &cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if want := time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC); !cfg.Date.Equal(want) {
+			t.Errorf("cfg.Date == %v, expected %v", cfg.Date, want)
+		}
+		if want := time.Date(2020, 12, 25, 10, 0, 0, 0, time.UTC); !cfg.Timestamp.Equal(want) {
+			t.Errorf("cfg.Timestamp == %v, expected %v", cfg.Timestamp, want)
+		}
+		want := []time.Time{
+			time.Date(0, 12, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if !reflect.DeepEqual(want, cfg.Holidays) {
+			t.Errorf("cfg.Holidays == %v, expected %v", cfg.Holidays, want)
+		}
+		if cfg.Nested.Built == nil || cfg.Nested.Built.Year() != 2019 {
+			t.Errorf("cfg.Nested.Built == %v, expected year 2019", cfg.Nested.Built)
+		}
+	})
+
+	t.Run("bad time returns error", func(t *testing.T) {
+		m := map[string]interface{}{
+			"date": "2020-12-25T10:00:00Z",
+		}
+
+		_, err := fig.applyFieldTags(m, reflect.TypeOf(Config{}))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if !strings.Contains(err.Error(), "date") {
+			t.Errorf("expected err to contain field name, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_Bytes(t *testing.T) {
+	type Config struct {
+		MaxBodySize int64   `fig:"max_body_size,bytes"`
+		BufferSize  uint32  `fig:"buffer_size,bytes"`
+		CacheSize   *uint64 `fig:"cache_size,bytes" default:"2GiB"`
+		ChunkSize   int     `fig:"chunk_size,bytes"`
+		Sizes       []int   `fig:"sizes,bytes"`
+	}
+
+	t.Run("file, env and defaults", func(t *testing.T) {
+		fig := defaultFig()
+		fig.useEnv = true
+
+		os.Clearenv()
+		setenv(t, "BUFFER_SIZE", "64KiB")
+
+		m := map[string]interface{}{
+			"max_body_size": "10MB",
+			"chunk_size":    4096,
+			"sizes":         []interface{}{"1KB", 2048},
+		}
+
+		vals, err := fig.applyFieldTags(m, reflect.TypeOf(Config{}))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var cfg Config
+		if err := fig.decodeMap(vals, &cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if err := fig.processCfg(&cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if cfg.MaxBodySize != 10000000 {
+			t.Errorf("cfg.MaxBodySize == %d, expected %d", cfg.MaxBodySize, 10000000)
+		}
+		if cfg.BufferSize != 64<<10 {
+			t.Errorf("cfg.BufferSize == %d, expected %d", cfg.BufferSize, 64<<10)
+		}
+		if cfg.CacheSize == nil || *cfg.CacheSize != 2<<30 {
+			t.Errorf("cfg.CacheSize == %v, expected %d", cfg.CacheSize, 2<<30)
+		}
+		if cfg.ChunkSize != 4096 {
+			t.Errorf("cfg.ChunkSize == %d, expected %d", cfg.ChunkSize, 4096)
+		}
+		if want := []int{1000, 2048}; !reflect.DeepEqual(want, cfg.Sizes) {
+			t.Errorf("cfg.Sizes == %v, expected %v", cfg.Sizes, want)
+		}
+	})
+
+	t.Run("invalid size in file", func(t *testing.T) {
+		fig := defaultFig()
+
+		m := map[string]interface{}{
+			"max_body_size": "10 bananas",
+		}
+
+		_, err := fig.applyFieldTags(m, reflect.TypeOf(Config{}))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if !strings.Contains(err.Error(), "max_body_size") {
+			t.Errorf("expected err to contain field name, got %v", err)
+		}
+	})
+
+	t.Run("env overflows field", func(t *testing.T) {
+		fig := defaultFig()
+		fig.useEnv = true
+
+		os.Clearenv()
+		setenv(t, "BUFFER_SIZE", "8GB")
+
+		var cfg Config
+		if err := fig.processCfg(&cfg); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("without flag suffixes are rejected", func(t *testing.T) {
+		var cfg struct {
+			Size int64 `fig:"size" default:"10MB"`
+		}
+		if err := defaultFig().processCfg(&cfg); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+type notifier interface{ Notify(string) }
+
+type noopNotifier struct{}
+
+func (noopNotifier) Notify(string) {}
+
+func Test_fig_Load_RegisterDefault(t *testing.T) {
+	notifierType := reflect.TypeOf((*notifier)(nil)).Elem()
+
+	type Config struct {
+		Notifier notifier   `fig:"notifier" default:"noop"`
+		Other    notifier   `fig:"other"`
+		Plugins  []notifier `fig:"plugins"`
+	}
+
+	t.Run("sets registered default", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, IgnoreFile(), RegisterDefault(notifierType, func() interface{} { return noopNotifier{} }))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, ok := cfg.Notifier.(noopNotifier); !ok {
+			t.Errorf("cfg.Notifier == %#v, expected noopNotifier", cfg.Notifier)
+		}
+		if cfg.Other != nil {
+			t.Errorf("cfg.Other == %#v, expected nil", cfg.Other)
+		}
+	})
+
+	t.Run("set field is left as-is", func(t *testing.T) {
+		type custom struct{ noopNotifier }
+		cfg := Config{Notifier: custom{}}
+		err := Load(&cfg, IgnoreFile(), RegisterDefault(notifierType, func() interface{} { return noopNotifier{} }))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, ok := cfg.Notifier.(custom); !ok {
+			t.Errorf("cfg.Notifier == %#v, expected custom", cfg.Notifier)
+		}
+	})
+
+	t.Run("without registration", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile()); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("constructor returns wrong type", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, IgnoreFile(), RegisterDefault(notifierType, func() interface{} { return "noop" }))
+		if err == nil || !strings.Contains(err.Error(), "does not implement") {
+			t.Fatalf("expected err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_CombinedTag(t *testing.T) {
+	type Config struct {
+		Kind     string   `fig:"kind,required"`
+		Replicas int      `fig:"replicas,default=3"`
+		Tags     []string `fig:"tags,default=[a,b]"`
+		Metadata struct {
+			Name string `fig:"name,required"`
+			Team string `fig:"team,required"`
+		} `fig:"metadata"`
+	}
+
+	var cfg Config
+	err := Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")), StrictTags())
+	if err == nil {
+		t.Fatalf("expected err")
+	}
+
+	fieldErrs := err.(fieldErrors)
+	if len(fieldErrs) != 1 || fieldErrs["metadata.team"] == nil {
+		t.Fatalf("expected metadata.team to fail required validation, got %+v", fieldErrs)
+	}
+
+	if cfg.Replicas != 3 {
+		t.Errorf("cfg.Replicas == %d, expected %d", cfg.Replicas, 3)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(want, cfg.Tags) {
+		t.Errorf("cfg.Tags == %v, expected %v", cfg.Tags, want)
+	}
+}
+
+func Test_fig_Load_StrictTags(t *testing.T) {
+	type Config struct {
+		Kind     string `fig:"kind" validate:"requird"`
+		Metadata struct {
+			Name string `fig:"name" validate:"required"`
+		} `fig:"metadata"`
+	}
+
+	var cfg Config
+	err := Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")))
+	if err != nil {
+		t.Fatalf("unexpected err without StrictTags: %v", err)
+	}
+
+	cfg = Config{}
+	err = Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")), StrictTags())
+	if err == nil {
+		t.Fatalf("expected err")
+	}
+	if want := `kind: unknown validation "requird" in validate tag`; err.Error() != want {
+		t.Errorf("want err %q, got %q", want, err.Error())
+	}
+	if cfg.Kind != "" {
+		t.Errorf("expected tags to be checked before loading, got %+v", cfg)
+	}
+}
+
+func Test_fig_Load_SignedSliceDefaults(t *testing.T) {
+	type Config struct {
+		Floats    []float64       `default:"[-1.5e3,2.5]"`
+		Durations []time.Duration `default:"[-30m,1h]"`
+		Offsets   []int           `default:"[-1, -2]"`
+		Env       []float64
+	}
+
+	os.Clearenv()
+	setenv(t, "ENV", "-2.5E-1,-4")
+
+	var cfg Config
+	if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{
+		Floats:    []float64{-1500, 2.5},
+		Durations: []time.Duration{-30 * time.Minute, time.Hour},
+		Offsets:   []int{-1, -2},
+		Env:       []float64{-0.25, -4},
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+}
+
+func Test_fig_Load_ArrayDefaults(t *testing.T) {
+	type Config struct {
+		Weights [3]float64       `fig:"weights" default:"[1.0,0.5,0.25]"`
+		Tiers   [2]string        `fig:"tiers" default:"primary,secondary"`
+		Waits   [2]time.Duration `fig:"waits" default:"[1s,1m]"`
+		Ports   [2]int           `fig:"ports" default:"[80,443]"`
+		Levels  [2]*int          `fig:"levels" default:"[1,2]"`
+	}
+
+	os.Clearenv()
+	setenv(t, "TIERS", "[gold,silver]")
+
+	var cfg Config
+	err := Load(&cfg, Reader(strings.NewReader("ports: [8080, 0]\n"), DecoderYaml), UseEnv(""))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{
+		Weights: [3]float64{1.0, 0.5, 0.25},
+		Tiers:   [2]string{"gold", "silver"},
+		Waits:   [2]time.Duration{time.Second, time.Minute},
+		Ports:   [2]int{8080, 0},
+		Levels:  [2]*int{ptr(1), ptr(2)},
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	for _, val := range []string{"[1.0,0.5]", "[1.0,0.5,0.25,0.125]", "[1.0,half,0.25]"} {
+		t.Run(val, func(t *testing.T) {
+			fv := reflect.New(reflect.TypeOf([3]float64{})).Elem()
+			if err := defaultFig().setDefaultValue(fv, val, structTag{}); err == nil {
+				t.Fatalf("expected err")
+			}
+			if !fv.IsZero() {
+				t.Errorf("array was modified: %v", fv)
+			}
+		})
+	}
+}
+
+func Test_fig_Load_DefaultResolver(t *testing.T) {
+	type Config struct {
+		MaxConns int           `fig:"maxConns" default:"@MaxConns"`
+		Timeout  time.Duration `fig:"timeout" default:"@Timeout"`
+		Level    string        `fig:"level" default:"info"`
+		Server   struct {
+			Port int `fig:"port" default:"@Port"`
+		} `fig:"server"`
+	}
+
+	table := map[string]string{
+		"@MaxConns": "64",
+		"@Timeout":  "5s",
+		"@Port":     "8080",
+	}
+
+	var paths []string
+	resolver := func(fieldPath, raw string) (string, bool) {
+		paths = append(paths, fieldPath)
+		s, ok := table[raw]
+		return s, ok
+	}
+
+	var cfg Config
+	if err := Load(&cfg, IgnoreFile(), DefaultResolver(resolver)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	var want Config
+	want.MaxConns = 64
+	want.Timeout = 5 * time.Second
+	want.Level = "info"
+	want.Server.Port = 8080
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	if want := []string{"maxConns", "timeout", "level", "server.port"}; !reflect.DeepEqual(want, paths) {
+		t.Errorf("want paths %v, got %v", want, paths)
+	}
+
+	t.Run("unresolved value is parsed", func(t *testing.T) {
+		var cfg struct {
+			MaxConns int `fig:"maxConns" default:"@Unknown"`
+		}
+		if err := Load(&cfg, IgnoreFile(), DefaultResolver(resolver)); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("not consulted for set fields", func(t *testing.T) {
+		var cfg struct {
+			MaxConns int `fig:"maxConns" default:"@MaxConns"`
+		}
+		cfg.MaxConns = 1
+
+		called := false
+		err := Load(&cfg, IgnoreFile(), DefaultResolver(func(string, string) (string, bool) {
+			called = true
+			return "", false
+		}))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if called || cfg.MaxConns != 1 {
+			t.Errorf("called = %t, maxConns = %d", called, cfg.MaxConns)
+		}
+	})
+}
+
+func Test_fig_Load_ValidateDefaults(t *testing.T) {
+	type Config struct {
+		Port    int           `fig:"port" default:"not-an-int"`
+		Timeout time.Duration `fig:"timeout" default:"5s"`
+		Server  *struct {
+			Ratio float64 `fig:"ratio" default:"half"`
+		} `fig:"server"`
+		Workers []struct {
+			Name string `fig:"name" default:"worker"`
+			Size int    `fig:"size" default:"big"`
+		} `fig:"workers"`
+	}
+
+	var cfg Config
+	cfg.Port = 80
+
+	err := Load(&cfg, IgnoreFile())
+	if err != nil {
+		t.Fatalf("unexpected err without option: %v", err)
+	}
+
+	err = Load(&cfg, IgnoreFile(), ValidateDefaults())
+	if err == nil {
+		t.Fatalf("expected err")
+	}
+
+	fieldErrs, ok := err.(fieldErrors)
+	if !ok {
+		t.Fatalf("want err of type fieldErrors, got %T", err)
+	}
+	want := []string{"port", "server.ratio", "workers.size"}
+	var got []string
+	for path := range fieldErrs {
+		got = append(got, path)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want errors for %v, got %v", want, got)
+	}
+
+	if cfg.Port != 80 || cfg.Timeout != 5*time.Second || cfg.Server != nil {
+		t.Errorf("cfg was modified: %+v", cfg)
+	}
+
+	t.Run("valid defaults", func(t *testing.T) {
+		var cfg struct {
+			Port  int      `fig:"port" default:"@Port"`
+			Hosts []string `fig:"hosts" default:"[a,b]"`
+		}
+		err := Load(&cfg, IgnoreFile(), ValidateDefaults(), DefaultResolver(func(_, raw string) (string, bool) {
+			return "8080", raw == "@Port"
+		}))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Port != 8080 || len(cfg.Hosts) != 2 {
+			t.Errorf("unexpected cfg: %+v", cfg)
+		}
+	})
+}
+
+func Test_fig_Load_NoDefaults(t *testing.T) {
+	type Config struct {
+		Kind     string `fig:"kind" default:"Deployment"`
+		Replicas int    `fig:"replicas" default:"3"`
+		Level    string `fig:"level" default:"info"`
+		Metadata struct {
+			Name string `fig:"name" validate:"required"`
+		} `fig:"metadata"`
+		Host string `fig:"host"`
+	}
+
+	os.Clearenv()
+	setenv(t, "LEVEL", "debug")
+
+	var cfg Config
+	err := Load(&cfg, File("pod.yaml"), Dirs(filepath.Join("testdata", "valid")), UseEnv(""), NoDefaults())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	var want Config
+	want.Kind = "Pod"
+	want.Level = "debug"
+	want.Metadata.Name = "redis"
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	t.Run("required is still validated", func(t *testing.T) {
+		var cfg struct {
+			Host string `fig:"host" validate:"required"`
+		}
+		if err := Load(&cfg, IgnoreFile(), NoDefaults()); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_RawMessage(t *testing.T) {
+	type Pod struct {
+		APIVersion interface{}     `fig:"apiVersion"`
+		Kind       string          `fig:"kind"`
+		Metadata   json.RawMessage `fig:"metadata"`
+		Spec       json.RawMessage `fig:"spec"`
+		Extra      json.RawMessage `fig:"extra" default:"{\"a\": 1}"`
+	}
+
+	for _, f := range []string{"pod.yaml", "pod.json", "pod.toml"} {
+		t.Run(f, func(t *testing.T) {
+			var cfg Pod
+			err := Load(&cfg, File(f), Dirs(filepath.Join("testdata", "valid")), UseStrict())
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if want := `{"master":true,"name":"redis"}`; string(cfg.Metadata) != want {
+				t.Errorf("cfg.Metadata == %s, expected %s", cfg.Metadata, want)
+			}
+
+			var spec struct {
+				Containers []struct {
+					Image string `json:"image"`
+				} `json:"containers"`
+			}
+			if err := json.Unmarshal(cfg.Spec, &spec); err != nil {
+				t.Fatalf("unable to unmarshal cfg.Spec: %v", err)
+			}
+			if len(spec.Containers) != 1 || spec.Containers[0].Image != "redis:5.0.4" {
+				t.Errorf("unexpected spec: %+v", spec)
+			}
+
+			if want := `{"a": 1}`; string(cfg.Extra) != want {
+				t.Errorf("cfg.Extra == %s, expected %s", cfg.Extra, want)
+			}
+		})
+	}
+
+	t.Run("env", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "EXTRA", `[1, 2]`)
+
+		var cfg Pod
+		if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := `[1, 2]`; string(cfg.Extra) != want {
+			t.Errorf("cfg.Extra == %s, expected %s", cfg.Extra, want)
+		}
+
+		setenv(t, "EXTRA", `{`)
+		if err := Load(&cfg, IgnoreFile(), UseEnv("")); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_decodeMap_DecodeHook(t *testing.T) {
+	type Celsius float64
+
+	fahrenheitHook := func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if t != reflect.TypeOf(Celsius(0)) {
+			return data, nil
+		}
+		s, ok := data.(string)
+		if !ok || !strings.HasSuffix(s, "F") {
+			return data, nil
+		}
+		var fahrenheit float64
+		if _, err := fmt.Sscanf(s, "%fF", &fahrenheit); err != nil {
+			return nil, err
+		}
+		return Celsius((fahrenheit - 32) * 5 / 9), nil
+	}
+
+	fig := defaultFig()
+	DecodeHook(fahrenheitHook)(fig)
+
+	m := map[string]interface{}{
+		"min":     "32F",
+		"max":     100.0,
+		"timeout": "5s",
+	}
+
+	var cfg struct {
+		Min     Celsius       `fig:"min"`
+		Max     Celsius       `fig:"max"`
+		Timeout time.Duration `fig:"timeout"`
+	}
+
+	if err := fig.decodeMap(m, &cfg); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if cfg.Min != 0 {
+		t.Errorf("cfg.Min == %v, expected %v", cfg.Min, 0)
+	}
+	if cfg.Max != 100 {
+		t.Errorf("cfg.Max == %v, expected %v", cfg.Max, 100)
+	}
+	if cfg.Timeout != 5*time.Second {
+		t.Errorf("cfg.Timeout == %v, expected %v", cfg.Timeout, 5*time.Second)
+	}
+}
+
+func Test_fig_processCfg(t *testing.T) {
+	t.Run("slice elements set by env", func(t *testing.T) {
+		fig := defaultFig()
+		fig.tag = "fig"
+		fig.useEnv = true
+
+		os.Clearenv()
+		setenv(t, "A_0_B", "b0")
+		setenv(t, "A_1_B", "b1")
+		setenv(t, "A_0_C", "9000")
+
+		cfg := struct {
+			A []struct {
+				B string `validate:"required"`
+				C int    `default:"5"`
+			}
+		}{}
+		cfg.A = []struct {
+			B string `validate:"required"`
+			C int    `default:"5"`
+		}{{B: "boo"}, {B: "boo"}}
+
+		err := fig.processCfg(&cfg)
+		if err != nil {
+			t.Fatalf("processCfg() returned unexpected error: %v", err)
+		}
+		if cfg.A[0].B != "b0" {
+			t.Errorf("cfg.A[0].B == %s, expected %s", cfg.A[0].B, "b0")
+		}
+		if cfg.A[1].B != "b1" {
+			t.Errorf("cfg.A[1].B == %s, expected %s", cfg.A[1].B, "b1")
+		}
+		if cfg.A[0].C != 9000 {
+			t.Errorf("cfg.A[0].C == %d, expected %d", cfg.A[0].C, 9000)
+		}
+		if cfg.A[1].C != 5 {
+			t.Errorf("cfg.A[1].C == %d, expected %d", cfg.A[1].C, 5)
+		}
+	})
+
+	t.Run("embedded struct set by env", func(t *testing.T) {
+		fig := defaultFig()
+		fig.useEnv = true
+		fig.tag = "fig"
+
+		type A struct {
+			B string
+		}
+		type C struct {
+			D *int
+		}
+		type F struct {
+			A
+			C `fig:"cc"`
+		}
+		cfg := F{}
+
+		os.Clearenv()
+		setenv(t, "A_B", "embedded")
+		setenv(t, "CC_D", "7")
+
+		err := fig.processCfg(&cfg)
+		if err != nil {
+			t.Fatalf("processCfg() returned unexpected error: %v", err)
+		}
+		if cfg.A.B != "embedded" {
+			t.Errorf("cfg.A.B == %s, expected %s", cfg.A.B, "embedded")
+		}
+		if *cfg.C.D != 7 {
+			t.Errorf("cfg.C.D == %d, expected %d", *cfg.C.D, 7)
+		}
+	})
+}
+
+func Test_fig_processCfg_Formats(t *testing.T) {
+	type Config struct {
+		Email    string   `fig:"email" validate:"email"`
+		Website  *string  `fig:"website" validate:"url"`
+		Host     string   `fig:"host" validate:"required,hostname"`
+		Peers    []string `fig:"peers" validate:"hostname"`
+		Fallback string   `fig:"fallback" validate:"hostname" default:"localhost"`
+	}
+
+	str := func(s string) *string { return &s }
+
+	for _, tc := range []struct {
+		Name string
+		Cfg  Config
+		Want []string
+	}{
+		{
+			Name: "valid",
+			Cfg: Config{
+				Email:   "jane@example.com",
+				Website: str("https://example.com/about"),
+				Host:    "db-1.internal",
+				Peers:   []string{"a.example.com", "b"},
+			},
+		},
+		{
+			Name: "empty values are not checked",
+			Cfg:  Config{Host: "db", Website: str("")},
+		},
+		{
+			Name: "invalid",
+			Cfg: Config{
+				Email:   "Jane <jane@example.com>",
+				Website: str("example.com"),
+				Host:    "-db",
+				Peers:   []string{"a.example.com", "b_c"},
+			},
+			Want: []string{"email", "website", "host", "peers"},
+		},
+		{
+			Name: "composes with required",
+			Cfg:  Config{},
+			Want: []string{"host"},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			fig := defaultFig()
+
+			cfg := tc.Cfg
+			err := fig.processCfg(&cfg)
+			if len(tc.Want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+
+			fieldErrs := err.(fieldErrors)
+
+			if len(tc.Want) != len(fieldErrs) {
+				t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", len(tc.Want), fieldErrs)
+			}
+
+			for _, field := range tc.Want {
+				if _, ok := fieldErrs[field]; !ok {
+					t.Errorf("want %s in fieldErrs, got %+v", field, fieldErrs)
+				}
+			}
+		})
+	}
+
+	t.Run("error describes the failure", func(t *testing.T) {
+		cfg := Config{Host: "db", Peers: []string{"b_c"}}
+		err := defaultFig().processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if want := `peers: hostname validation failed: "b_c" is not a valid hostname`; err.Error() != want {
+			t.Errorf("want err %q, got %q", want, err.Error())
+		}
+	})
+
+	t.Run("secret value is not in the error", func(t *testing.T) {
+		var cfg struct {
+			DSN string `fig:"dsn,secret" validate:"url" default:"u:hunter2@db"`
+		}
+		err := defaultFig().processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if want := "dsn: url validation failed: value is not a valid url"; err.Error() != want {
+			t.Errorf("want err %q, got %q", want, err.Error())
+		}
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		var cfg struct {
+			Port int `fig:"port" validate:"hostname" default:"80"`
+		}
+		if err := defaultFig().processCfg(&cfg); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_processCfg_ContainsExcludes(t *testing.T) {
+	type Config struct {
+		DSN   string   `fig:"dsn" validate:"required,contains=sslmode=,excludes=password"`
+		Hosts []string `fig:"hosts" validate:"contains=.,excludes=_"`
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Cfg  Config
+		Want map[string]string
+	}{
+		{
+			Name: "valid",
+			Cfg: Config{
+				DSN:   "postgres://db/app?sslmode=require",
+				Hosts: []string{"a.example.com", "b.example.com"},
+			},
+		},
+		{
+			Name: "missing substring",
+			Cfg: Config{
+				DSN:   "postgres://db/app",
+				Hosts: []string{"a.example.com", "localhost"},
+			},
+			Want: map[string]string{
+				"dsn":   `contains validation failed: "postgres://db/app" does not contain "sslmode="`,
+				"hosts": `contains validation failed: "localhost" does not contain "."`,
+			},
+		},
+		{
+			Name: "excluded substring",
+			Cfg: Config{
+				DSN:   "postgres://db/app?sslmode=require&password=x",
+				Hosts: []string{"a_b.example.com"},
+			},
+			Want: map[string]string{
+				"dsn":   `excludes validation failed: "postgres://db/app?sslmode=require&password=x" contains "password"`,
+				"hosts": `excludes validation failed: "a_b.example.com" contains "_"`,
+			},
+		},
+		{
+			Name: "composes with required",
+			Cfg:  Config{},
+			Want: map[string]string{
+				"dsn": "required validation failed",
+			},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			cfg := tc.Cfg
+			err := defaultFig().processCfg(&cfg)
+			if len(tc.Want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+
+			fieldErrs := err.(fieldErrors)
+			if len(tc.Want) != len(fieldErrs) {
+				t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", len(tc.Want), fieldErrs)
+			}
+			for field, want := range tc.Want {
+				if got := fieldErrs[field]; got == nil || got.Error() != want {
+					t.Errorf("want %s error %q, got %v", field, want, got)
+				}
+			}
+		})
+	}
+
+	t.Run("secret value is not in the error", func(t *testing.T) {
+		cfg := struct {
+			DSN   string `fig:"dsn,secret" validate:"contains=sslmode="`
+			Token string `fig:"token,secret" validate:"excludes=:"`
+		}{DSN: "postgres://u:hunter2@h/db", Token: "u:hunter2"}
+
+		err := defaultFig().processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if strings.Contains(err.Error(), "hunter2") {
+			t.Fatalf("secret is in err: %v", err)
+		}
+
+		fieldErrs := err.(fieldErrors)
+		for field, want := range map[string]string{
+			"dsn":   `contains validation failed: value does not contain "sslmode="`,
+			"token": `excludes validation failed: value contains ":"`,
+		} {
+			if got := fieldErrs[field]; got == nil || got.Error() != want {
+				t.Errorf("want %s error %q, got %v", field, want, got)
+			}
+		}
+	})
+}
+
+func Test_fig_processCfg_RequiredWithAndWithout(t *testing.T) {
+	type Contact struct {
+		Email string `fig:"email" validate:"required_without=phone"`
+		Phone string `fig:"phone" validate:"required_without=Email"`
+	}
+	type TLS struct {
+		Cert string `fig:"cert" validate:"required_with=key"`
+		Key  string `fig:"key" validate:"required_with=cert"`
+	}
+	type Config struct {
+		Contact Contact `fig:"contact"`
+		TLS     TLS     `fig:"tls"`
+		Admins  []Contact
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Cfg  Config
+		Want []string
+	}{
+		{
+			Name: "all set",
+			Cfg: Config{
+				Contact: Contact{Email: "a@b.c", Phone: "123"},
+				TLS:     TLS{Cert: "cert.pem", Key: "key.pem"},
+			},
+		},
+		{
+			Name: "one of each",
+			Cfg: Config{
+				Contact: Contact{Phone: "123"},
+				Admins:  []Contact{{Email: "a@b.c"}},
+			},
+		},
+		{
+			Name: "neither set",
+			Cfg: Config{
+				Admins: []Contact{{Email: "a@b.c"}, {}},
+			},
+			Want: []string{"contact.email", "contact.phone", "Admins[1].email", "Admins[1].phone"},
+		},
+		{
+			Name: "only one of both set",
+			Cfg: Config{
+				Contact: Contact{Email: "a@b.c"},
+				TLS:     TLS{Key: "key.pem"},
+			},
+			Want: []string{"tls.cert"},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			fig := defaultFig()
+
+			cfg := tc.Cfg
+			err := fig.processCfg(&cfg)
+			if len(tc.Want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+
+			fieldErrs := err.(fieldErrors)
+
+			if len(tc.Want) != len(fieldErrs) {
+				t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", len(tc.Want), fieldErrs)
+			}
+
+			for _, field := range tc.Want {
+				if _, ok := fieldErrs[field]; !ok {
+					t.Errorf("want %s in fieldErrs, got %+v", field, fieldErrs)
+				}
+			}
+		})
+	}
+
+	t.Run("error names both fields", func(t *testing.T) {
+		fig := defaultFig()
+
+		cfg := Config{TLS: TLS{Cert: "cert.pem"}, Contact: Contact{Phone: "123"}}
+		err := fig.processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+
+		if want := "tls.key: required validation failed: required when cert is set"; err.Error() != want {
+			t.Errorf("err == %q, expected %q", err.Error(), want)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		fig := defaultFig()
+
+		cfg := struct {
+			A string `validate:"required_with=C"`
+			B string
+		}{}
+		err := fig.processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+		if !strings.Contains(err.Error(), "unknown field C") {
+			t.Errorf("expected unknown field err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_processCfg_Unique(t *testing.T) {
+	type Container struct {
+		Name  string `fig:"name"`
+		Image string `fig:"image"`
+	}
+	type Spec struct {
+		Containers []*Container `fig:"containers" validate:"unique=name"`
+		Ports      []int        `fig:"ports" validate:"unique"`
+		Volumes    []Container  `fig:"volumes" validate:"unique=Image"`
+	}
+	type Config struct {
+		Spec Spec `fig:"spec"`
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Cfg  Config
+		Want map[string]string
+	}{
+		{
+			Name: "unique",
+			Cfg: Config{Spec: Spec{
+				Containers: []*Container{{Name: "app", Image: "x"}, nil, {Name: "redis", Image: "x"}},
+				Ports:      []int{80, 443},
+				Volumes:    []Container{{Image: "a"}, {Image: "b"}},
+			}},
+		},
+		{
+			Name: "duplicates",
+			Cfg: Config{Spec: Spec{
+				Containers: []*Container{{Name: "redis"}, {Name: "app"}, {Name: "redis"}},
+				Ports:      []int{80, 443, 80},
+				Volumes:    []Container{{Name: "a", Image: "data"}, {Name: "b", Image: "data"}},
+			}},
+			Want: map[string]string{
+				"spec.containers": `unique validation failed: duplicate name "redis" at index 2`,
+				"spec.ports":      `unique validation failed: duplicate value 80 at index 2`,
+				"spec.volumes":    `unique validation failed: duplicate Image "data" at index 1`,
+			},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			fig := defaultFig()
+
+			cfg := tc.Cfg
+			err := fig.processCfg(&cfg)
+			if len(tc.Want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+
+			var errs fieldErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("want fieldErrors, got %v", err)
+			}
+			if len(errs) != len(tc.Want) {
+				t.Fatalf("want %d errors, got %v", len(tc.Want), errs)
+			}
+			for path, want := range tc.Want {
+				if got := errs[path]; got == nil || got.Error() != want {
+					t.Errorf("%s: want err %q, got %v", path, want, got)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown field", func(t *testing.T) {
+		cfg := struct {
+			Containers []Container `validate:"unique=tag"`
+		}{Containers: []Container{{}}}
+
+		err := defaultFig().processCfg(&cfg)
+		if err == nil || !strings.Contains(err.Error(), "Containers: unique validation failed: unknown field tag") {
+			t.Fatalf("want unknown field err, got %v", err)
+		}
+	})
+
+	t.Run("secret value is not in the error", func(t *testing.T) {
+		cfg := struct {
+			Tokens []string `fig:"tokens,secret" validate:"unique"`
+		}{Tokens: []string{"hunter2", "hunter2"}}
+
+		err := defaultFig().processCfg(&cfg)
+		if want := "tokens: unique validation failed: duplicate value at index 1"; err == nil || err.Error() != want {
+			t.Fatalf("want err %q, got %v", want, err)
+		}
+	})
+}
+
+func Test_fig_processCfg_Keys(t *testing.T) {
+	type Endpoint struct {
+		Port int `fig:"port"`
+	}
+	type Config struct {
+		Endpoints map[string]Endpoint `fig:"endpoints" validate:"keys:hostname"`
+		Admins    *map[string]int     `fig:"admins" validate:"keys:email"`
+	}
+
+	t.Run("valid keys", func(t *testing.T) {
+		admins := map[string]int{"root@example.com": 1}
+		cfg := Config{
+			Endpoints: map[string]Endpoint{"api.example.com": {Port: 443}, "localhost": {}},
+			Admins:    &admins,
+		}
+		if err := defaultFig().processCfg(&cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		admins := map[string]int{"root": 1}
+		cfg := Config{
+			Endpoints: map[string]Endpoint{"localhost": {}, "bad host": {}, "under_score": {}},
+			Admins:    &admins,
+		}
+		err := defaultFig().processCfg(&cfg)
+
+		var errs fieldErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("want fieldErrors, got %v", err)
+		}
+		want := map[string]string{
+			"endpoints": `invalid key "bad host": hostname validation failed`,
+			"admins":    `invalid key "root": email validation failed`,
+		}
+		if len(errs) != len(want) {
+			t.Fatalf("want %d errors, got %v", len(want), errs)
+		}
+		for path, want := range want {
+			if got := errs[path]; got == nil || got.Error() != want {
+				t.Errorf("%s: want err %q, got %v", path, want, got)
+			}
+		}
+	})
+
+	t.Run("keys set by env", func(t *testing.T) {
+		setenv(t, "QUOTAS_NOT@VALID", "1")
+
+		var cfg struct {
+			Quotas map[string]int `fig:"quotas" validate:"keys:hostname"`
+		}
+		err := Load(&cfg, IgnoreFile(), UseEnv(""))
+		if err == nil || !strings.Contains(err.Error(), `quotas: invalid key "not@valid": hostname validation failed`) {
+			t.Fatalf("want invalid key err, got %v", err)
+		}
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		cfg := struct {
+			Hosts []string `validate:"keys:hostname"`
+		}{}
+		err := defaultFig().processCfg(&cfg)
+		if err == nil || !strings.Contains(err.Error(), "key validation failed: unsupported type []string") {
+			t.Fatalf("want unsupported type err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_processCfg_RequiredGroup(t *testing.T) {
+	type Auth struct {
+		Token    string `fig:"token" validate:"required_group=auth"`
+		Password string `fig:"password" validate:"required_group=auth"`
+		KeyFile  string `fig:"key_file" validate:"required_group=auth"`
+	}
+	type Config struct {
+		Auth    Auth `fig:"server"`
+		Mirrors []Auth
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Cfg  Config
+		Want []string
+	}{
+		{
+			Name: "one set",
+			Cfg: Config{
+				Auth:    Auth{Password: "secret"},
+				Mirrors: []Auth{{Token: "abc"}},
+			},
+		},
+		{
+			Name: "none set",
+			Cfg: Config{
+				Mirrors: []Auth{{KeyFile: "id_rsa"}, {}},
+			},
+			Want: []string{"server.auth", "Mirrors[1].auth"},
+		},
+		{
+			Name: "two set",
+			Cfg: Config{
+				Auth: Auth{Token: "abc", KeyFile: "id_rsa"},
+			},
+			Want: []string{"server.auth"},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			fig := defaultFig()
+
+			cfg := tc.Cfg
+			err := fig.processCfg(&cfg)
+			if len(tc.Want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+
+			fieldErrs := err.(fieldErrors)
+
+			if len(tc.Want) != len(fieldErrs) {
+				t.Fatalf("\nlen(fieldErrs) != %d\ngot %+v\n", len(tc.Want), fieldErrs)
+			}
+
+			for _, path := range tc.Want {
+				if _, ok := fieldErrs[path]; !ok {
+					t.Errorf("want %s in fieldErrs, got %+v", path, fieldErrs)
+				}
+			}
+		})
+	}
+
+	t.Run("error lists the group", func(t *testing.T) {
+		fig := defaultFig()
+
+		cfg := struct {
+			Token    string `fig:"token" validate:"required_group=auth"`
+			Password string `fig:"password" validate:"required_group=auth"`
+			KeyFile  string `fig:"key_file" validate:"required_group=auth"`
+		}{}
+		err := fig.processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+
+		if want := "auth: exactly one of [token password key_file] must be set"; err.Error() != want {
+			t.Errorf("err == %q, expected %q", err.Error(), want)
+		}
+	})
+}
+func Test_fig_Load_Squash(t *testing.T) {
+	type Base struct {
+		Host    string    `fig:"host" default:"127.0.0.1"`
+		Port    int       `fig:"port" default:"8080"`
+		Name    string    `fig:"name" validate:"required"`
+		Created time.Time `fig:"created" timelayout:"2006-01-02"`
+	}
+	type Config struct {
+		Base  `fig:",squash"`
+		Level string `fig:"level" default:"info"`
+	}
+
+	t.Run("defaults and required", func(t *testing.T) {
+		fig := defaultFig()
+
+		m := map[string]interface{}{
+			"port":    9090,
+			"created": "2020-01-02",
+		}
+
+		vals, err := fig.applyFieldTags(m, reflect.TypeOf(Config{}))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var cfg Config
+		if err := fig.decodeMap(vals, &cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		err = fig.processCfg(&cfg)
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+
+		fieldErrs := err.(fieldErrors)
+		if _, ok := fieldErrs["name"]; !ok || len(fieldErrs) != 1 {
+			t.Fatalf("want name in fieldErrs, got %+v", fieldErrs)
+		}
+
+		want := Config{
+			Base: Base{
+				Host:    "127.0.0.1",
+				Port:    9090,
+				Created: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+			Level: "info",
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot %+v", want, cfg)
+		}
+	})
+
+	t.Run("env and presence use squashed paths", func(t *testing.T) {
+		fig := defaultFig()
+		fig.useEnv = true
+		fig.envPrefix = "app"
+		fig.presenceAware = true
+
+		os.Clearenv()
+		setenv(t, "APP_NAME", "api")
+
+		m := map[string]interface{}{
+			"port": 0,
+		}
+
+		var cfg Config
+		if err := fig.decodeMap(m, &cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if err := fig.processCfg(&cfg); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if cfg.Name != "api" {
+			t.Errorf("cfg.Name == %s, expected %s", cfg.Name, "api")
+		}
+		if !fig.present["port"] || !fig.present["name"] {
+			t.Errorf("expected port and name to be present, got %+v", fig.present)
+		}
+	})
+}
+
+func Test_fig_processField(t *testing.T) {
+	fig := defaultFig()
+	fig.tag = "fig"
+
+	t.Run("field with default", func(t *testing.T) {
+		cfg := struct {
+			X int `fig:"y" default:"10"`
+		}{}
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err != nil {
+			t.Fatalf("processField() returned unexpected error: %v", err)
+		}
+		if cfg.X != 10 {
+			t.Errorf("cfg.X == %d, expected %d", cfg.X, 10)
+		}
+	})
+
+	t.Run("field with default does not overwrite", func(t *testing.T) {
+		cfg := struct {
+			X int `fig:"y" default:"10"`
+		}{}
+		cfg.X = 5
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err != nil {
+			t.Fatalf("processField() returned unexpected error: %v", err)
+		}
+		if cfg.X != 5 {
+			t.Errorf("cfg.X == %d, expected %d", cfg.X, 5)
+		}
+	})
+
+	t.Run("field with bad default", func(t *testing.T) {
+		cfg := struct {
+			X int `fig:"y" default:"not-an-int"`
+		}{}
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err == nil {
+			t.Fatalf("processField() returned nil error")
+		}
+	})
+
+	t.Run("field with required", func(t *testing.T) {
+		cfg := struct {
+			X int `fig:"y" validate:"required"`
+		}{}
+		cfg.X = 10
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err != nil {
+			t.Fatalf("processField() returned unexpected error: %v", err)
+		}
+		if cfg.X != 10 {
+			t.Errorf("cfg.X == %d, expected %d", cfg.X, 10)
+		}
+	})
+
+	t.Run("field with required error", func(t *testing.T) {
+		cfg := struct {
+			X int `fig:"y" validate:"required"`
+		}{}
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err == nil {
+			t.Fatalf("processField() returned nil error")
+		}
+	})
+
+	t.Run("field with default and required", func(t *testing.T) {
+		cfg := struct {
+			X int `fig:"y" default:"10" validate:"required"`
+		}{}
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err == nil {
+			t.Fatalf("processField() expected error")
+		}
+	})
+
+	t.Run("field overwritten by env", func(t *testing.T) {
+		fig := defaultFig()
+		fig.tag = "fig"
+		fig.useEnv = true
+		fig.envPrefix = "fig"
+
+		os.Clearenv()
+		setenv(t, "FIG_X", "MEN")
+
+		cfg := struct {
+			X string `fig:"x"`
+		}{}
+		cfg.X = "BOYS"
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err != nil {
+			t.Fatalf("processField() returned unexpected error: %v", err)
+		}
+		if cfg.X != "MEN" {
+			t.Errorf("cfg.X == %s, expected %s", cfg.X, "MEN")
+		}
+	})
+
+	t.Run("field with bad env", func(t *testing.T) {
+		fig := defaultFig()
+		fig.tag = "fig"
+		fig.useEnv = true
+		fig.envPrefix = "fig"
+
+		os.Clearenv()
+		setenv(t, "FIG_I", "FIFTY")
+
+		cfg := struct {
+			I int
+		}{}
+		parent := &field{
+			v:        reflect.ValueOf(&cfg).Elem(),
+			t:        reflect.ValueOf(&cfg).Elem().Type(),
+			sliceIdx: -1,
+		}
+
+		f := newStructField(parent, 0, fig.tag)
+		err := fig.processField(f)
+		if err == nil {
+			t.Fatalf("processField() returned nil error")
+		}
+	})
+}
+
+func Test_fig_setFromEnv(t *testing.T) {
+	fig := defaultFig()
+	fig.envPrefix = "fig"
+
+	var s string
+	fv := reflect.ValueOf(&s)
+
+	os.Clearenv()
+	ok, err := fig.setFromEnv(fv, "config.string", structTag{})
+	if err != nil {
+		t.Fatalf("setFromEnv() unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatalf("setFromEnv() == true, expected false")
+	}
+	if s != "" {
+		t.Fatalf("s modified to %s", s)
+	}
+
+	setenv(t, "FIG_CONFIG_STRING", "goroutine")
+	ok, err = fig.setFromEnv(fv, "config.string", structTag{})
+	if err != nil {
+		t.Fatalf("setFromEnv() unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("setFromEnv() == false, expected true")
+	}
+	if s != "goroutine" {
+		t.Fatalf("s == %s, expected %s", s, "goroutine")
+	}
+}
+
+func Test_fig_setFromEnv_MergeSlices(t *testing.T) {
+	for _, tc := range []struct {
+		Name  string
+		Merge bool
+		Val   string
+		Want  []int
+	}{
+		{
+			Name:  "append",
+			Merge: true,
+			Val:   "+[8080,9090]",
+			Want:  []int{80, 443, 8080, 9090},
+		},
+		{
+			Name:  "remove",
+			Merge: true,
+			Val:   "-[443]",
+			Want:  []int{80},
+		},
+		{
+			Name:  "replace",
+			Merge: true,
+			Val:   "[8080]",
+			Want:  []int{8080},
+		},
+		{
+			Name:  "disabled",
+			Merge: false,
+			Val:   "[8080]",
+			Want:  []int{8080},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			fig := defaultFig()
+			fig.mergeEnvSlices = tc.Merge
+
+			os.Clearenv()
+			setenv(t, "PORTS", tc.Val)
+
+			ports := []int{80, 443}
+			_, err := fig.setFromEnv(reflect.ValueOf(&ports).Elem(), "ports", structTag{})
+			if err != nil {
+				t.Fatalf("setFromEnv() unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(tc.Want, ports) {
+				t.Fatalf("want %+v, got %+v", tc.Want, ports)
+			}
+		})
+	}
+
+	t.Run("merge syntax is an error if disabled", func(t *testing.T) {
+		fig := defaultFig()
+
+		os.Clearenv()
+		setenv(t, "PORTS", "+[8080]")
+
+		ports := []int{80, 443}
+		_, err := fig.setFromEnv(reflect.ValueOf(&ports).Elem(), "ports", structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_setFromEnv_EnvPrefixes(t *testing.T) {
+	fig := defaultFig()
+	EnvPrefixes("myapp", "oldapp")(fig)
+
+	for _, tc := range []struct {
+		Name string
+		Env  map[string]string
+		Want string
+	}{
+		{
+			Name: "first prefix",
+			Env:  map[string]string{"MYAPP_LEVEL": "new", "OLDAPP_LEVEL": "old"},
+			Want: "new",
+		},
+		{
+			Name: "fallback prefix",
+			Env:  map[string]string{"OLDAPP_LEVEL": "old"},
+			Want: "old",
+		},
+		{
+			Name: "no prefix matches",
+			Env:  map[string]string{"LEVEL": "none"},
+			Want: "",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			os.Clearenv()
+			for k, v := range tc.Env {
+				setenv(t, k, v)
+			}
+
+			var level string
+			fv := reflect.ValueOf(&level).Elem()
+
+			ok, err := fig.setFromEnv(fv, "level", structTag{})
+			if err != nil {
+				t.Fatalf("setFromEnv() unexpected error: %v", err)
+			}
+			if ok != (tc.Want != "") {
+				t.Errorf("setFromEnv() == %t", ok)
+			}
+			if level != tc.Want {
+				t.Errorf("level == %s, expected %s", level, tc.Want)
+			}
+		})
+	}
+}
+
+func Test_fig_formatEnvKey(t *testing.T) {
+	fig := defaultFig()
+
+	for _, tc := range []struct {
+		key    string
+		prefix string
+		want   string
+	}{
+		{
+			key:  "port",
+			want: "PORT",
+		},
+		{
+			key:    "server.host",
+			prefix: "myapp",
+			want:   "MYAPP_SERVER_HOST",
+		},
+		{
+			key:  "loggers[0].log_level",
+			want: "LOGGERS_0_LOG_LEVEL",
+		},
+		{
+			key:  "nested[1].slice[2].twice",
+			want: "NESTED_1_SLICE_2_TWICE",
+		},
+		{
+			key:    "client.http.timeout",
+			prefix: "auth_s",
+			want:   "AUTH_S_CLIENT_HTTP_TIMEOUT",
+		},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tc.prefix, tc.key), func(t *testing.T) {
+			fig.envPrefix = tc.prefix
+			got := fig.formatEnvKey(tc.key)
+			if got != tc.want {
+				t.Errorf("formatEnvKey() == %s, expected %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_fig_envPath(t *testing.T) {
+	cfg := struct {
+		LogLevel   string
+		HTTPServer struct {
+			ReadTimeout time.Duration `fig:"timeout"`
+			Hosts       []struct {
+				HostName string
+			}
+		}
+	}{}
+	cfg.HTTPServer.Hosts = make([]struct{ HostName string }, 1)
+
+	fields := flattenCfg(&cfg, "fig")
+	if len(fields) != 5 {
+		t.Fatalf("len(fields) == %d, expected %d", len(fields), 5)
+	}
+
+	for _, tc := range []struct {
+		split     bool
+		fieldName bool
+		want      []string
+	}{
+		{
+			split: false,
+			want:  []string{"LogLevel", "HTTPServer", "HTTPServer.timeout", "HTTPServer.Hosts", "HTTPServer.Hosts[0].HostName"},
+		},
+		{
+			split: true,
+			want:  []string{"Log_Level", "HTTP_Server", "HTTP_Server.timeout", "HTTP_Server.Hosts", "HTTP_Server.Hosts[0].Host_Name"},
+		},
+		{
+			fieldName: true,
+			want:      []string{"LogLevel", "HTTPServer", "HTTPServer.ReadTimeout", "HTTPServer.Hosts", "HTTPServer.Hosts[0].HostName"},
+		},
+		{
+			split:     true,
+			fieldName: true,
+			want:      []string{"Log_Level", "HTTP_Server", "HTTP_Server.Read_Timeout", "HTTP_Server.Hosts", "HTTP_Server.Hosts[0].Host_Name"},
+		},
+	} {
+		t.Run(fmt.Sprintf("split=%t,fieldName=%t", tc.split, tc.fieldName), func(t *testing.T) {
+			fig := defaultFig()
+			fig.envSplitCamelCase = tc.split
+			fig.envUseFieldName = tc.fieldName
+
+			for i, field := range fields {
+				if got := fig.envPath(field); got != tc.want[i] {
+					t.Errorf("envPath() == %s, expected %s", got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_fig_Load_EnvUseFieldName(t *testing.T) {
+	type Config struct {
+		LogLevel string `fig:"log"`
+		Server   struct {
+			Host string `fig:"hostname"`
+		} `fig:"srv"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MYAPP_LOG", "ignored")
+	setenv(t, "MYAPP_LOG_LEVEL", "debug")
+	setenv(t, "MYAPP_SERVER_HOST", "example.com")
+
+	var cfg Config
+	err := Load(&cfg, IgnoreFile(), UseEnv("myapp"), EnvUseFieldName(), EnvSplitCamelCase())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.LogLevel != "debug" || cfg.Server.Host != "example.com" {
+		t.Errorf("want values from env keys formed from field names, got %+v", cfg)
+	}
+}
+
+func Test_fig_setDefaultValue(t *testing.T) {
+	fig := defaultFig()
+	var b bool
+	fv := reflect.ValueOf(&b).Elem()
+
+	err := fig.setDefaultValue(fv, "true", structTag{})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !b {
+		t.Fatalf("want %t, got %t", true, b)
+	}
+}
+
+func Test_fig_setDefaultValue_ExpandEnv(t *testing.T) {
+	fig := defaultFig()
+	fig.expandDefaults = true
+
+	t.Setenv("FIG_HOME", "/home/fig")
+	t.Setenv("FIG_PATHS", "/a,/b")
+
+	t.Run("slice elements", func(t *testing.T) {
+		var paths []string
+		fv := reflect.ValueOf(&paths).Elem()
+
+		err := fig.setDefaultValue(fv, "[${FIG_HOME}/bin,${FIG_PATHS},${FIG_GOPATH:-/go}/bin]", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if want := []string{"/home/fig/bin", "/a,/b", "/go/bin"}; !reflect.DeepEqual(want, paths) {
+			t.Fatalf("want %+v, got %+v", want, paths)
+		}
+	})
+
+	t.Run("scalar", func(t *testing.T) {
+		var dir string
+		fv := reflect.ValueOf(&dir).Elem()
+
+		err := fig.setDefaultValue(fv, "${FIG_HOME}/.cache", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if want := "/home/fig/.cache"; dir != want {
+			t.Fatalf("want %s, got %s", want, dir)
+		}
+	})
+
+	t.Run("unset variable returns error", func(t *testing.T) {
+		var paths []string
+		fv := reflect.ValueOf(&paths).Elem()
+
+		err := fig.setDefaultValue(fv, "[${FIG_HOME}/bin,${FIG_NOPE}/bin]", structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_setValue(t *testing.T) {
+	fig := defaultFig()
+
+	t.Run("nil ptr", func(t *testing.T) {
+		var s *string
+		fv := reflect.ValueOf(&s)
+
+		err := fig.setValue(fv, "bat", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if *s != "bat" {
+			t.Fatalf("want %s, got %s", "bat", *s)
+		}
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		var slice []int
+		fv := reflect.ValueOf(&slice).Elem()
+
+		err := fig.setValue(fv, "5", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if !reflect.DeepEqual([]int{5}, slice) {
+			t.Fatalf("want %+v, got %+v", []int{5}, slice)
+		}
+	})
+
+	t.Run("int", func(t *testing.T) {
+		var i int
+		fv := reflect.ValueOf(&i).Elem()
+
+		err := fig.setValue(fv, "-8", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if i != -8 {
+			t.Fatalf("want %d, got %d", -8, i)
+		}
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		var b bool
+		fv := reflect.ValueOf(&b).Elem()
+
+		err := fig.setValue(fv, "true", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if !b {
+			t.Fatalf("want true")
+		}
+	})
+
+	t.Run("bad bool", func(t *testing.T) {
+		var b bool
+		fv := reflect.ValueOf(&b).Elem()
+
+		err := fig.setValue(fv, "αλήθεια", structTag{})
+		if err == nil {
+			t.Fatalf("returned nil err")
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		var d time.Duration
+		fv := reflect.ValueOf(&d).Elem()
+
+		err := fig.setValue(fv, "5h", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if d.Hours() != 5 {
+			t.Fatalf("want %v, got %v", 5*time.Hour, d)
+		}
+	})
+
+	t.Run("bad duration", func(t *testing.T) {
+		var d time.Duration
+		fv := reflect.ValueOf(&d).Elem()
+
+		err := fig.setValue(fv, "5decades", structTag{})
+		if err == nil {
+			t.Fatalf("expexted err")
+		}
+	})
+
+	t.Run("uint", func(t *testing.T) {
+		var i uint
+		fv := reflect.ValueOf(&i).Elem()
+
+		err := fig.setValue(fv, "42", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if i != 42 {
+			t.Fatalf("want %d, got %d", 42, i)
+		}
+	})
+
+	t.Run("prefixed int literals", func(t *testing.T) {
+		for val, want := range map[string]int64{"0x1F": 31, "0o755": 493, "0b1010": 10, "0644": 644, "-0x10": -16, "12": 12, "-010": -10, "+0O17": 15} {
+			var i int64
+			if err := fig.setValue(reflect.ValueOf(&i).Elem(), val, structTag{}); err != nil {
+				t.Fatalf("%s: unexpected err: %v", val, err)
+			}
+			if i != want {
+				t.Errorf("%s: want %d, got %d", val, want, i)
+			}
+		}
+	})
+
+	t.Run("prefixed uint literal", func(t *testing.T) {
+		var i uint16
+		fv := reflect.ValueOf(&i).Elem()
+
+		if err := fig.setValue(fv, "0xFFFF", structTag{}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if i != 0xFFFF {
+			t.Fatalf("want %d, got %d", 0xFFFF, i)
+		}
+
+		if err := fig.setValue(fv, "0x10000", structTag{}); err == nil {
+			t.Fatalf("expected overflow err")
+		}
+	})
+
+	t.Run("underscores without numeric separators", func(t *testing.T) {
+		var i int
+		if err := fig.setValue(reflect.ValueOf(&i).Elem(), "0x_FF", structTag{}); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("float", func(t *testing.T) {
+		var f float32
+		fv := reflect.ValueOf(&f).Elem()
+
+		err := fig.setValue(fv, "0.015625", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if f != 0.015625 {
+			t.Fatalf("want %f, got %f", 0.015625, f)
+		}
+	})
+
+	t.Run("bad float", func(t *testing.T) {
+		var f float32
+		fv := reflect.ValueOf(&f).Elem()
+
+		err := fig.setValue(fv, "-i", structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("float32 out of range", func(t *testing.T) {
+		for _, val := range []string{"1e40", "-3.5e38"} {
+			var f float32
+			fv := reflect.ValueOf(&f).Elem()
+
+			err := fig.setValue(fv, val, structTag{})
+			if err == nil {
+				t.Fatalf("expected err for %s", val)
+			}
+			if f != 0 {
+				t.Fatalf("expected f to be left unset, got %f", f)
+			}
+		}
+	})
+
+	t.Run("float64 accepts values out of float32 range", func(t *testing.T) {
+		var f float64
+		fv := reflect.ValueOf(&f).Elem()
+
+		err := fig.setValue(fv, "1e40", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if f != 1e40 {
+			t.Fatalf("want %g, got %g", 1e40, f)
+		}
+	})
+
+	t.Run("float32 is rounded to its precision", func(t *testing.T) {
+		var f float32
+		fv := reflect.ValueOf(&f).Elem()
+
+		err := fig.setValue(fv, "0.1", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if f != float32(0.1) {
+			t.Fatalf("want %v, got %v", float32(0.1), f)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		var s string
+		fv := reflect.ValueOf(&s).Elem()
+
+		err := fig.setValue(fv, "bat", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if s != "bat" {
+			t.Fatalf("want %s, got %s", "bat", s)
+		}
+	})
+
+	t.Run("time", func(t *testing.T) {
+		var tme time.Time
+		fv := reflect.ValueOf(&tme).Elem()
+
+		err := fig.setValue(fv, "2020-01-01T00:00:00Z", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		want, err := time.Parse(fig.timeLayout, "2020-01-01T00:00:00Z")
+		if err != nil {
+			t.Fatalf("error parsing time: %v", err)
+		}
+
+		if !tme.Equal(want) {
+			t.Fatalf("want %v, got %v", want, tme)
+		}
+	})
+
+	t.Run("time with field layout", func(t *testing.T) {
+		var tme time.Time
+		fv := reflect.ValueOf(&tme).Elem()
+
+		err := fig.setValue(fv, "2020-01-01", structTag{timeLayout: "2006-01-02"})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC); !tme.Equal(want) {
+			t.Fatalf("want %v, got %v", want, tme)
+		}
+	})
+
+	t.Run("bad time", func(t *testing.T) {
+		var tme time.Time
+		fv := reflect.ValueOf(&tme).Elem()
+
+		err := fig.setValue(fv, "2020-Feb-01T00:00:00Z", structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("regexp", func(t *testing.T) {
+		var re regexp.Regexp
+		fv := reflect.ValueOf(&re).Elem()
+
+		err := fig.setValue(fv, "[a-z]+", structTag{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if want := regexp.MustCompile("[a-z]+"); re.String() != want.String() {
+			t.Fatalf("want %v, got %v", want, re)
+		}
+	})
+
+	t.Run("bad regexp", func(t *testing.T) {
+		var re regexp.Regexp
+		fv := reflect.ValueOf(&re).Elem()
+
+		err := fig.setValue(fv, "[a-", structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("interface returns error", func(t *testing.T) {
+		var i interface{}
+		fv := reflect.ValueOf(i)
+
+		err := fig.setValue(fv, "empty", structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("struct returns error", func(t *testing.T) {
+		s := struct{ Name string }{}
+		fv := reflect.ValueOf(&s).Elem()
+
+		err := fig.setValue(fv, "foo", structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_parseBool(t *testing.T) {
+	for _, tc := range []struct {
+		In        string
+		BoolWords bool
+		Want      bool
+		WantErr   bool
+	}{
+		{In: "true", Want: true},
+		{In: "0", Want: false},
+		{In: "yes", WantErr: true},
+		{In: "off", WantErr: true},
+		{In: "true", BoolWords: true, Want: true},
+		{In: "F", BoolWords: true, Want: false},
+		{In: "yes", BoolWords: true, Want: true},
+		{In: "No", BoolWords: true, Want: false},
+		{In: "ON", BoolWords: true, Want: true},
+		{In: "off", BoolWords: true, Want: false},
+		{In: "Enabled", BoolWords: true, Want: true},
+		{In: "disabled", BoolWords: true, Want: false},
+		{In: "yep", BoolWords: true, WantErr: true},
+	} {
+		t.Run(fmt.Sprintf("%s/%t", tc.In, tc.BoolWords), func(t *testing.T) {
+			fig := defaultFig()
+			fig.boolWords = tc.BoolWords
+
+			got, err := fig.parseBool(tc.In)
+			if tc.WantErr {
+				if err == nil {
+					t.Fatalf("expected err")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got != tc.Want {
+				t.Fatalf("want %t, got %t", tc.Want, got)
+			}
+		})
+	}
+}
+
+func Test_fig_Load_BoolWords(t *testing.T) {
+	type Config struct {
+		Debug   bool   `fig:"debug"`
+		Verbose bool   `fig:"verbose"`
+		Flags   []bool `fig:"flags"`
+	}
+
+	fig := defaultFig()
+	fig.boolWords = true
+	fig.useEnv = true
+
+	os.Clearenv()
+	setenv(t, "VERBOSE", "on")
+	setenv(t, "FLAGS", "[yes,off,Enabled]")
+
+	m := map[string]interface{}{
+		"debug": "Yes",
+	}
+
+	var cfg Config
+	if err := fig.decodeMap(m, &cfg); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := fig.processCfg(&cfg); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{Debug: true, Verbose: true, Flags: []bool{true, false, true}}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot %+v", want, cfg)
+	}
+}
+
+func TestEnvKeys(t *testing.T) {
+	type Config struct {
+		Build    time.Time
+		LogLevel string `fig:"log_level"`
+		Password string `fig:"password,noenv"`
+		Server   struct {
+			Host    string
+			MaxConn int
+		}
+		Peers []struct {
+			Addr string `fig:"addr"`
+		} `fig:"peers"`
+	}
+
+	var cfg Config
+	cfg.Peers = make([]struct {
+		Addr string `fig:"addr"`
+	}, 2)
+
+	keys, err := EnvKeys(&cfg, "myapp")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []string{
+		"MYAPP_BUILD",
+		"MYAPP_LOG_LEVEL",
+		"MYAPP_PEERS",
+		"MYAPP_PEERS_0_ADDR",
+		"MYAPP_PEERS_1_ADDR",
+		"MYAPP_SERVER",
+		"MYAPP_SERVER_HOST",
+		"MYAPP_SERVER_MAXCONN",
+	}
+	if !reflect.DeepEqual(want, keys) {
+		t.Errorf("\nwant %v\ngot  %v", want, keys)
+	}
+
+	t.Run("options", func(t *testing.T) {
+		var cfg struct {
+			LogLevel string
+		}
+		keys, err := EnvKeys(&cfg, "myapp", EnvSplitCamelCase(), EnvPrefixes("ignored", "oldapp"))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := []string{"MYAPP_LOG_LEVEL", "OLDAPP_LOG_LEVEL"}; !reflect.DeepEqual(want, keys) {
+			t.Errorf("want %v, got %v", want, keys)
+		}
+	})
+
+	t.Run("not a struct pointer", func(t *testing.T) {
+		if _, err := EnvKeys(Config{}, ""); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+// point is a fmt.Scanner that scans values of the form "x:y".
+type point struct{ X, Y int }
+
+func (p *point) Scan(state fmt.ScanState, _ rune) error {
+	tok, err := state.Token(true, nil)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Sscanf(string(tok), "%d:%d", &p.X, &p.Y)
+	return err
+}
+
+func Test_fig_Load_Scanners(t *testing.T) {
+	type Config struct {
+		Ratio    big.Rat  `fig:"ratio"`
+		Share    *big.Rat `fig:"share" default:"1/3"`
+		Rate     *big.Rat `fig:"rate"`
+		Supply   *big.Int `fig:"supply"`
+		Origin   point    `fig:"origin" default:"3:4"`
+		Position point    `fig:"position"`
+	}
+
+	data := "ratio: 2/10\nrate: 0.25\nposition: \"-1:7\"\n"
+
+	os.Clearenv()
+	setenv(t, "SUPPLY", "123456789012345678901234567890")
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if got := cfg.Ratio.RatString(); got != "1/5" {
+		t.Errorf("ratio: want 1/5, got %s", got)
+	}
+	if got := cfg.Share.RatString(); got != "1/3" {
+		t.Errorf("share: want 1/3, got %s", got)
+	}
+	if got := cfg.Rate.RatString(); got != "1/4" {
+		t.Errorf("rate: want 1/4, got %s", got)
+	}
+	if got := cfg.Supply.String(); got != "123456789012345678901234567890" {
+		t.Errorf("supply: want 123456789012345678901234567890, got %s", got)
+	}
+	if want := (point{3, 4}); cfg.Origin != want {
+		t.Errorf("origin: want %+v, got %+v", want, cfg.Origin)
+	}
+	if want := (point{-1, 7}); cfg.Position != want {
+		t.Errorf("position: want %+v, got %+v", want, cfg.Position)
+	}
+
+	t.Run("invalid values", func(t *testing.T) {
+		for _, tc := range []struct {
+			name, env string
+		}{
+			{name: "RATE", env: "one third"},
+			{name: "SUPPLY", env: "12 34"},
+			{name: "POSITION", env: "3"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				os.Clearenv()
+				setenv(t, tc.name, tc.env)
+
+				var cfg Config
+				err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv(""))
+				if err == nil {
+					t.Fatalf("expected err")
+				}
+				if !strings.Contains(err.Error(), strings.ToLower(tc.name)) {
+					t.Errorf("expected err to mention %s, got %v", strings.ToLower(tc.name), err)
+				}
+			})
+		}
+	})
+}
+
+func Test_fig_Load_Remain(t *testing.T) {
+	type Config struct {
+		Name    string                 `fig:"name"`
+		Plugins map[string]interface{} `fig:",remain"`
+		Server  struct {
+			Port  int                    `fig:"port"`
+			Extra map[string]interface{} `fig:",remain"`
+		} `fig:"server"`
+	}
+
+	data := "name: app\nauth:\n  provider: oidc\nmetrics: true\nserver:\n  port: 80\n  gzip: true\n"
+
+	os.Clearenv()
+	setenv(t, "PLUGINS", "ignored")
+
+	var cfg Config
+	err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseStrict(), UseEnv(""))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	var want Config
+	want.Name = "app"
+	want.Plugins = map[string]interface{}{
+		"auth":    map[string]interface{}{"provider": "oidc"},
+		"metrics": true,
+	}
+	want.Server.Port = 80
+	want.Server.Extra = map[string]interface{}{"gzip": true}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+}
+
+func Test_fig_Load_ConflictError(t *testing.T) {
+	type Config struct {
+		Port     int            `fig:"port"`
+		Host     string         `fig:"host"`
+		Timeout  *time.Duration `fig:"timeout"`
+		Level    string         `fig:"level" default:"info"`
+		Password string         `fig:"password,secret"`
+	}
+
+	data := "port: 8080\nhost: example.com\ntimeout: 5s\npassword: a\n"
+
+	load := func(t *testing.T, env map[string]string) error {
+		t.Helper()
+		os.Clearenv()
+		for k, v := range env {
+			setenv(t, k, v)
+		}
+		var cfg Config
+		return Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv(""), ConflictError())
+	}
+
+	t.Run("no conflicts", func(t *testing.T) {
+		// same value as the file, and a field that is not in the file
+		if err := load(t, map[string]string{"HOST": "example.com", "LEVEL": "debug"}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		err := load(t, map[string]string{"PORT": "9090", "TIMEOUT": "10s", "PASSWORD": "b"})
+		fieldErrs, ok := err.(fieldErrors)
+		if !ok {
+			t.Fatalf("want err of type fieldErrors, got %v", err)
+		}
+		if len(fieldErrs) != 3 {
+			t.Fatalf("want 3 errors, got %v", fieldErrs)
+		}
+		if got := fieldErrs["port"].Error(); !strings.Contains(got, "9090") || !strings.Contains(got, "8080") {
+			t.Errorf("unexpected port err: %v", got)
+		}
+		if got := fieldErrs["timeout"].Error(); !strings.Contains(got, "10s") || !strings.Contains(got, "5s") {
+			t.Errorf("unexpected timeout err: %v", got)
+		}
+		if got, want := fieldErrs["password"].Error(), "env conflicts with the value in the config file"; got != want {
+			t.Errorf("want password err %q, got %q", want, got)
+		}
+	})
+
+	t.Run("without option", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "PORT", "9090")
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Port != 9090 {
+			t.Errorf("want port 9090, got %d", cfg.Port)
+		}
+	})
+}
+
+func Test_fig_Load_EnvMaps(t *testing.T) {
+	type Config struct {
+		Quotas   map[string]int           `fig:"quotas"`
+		Timeouts map[string]time.Duration `fig:"timeouts"`
+		Releases map[string]time.Time     `fig:"releases"`
+		Ports    map[int]string           `fig:"ports"`
+		Servers  map[string]struct {
+			Host string `fig:"host"`
+		} `fig:"servers"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MYAPP_QUOTAS_FREE", "10")
+	setenv(t, "MYAPP_QUOTAS_PRO", "100")
+	setenv(t, "MYAPP_TIMEOUTS_READ", "5s")
+	setenv(t, "MYAPP_RELEASES_V1", "2024-03-01T10:00:00Z")
+	setenv(t, "MYAPP_PORTS_443", "https")
+	setenv(t, "MYAPP_SERVERS_A_HOST", "ignored")
+
+	data := "quotas:\n  free: 1\n  team: 50\n"
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("myapp")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{
+		Quotas:   map[string]int{"free": 10, "pro": 100, "team": 50},
+		Timeouts: map[string]time.Duration{"read": 5 * time.Second},
+		Releases: map[string]time.Time{"v1": time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		Ports:    map[int]string{443: "https"},
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	t.Run("invalid value", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "QUOTAS_FREE", "lots")
+
+		var cfg Config
+		cfg.Quotas = map[string]int{"free": 1}
+		if err := Load(&cfg, IgnoreFile(), UseEnv("")); err == nil {
+			t.Fatalf("expected err")
+		}
+		if want := map[string]int{"free": 1}; !reflect.DeepEqual(want, cfg.Quotas) {
+			t.Errorf("map was modified: %v", cfg.Quotas)
+		}
+	})
+
+	t.Run("without env", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "QUOTAS_FREE", "10")
+
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Quotas != nil {
+			t.Errorf("want nil map, got %v", cfg.Quotas)
+		}
+	})
+
+	t.Run("sibling fields", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "MYAPP_LIMITS_ENABLED", "true")
+		setenv(t, "MYAPP_LIMITS_MAX", "5")
+		setenv(t, "APP_SERVER_PORT", "8080")
+		setenv(t, "APP_SERVER_HOST", "example.com")
+
+		var cfg struct {
+			Limits        map[string]int    `fig:"limits"`
+			LimitsEnabled bool              `fig:"limits_enabled"`
+			Server        map[string]string `fig:"server"`
+			ServerPort    int               `fig:"server_port"`
+		}
+		if err := Load(&cfg, IgnoreFile(), EnvPrefixes("myapp", "app")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := map[string]int{"max": 5}; !reflect.DeepEqual(want, cfg.Limits) {
+			t.Errorf("want limits %v, got %v", want, cfg.Limits)
+		}
+		if want := map[string]string{"host": "example.com"}; !reflect.DeepEqual(want, cfg.Server) {
+			t.Errorf("want server %v, got %v", want, cfg.Server)
+		}
+		if !cfg.LimitsEnabled || cfg.ServerPort != 8080 {
+			t.Errorf("want sibling fields set, got %+v", cfg)
+		}
+	})
+}
+
+func Test_fig_Load_NoEnv(t *testing.T) {
+	type Config struct {
+		Host     string `fig:"host"`
+		Password string `fig:"password" env:"-"`
+		Token    string `fig:"token,noenv" default:"none"`
+		Vault    struct {
+			Addr string `fig:"addr"`
+		} `fig:"vault" env:"-"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MYAPP_HOST", "example.com")
+	setenv(t, "MYAPP_PASSWORD", "hunter2")
+	setenv(t, "MYAPP_TOKEN", "abc")
+	setenv(t, "MYAPP_VAULT_ADDR", "evil:8200")
+
+	data := "password: s3cret\nvault:\n  addr: vault:8200\n"
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("myapp")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	var want Config
+	want.Host = "example.com"
+	want.Password = "s3cret"
+	want.Token = "none"
+	want.Vault.Addr = "vault:8200"
+	if want != cfg {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	t.Run("env tags of other libraries", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "MYAPP_PORT", "9090")
+
+		var cfg struct {
+			Port int `fig:"port" env:"PORT"`
+		}
+		if err := Load(&cfg, IgnoreFile(), UseEnv("myapp")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Port != 9090 {
+			t.Errorf("want port 9090 from env, got %d", cfg.Port)
+		}
+	})
+}
+
+func Test_fig_Load_RequiredNonEmpty(t *testing.T) {
+	type Section struct {
+		Name string `fig:"name"`
+		Size int    `fig:"size" default:"10"`
+	}
+	type Config struct {
+		Present *Section `fig:"present" validate:"required"`
+		Content *Section `fig:"content" validate:"required_nonempty"`
+		Inline  Section  `fig:"inline" validate:"required_nonempty"`
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		Data     string
+		WantErrs []string
+	}{
+		{Name: "all content", Data: "present: {}\ncontent: {name: a}\ninline: {size: 3}\n"},
+		{Name: "missing", Data: "inline: {name: a}\n", WantErrs: []string{"content", "present"}},
+		{Name: "allocated but empty", Data: "present: {}\ncontent: {}\ninline: {}\n", WantErrs: []string{"content", "inline"}},
+		{Name: "only defaults", Data: "present: {}\ncontent: {size: 0}\ninline: {name: a}\n", WantErrs: []string{"content"}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			var cfg Config
+			err := Load(&cfg, Reader(strings.NewReader(tc.Data), DecoderYaml))
+			if len(tc.WantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+
+			fieldErrs, ok := err.(fieldErrors)
+			if !ok {
+				t.Fatalf("want fieldErrors, got %v", err)
+			}
+			var got []string
+			for path := range fieldErrs {
+				got = append(got, path)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.WantErrs, got) {
+				t.Errorf("want errors for %v, got %v", tc.WantErrs, err)
+			}
+		})
+	}
+}
+
+func Test_fig_Load_DeepRequired(t *testing.T) {
+	type Config struct {
+		Database struct {
+			Host string `fig:"host"`
+			Port int    `fig:"port" default:"5432"`
+			TLS  struct {
+				Cert string `fig:"cert"`
+			} `fig:"tls"`
+		} `fig:"database" validate:"required"`
+		Cache *struct {
+			Size int `fig:"size"`
+		} `fig:"cache" validate:"required"`
+	}
+
+	for _, tc := range []struct {
+		Name    string
+		Data    string
+		Env     map[string]string
+		WantErr bool
+	}{
+		{Name: "missing", Data: "cache: {size: 0}\n", WantErr: true},
+		{Name: "only defaults", Data: "database: {}\ncache: {size: 0}\n", WantErr: true},
+		{Name: "top level field", Data: "database: {host: db}\ncache: {size: 0}\n"},
+		{Name: "nested field", Data: "database: {tls: {cert: x.pem}}\ncache: {size: 0}\n"},
+		{Name: "overridden default", Data: "database: {port: 5433}\ncache: {size: 0}\n"},
+		{Name: "env", Data: "cache: {size: 0}\n", Env: map[string]string{"DATABASE_HOST": "db"}},
+		{Name: "empty pointer is set", Data: "database: {host: db}\ncache: {}\n"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			os.Clearenv()
+			for k, v := range tc.Env {
+				setenv(t, k, v)
+			}
+
+			var cfg Config
+			err := Load(&cfg, Reader(strings.NewReader(tc.Data), DecoderYaml), UseEnv(""), DeepRequired())
+			if tc.WantErr {
+				fieldErrs, ok := err.(fieldErrors)
+				if !ok || len(fieldErrs) != 1 || fieldErrs["database"] == nil {
+					t.Fatalf("want error for database, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+
+	t.Run("without option", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader("cache: {size: 0}\n"), DecoderYaml)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_NullAsEmpty(t *testing.T) {
+	type Server struct {
+		Host string `fig:"host" default:"localhost"`
+		Port int    `fig:"port" default:"8080"`
+	}
+	type Config struct {
+		Proxy   string   `fig:"proxy" default:"http://proxy:3128"`
+		Timeout int      `fig:"timeout" default:"30"`
+		Tags    []string `fig:"Tags" default:"[a,b]"`
+		Server  Server   `fig:"server"`
+	}
+
+	data := "proxy: null\ntags: ~\nserver:\n  host: null\n"
+
+	t.Run("defaults by default", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		want := Config{
+			Proxy:   "http://proxy:3128",
+			Timeout: 30,
+			Tags:    []string{"a", "b"},
+			Server:  Server{Host: "localhost", Port: 8080},
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+		}
+	})
+
+	t.Run("null fields left empty", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), NullAsEmpty()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		want := Config{
+			Timeout: 30,
+			Server:  Server{Port: 8080},
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+		}
+	})
+
+	t.Run("env still applies", func(t *testing.T) {
+		os.Clearenv()
+		setenv(t, "PROXY", "http://other:8080")
+
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), NullAsEmpty(), UseEnv("")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Proxy != "http://other:8080" {
+			t.Errorf("want proxy from env, got %q", cfg.Proxy)
+		}
+	})
+}
+
+func Test_fig_Load_RejectDuplicateKeys(t *testing.T) {
+	type Config struct {
+		Server struct {
+			Host string `fig:"host"`
+		} `fig:"server"`
+	}
+
+	data := `{"server":{"host":"a","host":"b"}}`
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderJSON)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.Server.Host != "b" {
+		t.Errorf("want last value without the option, got %q", cfg.Server.Host)
+	}
+
+	err := Load(&cfg, Reader(strings.NewReader(data), DecoderJSON), RejectDuplicateKeys())
+	if err == nil || err.Error() != "duplicate key server.host" {
+		t.Fatalf("want duplicate key err, got %v", err)
+	}
+
+	err = Load(&cfg, Reader(strings.NewReader("server:\n  host: a\n  host: b\n"), DecoderYaml))
+	if err == nil || !strings.Contains(err.Error(), `mapping key "host" already defined`) {
+		t.Fatalf("want yaml duplicate key err, got %v", err)
+	}
+}
+
+func Test_fig_Load_YAMLDocument(t *testing.T) {
+	type Config struct {
+		Env  string `fig:"env"`
+		Port int    `fig:"port"`
+	}
+
+	data := "env: dev\nport: 8080\n---\nenv: staging\nport: 8081\n---\nenv: prod\nport: 80\n"
+
+	for _, tc := range []struct {
+		name    string
+		options []Option
+		want    Config
+		wantErr string
+	}{
+		{name: "first by default", want: Config{Env: "dev", Port: 8080}},
+		{name: "by index", options: []Option{YAMLDocument(1)}, want: Config{Env: "staging", Port: 8081}},
+		{name: "where", options: []Option{YAMLDocumentWhere("env", "prod")}, want: Config{Env: "prod", Port: 80}},
+		{name: "where non-string", options: []Option{YAMLDocumentWhere("port", "8081")}, want: Config{Env: "staging", Port: 8081}},
+		{name: "index out of range", options: []Option{YAMLDocument(3)}, wantErr: "no yaml document at index 3"},
+		{name: "no match", options: []Option{YAMLDocumentWhere("env", "test")}, wantErr: `no yaml document with env "test"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			options := append([]Option{Reader(strings.NewReader(data), DecoderYaml)}, tc.options...)
+			err := Load(&cfg, options...)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected err containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if cfg != tc.want {
+				t.Errorf("want %+v, got %+v", tc.want, cfg)
+			}
+		})
+	}
+
+	t.Run("other formats ignore the option", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader(`{"env":"dev","port":1}`), DecoderJSON), YAMLDocument(2))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := (Config{Env: "dev", Port: 1}); cfg != want {
+			t.Errorf("want %+v, got %+v", want, cfg)
+		}
+	})
+}
+
+func Test_fig_Load_IsZeroer(t *testing.T) {
+	type Config struct {
+		Budget money `fig:"budget" validate:"required"`
+		ID     uuid  `fig:"id" validate:"required"`
+	}
+
+	var cfg Config
+	err := Load(&cfg, Reader(strings.NewReader("budget:\n  amount: 5\n"), DecoderYaml))
+	if err == nil {
+		t.Fatalf("expected err")
+	}
+
+	fieldErrs, ok := err.(fieldErrors)
+	if !ok {
+		t.Fatalf("want err of type fieldErrors, got %T", err)
+	}
+	if len(fieldErrs) != 2 || fieldErrs["budget"] == nil || fieldErrs["id"] == nil {
+		t.Errorf("unexpected errors: %v", fieldErrs)
+	}
+
+	cfg = Config{ID: uuid{1}}
+	err = Load(&cfg, Reader(strings.NewReader("budget:\n  amount: 5\n  currency: EUR\n"), DecoderYaml))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		var cfg struct {
+			Budget money `fig:"budget" default:"{currency: USD}"`
+		}
+		// the budget has an amount but IsZero as it has no currency
+		err := Load(&cfg, Reader(strings.NewReader("budget:\n  amount: 5\n"), DecoderYaml))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Budget.Currency != "USD" {
+			t.Errorf("want default applied to a budget that IsZero, got %+v", cfg.Budget)
+		}
+	})
+}
+
+func Test_fig_Load_AllowNumericSeparators(t *testing.T) {
+	type Config struct {
+		MaxRows int     `fig:"maxRows" default:"1_000_000"`
+		Limit   uint64  `fig:"limit"`
+		Ratio   float64 `fig:"ratio" default:"0.000_5"`
+	}
+
+	os.Clearenv()
+	setenv(t, "LIMIT", "18_446_744")
+
+	var cfg Config
+	if err := Load(&cfg, IgnoreFile(), UseEnv(""), AllowNumericSeparators()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want := (Config{MaxRows: 1000000, Limit: 18446744, Ratio: 0.0005}); want != cfg {
+		t.Errorf("want %+v, got %+v", want, cfg)
+	}
+
+	t.Run("invalid placement", func(t *testing.T) {
+		var cfg struct {
+			MaxRows int `fig:"maxRows" default:"1__000"`
+		}
+		if err := Load(&cfg, IgnoreFile(), AllowNumericSeparators()); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("rejected without option", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile()); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
+
+func Test_fig_Load_AllowNumericBool(t *testing.T) {
+	type Config struct {
+		File    bool `fig:"file"`
+		Quoted  bool `fig:"quoted"`
+		Env     bool `fig:"env"`
+		Default bool `fig:"default" default:"1"`
+	}
+
+	load := func(t *testing.T, data, env string, opts ...Option) (Config, error) {
+		t.Helper()
+		os.Clearenv()
+		if env != "" {
+			setenv(t, "ENV", env)
+		}
+
+		var cfg Config
+		opts = append([]Option{Reader(strings.NewReader(data), DecoderYaml), UseEnv("")}, opts...)
+		err := Load(&cfg, opts...)
+		return cfg, err
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		cfg, err := load(t, "file: 1\nquoted: \"1\"\n", "1", AllowNumericBool(true))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := (Config{File: true, Quoted: true, Env: true, Default: true}); want != cfg {
+			t.Errorf("want %+v, got %+v", want, cfg)
+		}
+	})
+
+	t.Run("allowed rejects other numbers", func(t *testing.T) {
+		if _, err := load(t, "file: 2\n", "", AllowNumericBool(true)); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	for _, tc := range []struct {
+		Name string
+		Data string
+		Env  string
+	}{
+		{Name: "disallowed in file", Data: "file: 0\ndefault: true\n"},
+		{Name: "disallowed quoted in file", Data: "quoted: \"1\"\ndefault: true\n"},
+		{Name: "disallowed in env", Data: "default: true\n", Env: "1"},
+		{Name: "disallowed in default", Data: "env: true\n"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			if _, err := load(t, tc.Data, tc.Env, AllowNumericBool(false)); err == nil {
+				t.Fatalf("expected err")
+			}
+		})
+	}
+
+	t.Run("disallowed accepts words", func(t *testing.T) {
 		os.Clearenv()
-		setenv(t, "A_B", "embedded")
-		setenv(t, "CC_D", "7")
+		setenv(t, "ENV", "true")
 
-		err := fig.processCfg(&cfg)
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("file: true\nquoted: \"false\"\ndefault: true\n"), DecoderYaml), UseEnv(""), AllowNumericBool(false))
 		if err != nil {
-			t.Fatalf("processCfg() returned unexpected error: %v", err)
+			t.Fatalf("unexpected err: %v", err)
 		}
-		if cfg.A.B != "embedded" {
-			t.Errorf("cfg.A.B == %s, expected %s", cfg.A.B, "embedded")
+		if want := (Config{File: true, Env: true, Default: true}); want != cfg {
+			t.Errorf("want %+v, got %+v", want, cfg)
 		}
-		if *cfg.C.D != 7 {
-			t.Errorf("cfg.C.D == %d, expected %d", *cfg.C.D, 7)
+	})
+}
+
+func Test_fig_setSlice(t *testing.T) {
+	f := defaultFig()
+
+	for _, tc := range []struct {
+		Name      string
+		InSlice   interface{}
+		WantSlice interface{}
+		Val       string
+	}{
+		{
+			Name:      "ints",
+			InSlice:   &[]int{},
+			WantSlice: &[]int{5, 10, 15},
+			Val:       "[5,10,15]",
+		},
+		{
+			Name:      "ints-no-square-braces",
+			InSlice:   &[]int{},
+			WantSlice: &[]int{5, 10, 15},
+			Val:       "5,10,15",
+		},
+		{
+			Name:      "uints",
+			InSlice:   &[]uint{},
+			WantSlice: &[]uint{5, 10, 15, 20, 25},
+			Val:       "[5,10,15,20,25]",
+		},
+		{
+			Name:      "floats",
+			InSlice:   &[]float32{},
+			WantSlice: &[]float32{1.5, 1.125, -0.25},
+			Val:       "[1.5,1.125,-0.25]",
+		},
+		{
+			Name:      "signed floats in scientific notation",
+			InSlice:   &[]float64{},
+			WantSlice: &[]float64{-1500, 2.5, 1e-3, -2e+2},
+			Val:       "[-1.5e3,2.5,+1E-3,-2e+2]",
+		},
+		{
+			Name:      "negative ints",
+			InSlice:   &[]int{},
+			WantSlice: &[]int{-1, -20, 3},
+			Val:       "-1,-20,3",
+		},
+		{
+			Name:      "signed numbers with spaces",
+			InSlice:   &[]float64{},
+			WantSlice: &[]float64{-1500, 2.5, -3},
+			Val:       "[ -1.5e3, 2.5 , -3 ]",
+		},
+		{
+			Name:      "negative durations",
+			InSlice:   &[]time.Duration{},
+			WantSlice: &[]time.Duration{-30 * time.Minute, time.Hour, -1500 * time.Millisecond},
+			Val:       "[-30m,1h,-1.5s]",
+		},
+		{
+			Name:      "strings",
+			InSlice:   &[]string{},
+			WantSlice: &[]string{"a", "b", "c", "d"},
+			Val:       "[a,b,c,d]",
+		},
+		{
+			Name:      "durations",
+			InSlice:   &[]time.Duration{},
+			WantSlice: &[]time.Duration{30 * time.Minute, 2 * time.Hour},
+			Val:       "[30m,2h]",
+		},
+		{
+			Name:    "times",
+			InSlice: &[]time.Time{},
+			WantSlice: &[]time.Time{
+				time.Date(2019, 12, 25, 10, 30, 30, 0, time.UTC),
+				time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Val: "[2019-12-25T10:30:30Z,2020-01-01T00:00:00Z]",
+		},
+		{
+			Name:    "regexps",
+			InSlice: &[]*regexp.Regexp{},
+			WantSlice: &[]*regexp.Regexp{
+				regexp.MustCompile("[a-z]+"),
+				regexp.MustCompile(".*"),
+			},
+			Val: "[[a-z]+,.*]",
+		},
+		{
+			Name:    "regexps with commas",
+			InSlice: &[]*regexp.Regexp{},
+			WantSlice: &[]*regexp.Regexp{
+				regexp.MustCompile("[a-z]{2,4}"),
+				regexp.MustCompile(`\d+`),
+				regexp.MustCompile("a,b"),
+			},
+			Val: `[[a-z]{2,4},\d+,"a,b"]`,
+		},
+		{
+			Name:      "int pointers",
+			InSlice:   &[]*int{},
+			WantSlice: &[]*int{ptr(5), ptr(10)},
+			Val:       "[5,10]",
+		},
+		{
+			Name:      "string pointers",
+			InSlice:   &[]*string{},
+			WantSlice: &[]*string{ptr("a"), ptr("b")},
+			Val:       "a,b",
+		},
+		{
+			Name:      "duration pointers",
+			InSlice:   &[]*time.Duration{},
+			WantSlice: &[]*time.Duration{ptr(time.Second), ptr(2 * time.Minute)},
+			Val:       "[1s,2m]",
+		},
+	} {
+		t.Run(tc.Val, func(t *testing.T) {
+			in := reflect.ValueOf(tc.InSlice).Elem()
+
+			err := f.setSlice(in, tc.Val, structTag{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := reflect.ValueOf(tc.WantSlice).Elem()
+
+			if !reflect.DeepEqual(want.Interface(), in.Interface()) {
+				t.Fatalf("want %+v, got %+v", want, in)
+			}
+		})
+	}
+
+	t.Run("negative int into uint returns error", func(t *testing.T) {
+		in := &[]uint{}
+		val := "[-5]"
+
+		err := f.setSlice(reflect.ValueOf(in).Elem(), val, structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("lone sign returns error", func(t *testing.T) {
+		in := &[]float64{}
+		val := "[-,1]"
+
+		err := f.setSlice(reflect.ValueOf(in).Elem(), val, structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+
+	t.Run("bad pointer element returns error", func(t *testing.T) {
+		in := &[]*int{}
+		val := "[1,x]"
+
+		err := f.setSlice(reflect.ValueOf(in).Elem(), val, structTag{})
+		if err == nil {
+			t.Fatalf("expected err")
 		}
 	})
 }
 
-func Test_fig_processField(t *testing.T) {
-	fig := defaultFig()
-	fig.tag = "fig"
+func Test_fig_Load_StructFlowDefaults(t *testing.T) {
+	type TLS struct {
+		Enabled bool   `fig:"enabled"`
+		CA      string `fig:"ca"`
+	}
+	type Server struct {
+		Host    string        `fig:"host"`
+		Port    int           `fig:"port"`
+		Timeout time.Duration `fig:"timeout"`
+		Tags    []string      `fig:"tags"`
+		TLS     TLS           `fig:"tls"`
+	}
+	type Config struct {
+		Server   Server  `fig:"server" default:"{host:localhost,port:8080,timeout:5s,tags:[a,b],tls:{enabled:true}}"`
+		Admin    *Server `fig:"admin" default:"{host:admin.local,port:9090}"`
+		Metrics  Server  `fig:"metrics" default:"{port:9100}"`
+		Fallback Server  `fig:"fallback" default:"{host:fallback}"`
+	}
 
-	t.Run("field with default", func(t *testing.T) {
-		cfg := struct {
-			X int `fig:"y" default:"10"`
-		}{}
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
+	os.Clearenv()
+	setenv(t, "SERVER_PORT", "8081")
+
+	data := "fallback:\n  port: 1\n"
+
+	var cfg Config
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{
+		Server: Server{
+			Host:    "localhost",
+			Port:    8081,
+			Timeout: 5 * time.Second,
+			Tags:    []string{"a", "b"},
+			TLS:     TLS{Enabled: true},
+		},
+		Admin:    &Server{Host: "admin.local", Port: 9090},
+		Metrics:  Server{Port: 9100},
+		Fallback: Server{Port: 1},
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Cfg  interface{}
+	}{
+		{
+			Name: "unknown key",
+			Cfg: &struct {
+				Server Server `fig:"server" default:"{hots:localhost}"`
+			}{},
+		},
+		{
+			Name: "bad value",
+			Cfg: &struct {
+				Server Server `fig:"server" default:"{port:http}"`
+			}{},
+		},
+		{
+			Name: "malformed",
+			Cfg: &struct {
+				Server Server `fig:"server" default:"{port:80"`
+			}{},
+		},
+		{
+			Name: "not a mapping",
+			Cfg: &struct {
+				Server Server `fig:"server" default:"localhost"`
+			}{},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			if err := Load(tc.Cfg, IgnoreFile()); err == nil {
+				t.Fatalf("expected err")
+			}
+		})
+	}
+}
+
+func Test_fig_Load_PointerSliceDefaultsAndEnv(t *testing.T) {
+	type Config struct {
+		Ports     []*int           `fig:"ports" default:"[80,443]"`
+		Names     []*string        `fig:"names" default:"[a,b]"`
+		Timeouts  []*time.Duration `fig:"timeouts" default:"[1s,2m]"`
+		Retries   []*int           `fig:"retries"`
+		Intervals *[]*int          `fig:"intervals" default:"[1,2]"`
+	}
+
+	os.Clearenv()
+	setenv(t, "RETRIES", "[3,5]")
+	setenv(t, "NAMES", "x")
+
+	var cfg Config
+	if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := Config{
+		Ports:     []*int{ptr(80), ptr(443)},
+		Names:     []*string{ptr("x")},
+		Timeouts:  []*time.Duration{ptr(time.Second), ptr(2 * time.Minute)},
+		Retries:   []*int{ptr(3), ptr(5)},
+		Intervals: &[]*int{ptr(1), ptr(2)},
+	}
+	if !reflect.DeepEqual(want, cfg) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+	}
+	if cfg.Ports[0] == cfg.Ports[1] {
+		t.Errorf("expected each element to be allocated separately")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	t.Setenv(key, value)
+}
+
+func Test_fig_Load_ScalarToSlice(t *testing.T) {
+	type Config struct {
+		Hosts    []string        `fig:"hosts"`
+		Ports    []int           `fig:"ports"`
+		Timeouts []time.Duration `fig:"timeouts"`
+		Tags     []string        `fig:"tags" default:"[a,b]"`
+	}
+
+	want := Config{
+		Hosts:    []string{"kafka1"},
+		Ports:    []int{9092},
+		Timeouts: []time.Duration{time.Second},
+		Tags:     []string{"x"},
+	}
+
+	for _, tc := range []struct {
+		decoder Decoder
+		data    string
+	}{
+		{DecoderYaml, "hosts: kafka1\nports: 9092\ntimeouts: 1s\ntags: x\n"},
+		{DecoderJSON, `{"hosts":"kafka1","ports":9092,"timeouts":"1s","tags":"x"}`},
+		{DecoderToml, "hosts = \"kafka1\"\nports = 9092\ntimeouts = \"1s\"\ntags = \"x\"\n"},
+	} {
+		t.Run(string(tc.decoder), func(t *testing.T) {
+			var cfg Config
+			if err := Load(&cfg, Reader(strings.NewReader(tc.data), tc.decoder)); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(want, cfg) {
+				t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+			}
+		})
+	}
+
+	t.Run("env", func(t *testing.T) {
+		setenv(t, "HOSTS", "kafka1")
+		setenv(t, "PORTS", "9092")
+		setenv(t, "TIMEOUTS", "1s")
+		setenv(t, "TAGS", "x")
+
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
 		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
+		}
+	})
+}
 
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
+type pluginsConfig struct {
+	Plugins map[string]string `fig:"plugins"`
+	Timeout time.Duration     `fig:"timeout" default:"5s"`
+	Name    string            `fig:"name" validate:"required"`
+}
+
+// Decode collects every key that starts with "plugin_" into Plugins.
+func (c *pluginsConfig) Decode(vals map[string]interface{}) error {
+	for k, v := range vals {
+		name, ok := strings.CutPrefix(k, "plugin_")
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("plugin %s: want a string, got %T", name, v)
+		}
+		if c.Plugins == nil {
+			c.Plugins = make(map[string]string)
+		}
+		c.Plugins[name] = s
+	}
+	return nil
+}
+
+func Test_fig_Load_MapDecoder(t *testing.T) {
+	t.Run("decodes", func(t *testing.T) {
+		setenv(t, "NAME", "app")
+
+		var cfg pluginsConfig
+		data := `{"plugin_auth":"oidc","plugin_cache":"redis","other":1}`
+		err := Load(&cfg, Reader(strings.NewReader(data), DecoderJSON), UseEnv(""), UseStrict())
 		if err != nil {
-			t.Fatalf("processField() returned unexpected error: %v", err)
+			t.Fatalf("unexpected err: %v", err)
 		}
-		if cfg.X != 10 {
-			t.Errorf("cfg.X == %d, expected %d", cfg.X, 10)
+
+		want := pluginsConfig{
+			Plugins: map[string]string{"auth": "oidc", "cache": "redis"},
+			Timeout: 5 * time.Second,
+			Name:    "app",
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
 	})
 
-	t.Run("field with default does not overwrite", func(t *testing.T) {
-		cfg := struct {
-			X int `fig:"y" default:"10"`
-		}{}
-		cfg.X = 5
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
+	t.Run("validates", func(t *testing.T) {
+		var cfg pluginsConfig
+		err := Load(&cfg, Reader(strings.NewReader(`{"plugin_auth":"oidc"}`), DecoderJSON))
+		if err == nil || !strings.Contains(err.Error(), "name") {
+			t.Fatalf("want required err for name, got %v", err)
+		}
+	})
+
+	t.Run("decode error", func(t *testing.T) {
+		var cfg pluginsConfig
+		err := Load(&cfg, Reader(strings.NewReader(`{"plugin_auth":1}`), DecoderJSON))
+		if err == nil || err.Error() != "plugin auth: want a string, got float64" {
+			t.Fatalf("want decode err, got %v", err)
+		}
+	})
+}
+
+func Test_fig_Load_PrefixedIntLiterals(t *testing.T) {
+	setenv(t, "MASK", "0xFF")
+
+	var cfg struct {
+		Mode  int   `fig:"mode" default:"0o644"`
+		Mask  uint8 `fig:"mask"`
+		Flags []int `fig:"flags" default:"[0b01,0b10]"`
+	}
+	if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.Mode != 420 || cfg.Mask != 255 || !reflect.DeepEqual(cfg.Flags, []int{1, 2}) {
+		t.Errorf("got %+v", cfg)
+	}
+
+	t.Run("leading zeros are decimal", func(t *testing.T) {
+		setenv(t, "ZIP", "08080")
+
+		var cfg struct {
+			Zip     int    `fig:"zip"`
+			Ten     int    `fig:"ten" default:"010"`
+			Hundred uint16 `fig:"hundred" default:"0100"`
+		}
+		if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Zip != 8080 || cfg.Ten != 10 || cfg.Hundred != 100 {
+			t.Errorf("got %+v", cfg)
 		}
+	})
+}
 
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
-		if err != nil {
-			t.Fatalf("processField() returned unexpected error: %v", err)
+func Test_fig_Load_FromEnvConfig(t *testing.T) {
+	type Config struct {
+		Host string `fig:"host"`
+	}
+
+	t.Run("config file", func(t *testing.T) {
+		setenv(t, EnvConfigFile, filepath.Join("testdata", "valid", "server.yaml"))
+
+		var cfg Config
+		if err := Load(&cfg, FromEnvConfig()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
 		}
-		if cfg.X != 5 {
-			t.Errorf("cfg.X == %d, expected %d", cfg.X, 5)
+		if cfg.Host != "0.0.0.0" {
+			t.Errorf("want host from %s, got %q", EnvConfigFile, cfg.Host)
 		}
 	})
 
-	t.Run("field with bad default", func(t *testing.T) {
-		cfg := struct {
-			X int `fig:"y" default:"not-an-int"`
-		}{}
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
+	t.Run("strict", func(t *testing.T) {
+		setenv(t, EnvConfigFile, filepath.Join("testdata", "valid", "server.yaml"))
+		setenv(t, EnvStrict, "1")
+
+		var cfg Config
+		err := Load(&cfg, FromEnvConfig())
+		if err == nil || !strings.Contains(err.Error(), "logger") {
+			t.Fatalf("want unknown keys err, got %v", err)
 		}
+	})
 
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
-		if err == nil {
-			t.Fatalf("processField() returned nil error")
+	t.Run("explicit options win", func(t *testing.T) {
+		setenv(t, EnvConfigFile, filepath.Join("testdata", "valid", "missing.yaml"))
+		setenv(t, EnvStrict, "false")
+
+		var cfg Config
+		err := Load(&cfg, UseStrict(), FromEnvConfig(), File("server.yaml"), Dirs(filepath.Join("testdata", "valid")))
+		if err == nil || !strings.Contains(err.Error(), "logger") {
+			t.Fatalf("want unknown keys err, got %v", err)
 		}
 	})
 
-	t.Run("field with required", func(t *testing.T) {
-		cfg := struct {
-			X int `fig:"y" validate:"required"`
-		}{}
-		cfg.X = 10
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
+	t.Run("invalid strict", func(t *testing.T) {
+		setenv(t, EnvStrict, "maybe")
+
+		var cfg Config
+		err := Load(&cfg, FromEnvConfig(), IgnoreFile())
+		if err == nil || !strings.HasPrefix(err.Error(), EnvStrict+": ") {
+			t.Fatalf("want %s err, got %v", EnvStrict, err)
 		}
+	})
+}
 
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
-		if err != nil {
-			t.Fatalf("processField() returned unexpected error: %v", err)
+func Test_fig_Load_TimeNames(t *testing.T) {
+	type Config struct {
+		Day      time.Weekday   `fig:"day" default:"Monday"`
+		Month    time.Month     `fig:"month" default:"jan"`
+		Workdays []time.Weekday `fig:"workdays" default:"[mon,TUE,Wednesday,4,fri]"`
+		Billing  time.Month     `fig:"billing"`
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
 		}
-		if cfg.X != 10 {
-			t.Errorf("cfg.X == %d, expected %d", cfg.X, 10)
+		want := Config{
+			Day:      time.Monday,
+			Month:    time.January,
+			Workdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
 	})
 
-	t.Run("field with required error", func(t *testing.T) {
-		cfg := struct {
-			X int `fig:"y" validate:"required"`
-		}{}
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
-		}
+	t.Run("file and env", func(t *testing.T) {
+		setenv(t, "BILLING", "december")
 
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
-		if err == nil {
-			t.Fatalf("processField() returned nil error")
+		var cfg Config
+		data := "day: sat\nmonth: 3\nworkdays: [sunday]\n"
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		want := Config{
+			Day:      time.Saturday,
+			Month:    time.March,
+			Workdays: []time.Weekday{time.Sunday},
+			Billing:  time.December,
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
 	})
 
-	t.Run("field with default and required", func(t *testing.T) {
-		cfg := struct {
-			X int `fig:"y" default:"10" validate:"required"`
-		}{}
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
+	t.Run("invalid name", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("day: someday\n"), DecoderYaml))
+		if err == nil || !strings.Contains(err.Error(), `invalid weekday "someday"`) {
+			t.Fatalf("want invalid weekday err, got %v", err)
 		}
+	})
 
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
-		if err == nil {
-			t.Fatalf("processField() expected error")
+	t.Run("out of range", func(t *testing.T) {
+		var cfg struct {
+			Month time.Month `fig:"month" default:"13"`
+		}
+		err := Load(&cfg, IgnoreFile())
+		if err == nil || !strings.Contains(err.Error(), `invalid month "13"`) {
+			t.Fatalf("want invalid month err, got %v", err)
 		}
 	})
+}
 
-	t.Run("field overwritten by env", func(t *testing.T) {
-		fig := defaultFig()
-		fig.tag = "fig"
-		fig.useEnv = true
-		fig.envPrefix = "fig"
+func Test_fig_Load_EnvJSON(t *testing.T) {
+	type Server struct {
+		Host string `fig:"host"`
+		Port int    `fig:"port"`
+		TLS  bool   `fig:"tls"`
+	}
+	type Config struct {
+		Server Server            `fig:"server"`
+		Backup *Server           `fig:"backup"`
+		Labels map[string]string `fig:"labels"`
+	}
 
-		os.Clearenv()
-		setenv(t, "FIG_X", "MEN")
+	data := "server:\n  host: localhost\n  tls: true\nbackup:\n  host: backup\nlabels:\n  team: infra\n"
 
-		cfg := struct {
-			X string `fig:"x"`
-		}{}
-		cfg.X = "BOYS"
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
-		}
+	t.Run("leaf wins over object", func(t *testing.T) {
+		setenv(t, "MYAPP_SERVER", `{"host":"0.0.0.0","port":8080}`)
+		setenv(t, "MYAPP_SERVER_PORT", "9090")
+		setenv(t, "MYAPP_BACKUP", `{"port":8081}`)
+		setenv(t, "MYAPP_BACKUP_HOST", "standby")
+		setenv(t, "MYAPP_LABELS", `{"env":"prod","team":"core"}`)
+		setenv(t, "MYAPP_LABELS_TEAM", "platform")
 
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("myapp"), EnvJSON())
 		if err != nil {
-			t.Fatalf("processField() returned unexpected error: %v", err)
+			t.Fatalf("unexpected err: %v", err)
 		}
-		if cfg.X != "MEN" {
-			t.Errorf("cfg.X == %s, expected %s", cfg.X, "MEN")
+
+		want := Config{
+			Server: Server{Host: "0.0.0.0", Port: 9090, TLS: true},
+			Backup: &Server{Host: "standby", Port: 8081},
+			Labels: map[string]string{"env": "prod", "team": "platform"},
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
 	})
 
-	t.Run("field with bad env", func(t *testing.T) {
-		fig := defaultFig()
-		fig.tag = "fig"
-		fig.useEnv = true
-		fig.envPrefix = "fig"
-
-		os.Clearenv()
-		setenv(t, "FIG_I", "FIFTY")
+	t.Run("allocates nil pointer", func(t *testing.T) {
+		setenv(t, "MYAPP_BACKUP", `{"host":"standby","port":8081}`)
 
-		cfg := struct {
-			I int
-		}{}
-		parent := &field{
-			v:        reflect.ValueOf(&cfg).Elem(),
-			t:        reflect.ValueOf(&cfg).Elem().Type(),
-			sliceIdx: -1,
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), UseEnv("myapp"), EnvJSON()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
 		}
-
-		f := newStructField(parent, 0, fig.tag)
-		err := fig.processField(f)
-		if err == nil {
-			t.Fatalf("processField() returned nil error")
+		if want := (&Server{Host: "standby", Port: 8081}); !reflect.DeepEqual(want, cfg.Backup) {
+			t.Errorf("want %+v, got %+v", want, cfg.Backup)
 		}
 	})
-}
 
-func Test_fig_setFromEnv(t *testing.T) {
-	fig := defaultFig()
-	fig.envPrefix = "fig"
+	for _, tc := range []struct {
+		name    string
+		val     string
+		wantErr string
+	}{
+		{name: "invalid json", val: `{"host":`, wantErr: "invalid json"},
+		{name: "unknown key", val: `{"hots":"x"}`, wantErr: "unknown keys: hots"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setenv(t, "MYAPP_SERVER", tc.val)
 
-	var s string
-	fv := reflect.ValueOf(&s)
+			var cfg Config
+			err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("myapp"), EnvJSON())
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("want err containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
 
-	os.Clearenv()
-	err := fig.setFromEnv(fv, "config.string")
-	if err != nil {
-		t.Fatalf("setFromEnv() unexpected error: %v", err)
+func Test_fig_Load_DecoderAuto(t *testing.T) {
+	type Config struct {
+		Server struct {
+			Host string `fig:"host"`
+			Port int    `fig:"port"`
+		} `fig:"server"`
 	}
-	if s != "" {
-		t.Fatalf("s modified to %s", s)
+
+	for _, data := range []string{
+		`{"server":{"host":"localhost","port":8080}}`,
+		"server:\n  host: localhost\n  port: 8080\n",
+		"# generated\n[server]\nhost = \"localhost\"\nport = 8080\n",
+		"server.host = \"localhost\"\nserver.port = 8080\n",
+	} {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderAuto)); err != nil {
+			t.Fatalf("%q: unexpected err: %v", data, err)
+		}
+		if cfg.Server.Host != "localhost" || cfg.Server.Port != 8080 {
+			t.Errorf("%q: got %+v", data, cfg)
+		}
 	}
 
-	setenv(t, "FIG_CONFIG_STRING", "goroutine")
-	err = fig.setFromEnv(fv, "config.string")
-	if err != nil {
-		t.Fatalf("setFromEnv() unexpected error: %v", err)
+	t.Run("long config", func(t *testing.T) {
+		data := "server:\n  host: localhost\n  port: 8080\n" + strings.Repeat("# padding\n", sniffLen)
+
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderAuto)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cfg.Server.Port != 8080 {
+			t.Errorf("got %+v", cfg)
+		}
+	})
+}
+
+func Test_fig_Load_InfNaN(t *testing.T) {
+	type Config struct {
+		Max     float64   `fig:"max" default:"+Inf"`
+		Min     float32   `fig:"min" default:"-inf"`
+		Missing float64   `fig:"missing" default:"NaN"`
+		Bounds  []float64 `fig:"bounds" default:"[-Inf,0,Infinity]"`
 	}
-	if s != "goroutine" {
-		t.Fatalf("s == %s, expected %s", s, "goroutine")
+
+	check := func(t *testing.T, cfg Config) {
+		t.Helper()
+		if !math.IsInf(cfg.Max, 1) || !math.IsInf(float64(cfg.Min), -1) || !math.IsNaN(cfg.Missing) {
+			t.Errorf("got %+v", cfg)
+		}
+		if len(cfg.Bounds) != 3 || !math.IsInf(cfg.Bounds[0], -1) || cfg.Bounds[1] != 0 || !math.IsInf(cfg.Bounds[2], 1) {
+			t.Errorf("got bounds %v", cfg.Bounds)
+		}
 	}
-}
 
-func Test_fig_formatEnvKey(t *testing.T) {
-	fig := defaultFig()
+	t.Run("defaults", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		check(t, cfg)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		setenv(t, "MAX", "inf")
+		setenv(t, "MIN", "-Inf")
+		setenv(t, "MISSING", "nan")
+		setenv(t, "BOUNDS", "[-inf, 0, +inf]")
+
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), UseEnv(""), NoDefaults()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		check(t, cfg)
+	})
 
 	for _, tc := range []struct {
-		key    string
-		prefix string
-		want   string
+		decoder Decoder
+		data    string
 	}{
-		{
-			key:  "port",
-			want: "PORT",
-		},
-		{
-			key:    "server.host",
-			prefix: "myapp",
-			want:   "MYAPP_SERVER_HOST",
-		},
-		{
-			key:  "loggers[0].log_level",
-			want: "LOGGERS_0_LOG_LEVEL",
-		},
-		{
-			key:  "nested[1].slice[2].twice",
-			want: "NESTED_1_SLICE_2_TWICE",
-		},
-		{
-			key:    "client.http.timeout",
-			prefix: "auth_s",
-			want:   "AUTH_S_CLIENT_HTTP_TIMEOUT",
-		},
+		{DecoderYaml, "max: .inf\nmin: -.inf\nmissing: .nan\nbounds: [-.inf, 0, .inf]\n"},
+		{DecoderYaml, "max: Inf\nmin: -Inf\nmissing: NaN\nbounds: [-Inf, 0, +Inf]\n"},
+		{DecoderJSON, `{"max":"+Inf","min":"-Inf","missing":"NaN","bounds":["-Inf",0,"Inf"]}`},
+		{DecoderToml, "max = inf\nmin = -inf\nmissing = nan\nbounds = [-inf, 0.0, +inf]\n"},
 	} {
-		t.Run(fmt.Sprintf("%s/%s", tc.prefix, tc.key), func(t *testing.T) {
-			fig.envPrefix = tc.prefix
-			got := fig.formatEnvKey(tc.key)
-			if got != tc.want {
-				t.Errorf("formatEnvKey() == %s, expected %s", got, tc.want)
+		t.Run(string(tc.decoder), func(t *testing.T) {
+			var cfg Config
+			if err := Load(&cfg, Reader(strings.NewReader(tc.data), tc.decoder), NoDefaults()); err != nil {
+				t.Fatalf("unexpected err: %v", err)
 			}
+			check(t, cfg)
 		})
 	}
+
+	t.Run("NaN is set", func(t *testing.T) {
+		var cfg struct {
+			Ratio float64 `fig:"ratio" validate:"required"`
+		}
+		setenv(t, "RATIO", "NaN")
+		if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !math.IsNaN(cfg.Ratio) {
+			t.Errorf("want NaN, got %v", cfg.Ratio)
+		}
+	})
+
+	t.Run("NaN does not conflict with NaN", func(t *testing.T) {
+		var cfg struct {
+			Ratio float64 `fig:"ratio"`
+		}
+		setenv(t, "RATIO", "nan")
+		err := Load(&cfg, Reader(strings.NewReader("ratio: .nan\n"), DecoderYaml), UseEnv(""), ConflictError())
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
 }
 
-func Test_fig_setDefaultValue(t *testing.T) {
-	fig := defaultFig()
-	var b bool
-	fv := reflect.ValueOf(&b).Elem()
+func Test_fig_Load_FieldError(t *testing.T) {
+	type Config struct {
+		Port    int            `fig:"port"`
+		Timeout time.Duration  `fig:"timeout" default:"5x"`
+		Quotas  map[string]int `fig:"quotas"`
+		Pin     int            `fig:"pin,secret"`
+	}
+
+	setenv(t, "MYAPP_PORT", "eighty")
+	setenv(t, "MYAPP_QUOTAS_FREE", "ten")
+	setenv(t, "MYAPP_PIN", "12a4")
 
-	err := fig.setDefaultValue(fv, "true")
+	var cfg Config
+	err := Load(&cfg, IgnoreFile(), UseEnv("myapp"))
 	if err == nil {
 		t.Fatalf("expected err")
 	}
-}
 
-func Test_fig_setValue(t *testing.T) {
-	fig := defaultFig()
+	for _, want := range []string{
+		`port: cannot parse "eighty" as int (from env MYAPP_PORT): invalid syntax`,
+		`timeout: cannot parse "5x" as time.Duration (from default): time: unknown unit "x" in duration "5x"`,
+		`quotas: cannot parse "ten" as int (from env MYAPP_QUOTAS_FREE): invalid syntax`,
+		`pin: cannot parse *** as int (from env MYAPP_PIN)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("want err to contain %q, got %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "12a4") {
+		t.Errorf("secret value in err: %v", err)
+	}
 
-	t.Run("nil ptr", func(t *testing.T) {
-		var s *string
-		fv := reflect.ValueOf(&s)
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("want a *FieldError, got %T", err)
+	}
+	if fe.Path != "pin" || fe.Source != SourceEnv || fe.Env != "MYAPP_PIN" || fe.Type != reflect.TypeOf(0) {
+		t.Errorf("got %+v", fe)
+	}
+
+	t.Run("from file", func(t *testing.T) {
+		type Server struct {
+			Port int `fig:"port"`
+		}
+		var cfg struct {
+			Port    int            `fig:"port"`
+			Timeout time.Duration  `fig:"timeout"`
+			Quotas  map[string]int `fig:"quotas"`
+			Pin     int            `fig:"pin,secret"`
+			Servers []Server       `fig:"servers"`
+		}
+		data := "port: eighty\ntimeout: 5x\nquotas:\n  free: ten\npin: 12a4\nservers:\n  - port: 1\n  - port: two\n"
+		err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml))
+		if err == nil {
+			t.Fatalf("expected err")
+		}
+
+		for _, want := range []string{
+			`port: cannot parse "eighty" as int (from file): invalid syntax`,
+			`timeout: cannot parse "5x" as time.Duration (from file): time: unknown unit "x" in duration "5x"`,
+			`quotas[free]: cannot parse "ten" as int (from file): invalid syntax`,
+			`pin: cannot parse *** as int (from file)`,
+			`servers[1].port: cannot parse "two" as int (from file): invalid syntax`,
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("want err to contain %q, got %v", want, err)
+			}
+		}
+		if strings.Contains(err.Error(), "12a4") {
+			t.Errorf("secret value in err: %v", err)
+		}
+
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			t.Fatalf("want a *FieldError, got %T", err)
+		}
+		if fe.Source != SourceFile || fe.Path == "" || fe.Type == nil {
+			t.Errorf("got %+v", fe)
+		}
+
+		var me *mapstructure.Error
+		if !errors.As(err, &me) || len(me.Errors) != 5 {
+			t.Errorf("want the mapstructure error to be reachable, got %v", me)
+		}
+	})
 
-		err := fig.setValue(fv, "bat")
-		if err != nil {
+	t.Run("from named file", func(t *testing.T) {
+		var cfg struct {
+			Port int `fig:"port"`
+		}
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte("port: eighty\n"), 0o600); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
 
-		if *s != "bat" {
-			t.Fatalf("want %s, got %s", "bat", *s)
+		err := Load(&cfg, Dirs(dir))
+		var fe *FieldError
+		if !errors.As(err, &fe) || fe.Path != "port" || fe.Value != "eighty" || fe.Type != reflect.TypeOf(0) || fe.File != path {
+			t.Fatalf("want a *FieldError for port, got %v", err)
+		}
+		if want := fmt.Sprintf(`port: cannot parse "eighty" as int (from file %s): invalid syntax`, path); err.Error() != want {
+			t.Errorf("want err %q, got %q", want, err.Error())
 		}
 	})
+}
 
-	t.Run("slice", func(t *testing.T) {
-		var slice []int
-		fv := reflect.ValueOf(&slice).Elem()
+func Test_fig_Load_Hex(t *testing.T) {
+	type Config struct {
+		Key   [4]byte   `fig:"key,hex" default:"0a1b2c3d"`
+		Salt  []byte    `fig:"salt,hex"`
+		IV    *[2]byte  `fig:"iv,hex"`
+		Peers [][2]byte `fig:"peers,hex"`
+	}
 
-		err := fig.setValue(fv, "5")
-		if err != nil {
+	t.Run("sources", func(t *testing.T) {
+		setenv(t, "IV", "ffee")
+
+		var cfg Config
+		data := "salt: deadbeef\npeers: [\"0001\", abcd]\n"
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("")); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
 
-		if !reflect.DeepEqual([]int{5}, slice) {
-			t.Fatalf("want %+v, got %+v", []int{5}, slice)
+		want := Config{
+			Key:   [4]byte{0x0a, 0x1b, 0x2c, 0x3d},
+			Salt:  []byte{0xde, 0xad, 0xbe, 0xef},
+			IV:    &[2]byte{0xff, 0xee},
+			Peers: [][2]byte{{0x00, 0x01}, {0xab, 0xcd}},
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
-	})
-
-	t.Run("int", func(t *testing.T) {
-		var i int
-		fv := reflect.ValueOf(&i).Elem()
 
-		err := fig.setValue(fv, "-8")
-		if err != nil {
+		var buf bytes.Buffer
+		if err := Dump(&cfg, &buf, DecoderYaml); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
-
-		if i != -8 {
-			t.Fatalf("want %d, got %d", -8, i)
+		if !strings.Contains(buf.String(), "key: 0a1b2c3d") || !strings.Contains(buf.String(), "salt: deadbeef") {
+			t.Errorf("want hex in dump, got:\n%s", buf.String())
 		}
 	})
 
-	t.Run("bool", func(t *testing.T) {
-		var b bool
-		fv := reflect.ValueOf(&b).Elem()
+	for _, tc := range []struct {
+		name    string
+		data    string
+		env     string
+		wantErr string
+	}{
+		{name: "odd length", data: "salt: abc\n", wantErr: "salt: invalid hex: encoding/hex: odd length hex string"},
+		{name: "non hex", env: "zz", wantErr: `invalid hex: encoding/hex: invalid byte: U+007A 'z'`},
+		{name: "array length", data: "key: 0a1b\n", wantErr: "key: hex decodes to 2 bytes, want 4 for [4]uint8"},
+		{name: "not a string", data: "peers: [0001]\n", wantErr: "peers[0]: hex value must be a string, got 1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				setenv(t, "SALT", tc.env)
+			}
+			var cfg Config
+			err := Load(&cfg, Reader(strings.NewReader(tc.data+"iv: null\n"), DecoderYaml), UseEnv(""))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("want err containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
 
-		err := fig.setValue(fv, "true")
-		if err != nil {
+func Test_fig_Load_NormalizeMapKeys(t *testing.T) {
+	type Env struct {
+		Replicas int               `fig:"replicas"`
+		Labels   map[string]string `fig:"labels"`
+	}
+	type Config struct {
+		Limits map[string]int            `fig:"limits"`
+		Envs   map[string]Env            `fig:"envs"`
+		Nested map[string]map[string]int `fig:"nested"`
+		Name   string                    `fig:"name"`
+	}
+
+	t.Run("rewrites map keys", func(t *testing.T) {
+		data := `
+Name: app
+limits:
+  Prod: 10
+  staging: 5
+envs:
+  PROD:
+    Replicas: 3
+    labels:
+      Team: core
+nested:
+  EU:
+    West: 1
+`
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), NormalizeMapKeys(strings.ToLower)); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
 
-		if !b {
-			t.Fatalf("want true")
+		want := Config{
+			Limits: map[string]int{"prod": 10, "staging": 5},
+			Envs:   map[string]Env{"prod": {Replicas: 3, Labels: map[string]string{"team": "core"}}},
+			Nested: map[string]map[string]int{"eu": {"west": 1}},
+			Name:   "app",
 		}
-	})
-
-	t.Run("bad bool", func(t *testing.T) {
-		var b bool
-		fv := reflect.ValueOf(&b).Elem()
-
-		err := fig.setValue(fv, "αλήθεια")
-		if err == nil {
-			t.Fatalf("returned nil err")
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
 	})
 
-	t.Run("duration", func(t *testing.T) {
-		var d time.Duration
-		fv := reflect.ValueOf(&d).Elem()
-
-		err := fig.setValue(fv, "5h")
-		if err != nil {
+	t.Run("keys kept without option", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader("limits:\n  Prod: 10\n"), DecoderYaml)); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
-
-		if d.Hours() != 5 {
-			t.Fatalf("want %v, got %v", 5*time.Hour, d)
+		if want := map[string]int{"Prod": 10}; !reflect.DeepEqual(want, cfg.Limits) {
+			t.Errorf("want %v, got %v", want, cfg.Limits)
 		}
 	})
 
-	t.Run("bad duration", func(t *testing.T) {
-		var d time.Duration
-		fv := reflect.ValueOf(&d).Elem()
+	t.Run("colliding keys are folded", func(t *testing.T) {
+		data := `
+limits:
+  Prod: 10
+  PROD: 20
+  prod: 30
+envs:
+  PROD:
+    replicas: 3
+    labels:
+      Team: core
+  prod:
+    labels:
+      team: infra
+      tier: web
+nested:
+  EU:
+    West: 1
+  eu:
+    east: 2
+`
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), NormalizeMapKeys(strings.ToLower)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
 
-		err := fig.setValue(fv, "5decades")
-		if err == nil {
-			t.Fatalf("expexted err")
+		want := Config{
+			Limits: map[string]int{"prod": 30},
+			Envs:   map[string]Env{"prod": {Replicas: 3, Labels: map[string]string{"team": "infra", "tier": "web"}}},
+			Nested: map[string]map[string]int{"eu": {"west": 1, "east": 2}},
+		}
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
 	})
+}
 
-	t.Run("uint", func(t *testing.T) {
-		var i uint
-		fv := reflect.ValueOf(&i).Elem()
+func Test_fig_Load_Files(t *testing.T) {
+	type Config struct {
+		Host string `fig:"host"`
+		Port int    `fig:"port"`
+	}
 
-		err := fig.setValue(fv, "42")
-		if err != nil {
+	base, local := t.TempDir(), t.TempDir()
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
+	}
+	write(filepath.Join(base, "config.yaml"), "host: base\nport: 80\n")
 
-		if i != 42 {
-			t.Fatalf("want %d, got %d", 42, i)
+	t.Run("falls back to next filename", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Files("config.local.yaml", "config.yaml"), Dirs(local, base)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := (Config{Host: "base", Port: 80}); want != cfg {
+			t.Errorf("want %+v, got %+v", want, cfg)
 		}
 	})
 
-	t.Run("float", func(t *testing.T) {
-		var f float32
-		fv := reflect.ValueOf(&f).Elem()
+	t.Run("first existing file replaces the others", func(t *testing.T) {
+		write(filepath.Join(local, "config.local.json"), `{"host": "local"}`)
 
-		err := fig.setValue(fv, "0.015625")
-		if err != nil {
+		var cfg Config
+		if err := Load(&cfg, Files("config.local.json", "config.yaml"), Dirs(base, local)); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
-
-		if f != 0.015625 {
-			t.Fatalf("want %f, got %f", 0.015625, f)
+		if want := (Config{Host: "local"}); want != cfg {
+			t.Errorf("want %+v, got %+v", want, cfg)
 		}
 	})
 
-	t.Run("bad float", func(t *testing.T) {
-		var f float32
-		fv := reflect.ValueOf(&f).Elem()
-
-		err := fig.setValue(fv, "-i")
-		if err == nil {
-			t.Fatalf("expected err")
+	t.Run("last of File and Files wins", func(t *testing.T) {
+		var cfg Config
+		if err := Load(&cfg, Files("config.local.json"), File("config.yaml"), Dirs(local, base)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if want := (Config{Host: "base", Port: 80}); want != cfg {
+			t.Errorf("want %+v, got %+v", want, cfg)
 		}
 	})
+}
 
-	t.Run("string", func(t *testing.T) {
-		var s string
-		fv := reflect.ValueOf(&s).Elem()
+func Test_fig_Load_Regexp(t *testing.T) {
+	type Config struct {
+		Value   regexp.Regexp  `fig:"value"`
+		Pointer *regexp.Regexp `fig:"pointer"`
+		Default regexp.Regexp  `fig:"default" default:"^v[0-9]+$"`
+		Slice   []regexp.Regexp
+	}
 
-		err := fig.setValue(fv, "bat")
-		if err != nil {
+	t.Run("from file", func(t *testing.T) {
+		data := "value: \"[a-z]+\"\npointer: \"^/api\"\nslice: [a, \"b+\"]\n"
+
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml)); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
 
-		if s != "bat" {
-			t.Fatalf("want %s, got %s", "bat", s)
+		if want := "[a-z]+"; cfg.Value.String() != want {
+			t.Errorf("cfg.Value == %q, expected %q", cfg.Value.String(), want)
+		}
+		if !cfg.Value.MatchString("abc") || cfg.Value.MatchString("123") {
+			t.Errorf("cfg.Value %q does not match as compiled", cfg.Value.String())
+		}
+		if want := "^/api"; cfg.Pointer == nil || cfg.Pointer.String() != want {
+			t.Errorf("cfg.Pointer == %v, expected %q", cfg.Pointer, want)
+		}
+		if want := "^v[0-9]+$"; cfg.Default.String() != want {
+			t.Errorf("cfg.Default == %q, expected %q", cfg.Default.String(), want)
+		}
+		if len(cfg.Slice) != 2 || cfg.Slice[0].String() != "a" || cfg.Slice[1].String() != "b+" {
+			t.Errorf("cfg.Slice == %v, expected [a b+]", cfg.Slice)
 		}
 	})
 
-	t.Run("time", func(t *testing.T) {
-		var tme time.Time
-		fv := reflect.ValueOf(&tme).Elem()
+	t.Run("from env", func(t *testing.T) {
+		setenv(t, "VALUE", "x{2}")
 
-		err := fig.setValue(fv, "2020-01-01T00:00:00Z")
-		if err != nil {
+		var cfg Config
+		if err := Load(&cfg, IgnoreFile(), UseEnv("")); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
-
-		want, err := time.Parse(fig.timeLayout, "2020-01-01T00:00:00Z")
-		if err != nil {
-			t.Fatalf("error parsing time: %v", err)
+		if want := "x{2}"; cfg.Value.String() != want {
+			t.Errorf("cfg.Value == %q, expected %q", cfg.Value.String(), want)
 		}
+	})
 
-		if !tme.Equal(want) {
-			t.Fatalf("want %v, got %v", want, tme)
+	t.Run("bad pattern in file", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("value: \"[a-\"\n"), DecoderYaml))
+		if err == nil || !strings.Contains(err.Error(), "missing closing ]") {
+			t.Fatalf("want missing closing ] err, got %v", err)
 		}
 	})
+}
 
-	t.Run("bad time", func(t *testing.T) {
-		var tme time.Time
-		fv := reflect.ValueOf(&tme).Elem()
+func Test_fig_Load_Transform(t *testing.T) {
+	type Config struct {
+		Host   string   `fig:"host" transform:"trim,lower"`
+		Region *string  `fig:"region" transform:"upper"`
+		Name   string   `fig:"name" transform:"title" default:"my  app"`
+		Tags   []string `fig:"tags" transform:"trim"`
+		Email  string   `fig:"email" transform:"lower" validate:"email"`
+	}
 
-		err := fig.setValue(fv, "2020-Feb-01T00:00:00Z")
-		if err == nil {
-			t.Fatalf("expected err")
-		}
-	})
+	t.Run("applied to every source", func(t *testing.T) {
+		setenv(t, "REGION", "eu-west")
 
-	t.Run("regexp", func(t *testing.T) {
-		var re regexp.Regexp
-		fv := reflect.ValueOf(&re).Elem()
+		data := "host: \" Example.COM \"\ntags: [\" a\", \"b \"]\nemail: ADMIN@Example.com\n"
 
-		err := fig.setValue(fv, "[a-z]+")
-		if err != nil {
+		var cfg Config
+		if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), UseEnv("")); err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
 
-		if want := regexp.MustCompile("[a-z]+"); re.String() != want.String() {
-			t.Fatalf("want %v, got %v", want, re)
+		region := "EU-WEST"
+		want := Config{
+			Host:   "example.com",
+			Region: &region,
+			Name:   "My  App",
+			Tags:   []string{"a", "b"},
+			Email:  "admin@example.com",
 		}
-	})
-
-	t.Run("bad regexp", func(t *testing.T) {
-		var re regexp.Regexp
-		fv := reflect.ValueOf(&re).Elem()
-
-		err := fig.setValue(fv, "[a-")
-		if err == nil {
-			t.Fatalf("expected err")
+		if !reflect.DeepEqual(want, cfg) {
+			t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 		}
 	})
 
-	t.Run("interface returns error", func(t *testing.T) {
-		var i interface{}
-		fv := reflect.ValueOf(i)
-
-		err := fig.setValue(fv, "empty")
-		if err == nil {
-			t.Fatalf("expected err")
+	t.Run("unknown transform", func(t *testing.T) {
+		var cfg struct {
+			Host string `fig:"host" transform:"snake"`
+		}
+		err := Load(&cfg, IgnoreFile())
+		if err == nil || !strings.Contains(err.Error(), `host: unknown transform "snake"`) {
+			t.Fatalf("want unknown transform err, got %v", err)
 		}
 	})
 
-	t.Run("struct returns error", func(t *testing.T) {
-		s := struct{ Name string }{}
-		fv := reflect.ValueOf(&s).Elem()
-
-		err := fig.setValue(fv, "foo")
-		if err == nil {
-			t.Fatalf("expected err")
+	t.Run("unsupported type", func(t *testing.T) {
+		var cfg struct {
+			Port int `fig:"port" transform:"trim"`
+		}
+		err := Load(&cfg, IgnoreFile())
+		if err == nil || !strings.Contains(err.Error(), "port: transform is not supported on type int") {
+			t.Fatalf("want unsupported type err, got %v", err)
 		}
 	})
 }
 
-func Test_fig_setSlice(t *testing.T) {
-	f := defaultFig()
+func Test_fig_Load_TopLevelList(t *testing.T) {
+	type Job struct {
+		Name     string        `fig:"name" validate:"required"`
+		Interval time.Duration `fig:"interval" default:"1m"`
+	}
+	type Config struct {
+		Jobs  []Job  `fig:"jobs"`
+		Level string `fig:"level" default:"info"`
+	}
 
 	for _, tc := range []struct {
-		Name      string
-		InSlice   interface{}
-		WantSlice interface{}
-		Val       string
+		decoder Decoder
+		data    string
 	}{
-		{
-			Name:      "ints",
-			InSlice:   &[]int{},
-			WantSlice: &[]int{5, 10, 15},
-			Val:       "[5,10,15]",
-		},
-		{
-			Name:      "ints-no-square-braces",
-			InSlice:   &[]int{},
-			WantSlice: &[]int{5, 10, 15},
-			Val:       "5,10,15",
-		},
-		{
-			Name:      "uints",
-			InSlice:   &[]uint{},
-			WantSlice: &[]uint{5, 10, 15, 20, 25},
-			Val:       "[5,10,15,20,25]",
-		},
-		{
-			Name:      "floats",
-			InSlice:   &[]float32{},
-			WantSlice: &[]float32{1.5, 1.125, -0.25},
-			Val:       "[1.5,1.125,-0.25]",
-		},
-		{
-			Name:      "strings",
-			InSlice:   &[]string{},
-			WantSlice: &[]string{"a", "b", "c", "d"},
-			Val:       "[a,b,c,d]",
-		},
-		{
-			Name:      "durations",
-			InSlice:   &[]time.Duration{},
-			WantSlice: &[]time.Duration{30 * time.Minute, 2 * time.Hour},
-			Val:       "[30m,2h]",
-		},
-		{
-			Name:    "times",
-			InSlice: &[]time.Time{},
-			WantSlice: &[]time.Time{
-				time.Date(2019, 12, 25, 10, 30, 30, 0, time.UTC),
-				time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			Val: "[2019-12-25T10:30:30Z,2020-01-01T00:00:00Z]",
-		},
-		{
-			Name:    "regexps",
-			InSlice: &[]*regexp.Regexp{},
-			WantSlice: &[]*regexp.Regexp{
-				regexp.MustCompile("[a-z]+"),
-				regexp.MustCompile(".*"),
-			},
-			Val: "[[a-z]+,.*]",
-		},
+		{decoder: DecoderYaml, data: "- name: backup\n  interval: 1h\n- name: cleanup\n"},
+		{decoder: DecoderJSON, data: `[{"name": "backup", "interval": "1h"}, {"name": "cleanup"}]`},
 	} {
-		t.Run(tc.Val, func(t *testing.T) {
-			in := reflect.ValueOf(tc.InSlice).Elem()
-
-			err := f.setSlice(in, tc.Val)
-			if err != nil {
-				t.Fatalf("unexpected error: %v", err)
+		t.Run(string(tc.decoder), func(t *testing.T) {
+			var cfg Config
+			if err := Load(&cfg, Reader(strings.NewReader(tc.data), tc.decoder)); err != nil {
+				t.Fatalf("unexpected err: %v", err)
 			}
 
-			want := reflect.ValueOf(tc.WantSlice).Elem()
-
-			if !reflect.DeepEqual(want.Interface(), in.Interface()) {
-				t.Fatalf("want %+v, got %+v", want, in)
+			want := Config{
+				Jobs:  []Job{{Name: "backup", Interval: time.Hour}, {Name: "cleanup", Interval: time.Minute}},
+				Level: "info",
+			}
+			if !reflect.DeepEqual(want, cfg) {
+				t.Errorf("\nwant %+v\ngot  %+v", want, cfg)
 			}
 		})
 	}
 
-	t.Run("negative int into uint returns error", func(t *testing.T) {
-		in := &[]uint{}
-		val := "[-5]"
+	t.Run("validates elements", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("- interval: 1h\n"), DecoderYaml))
+		if err == nil || !strings.Contains(err.Error(), "jobs[0].name: required validation failed") {
+			t.Fatalf("want required err, got %v", err)
+		}
+	})
+
+	t.Run("struct without a single slice field", func(t *testing.T) {
+		var cfg struct {
+			Jobs  []Job
+			Hosts []string
+		}
+		err := Load(&cfg, Reader(strings.NewReader("- name: backup\n"), DecoderYaml))
+		if err == nil || !strings.Contains(err.Error(), "can only be loaded into a struct with a single slice field") {
+			t.Fatalf("want single slice field err, got %v", err)
+		}
+	})
+}
+
+// crashy is a StringUnmarshaler that panics on the value "crash".
+type crashy string
+
+func (c *crashy) UnmarshalString(v string) error {
+	if v == "crash" {
+		var m map[string]string
+		m["x"] = v // assignment to entry in nil map
+	}
+	*c = crashy(v)
+	return nil
+}
+
+func Test_fig_Load_RecoversPanics(t *testing.T) {
+	type Config struct {
+		A    crashy `fig:"a"`
+		B    crashy `fig:"b" default:"crash"`
+		Port int    `fig:"port" validate:"required"`
+		Host string `fig:"host" default:"localhost"`
+	}
+
+	t.Run("from defaults and env", func(t *testing.T) {
+		setenv(t, "A", "crash")
 
-		err := f.setSlice(reflect.ValueOf(in).Elem(), val)
+		var cfg Config
+		err := Load(&cfg, IgnoreFile(), UseEnv(""))
 		if err == nil {
 			t.Fatalf("expected err")
 		}
+
+		fieldErrs, ok := err.(fieldErrors)
+		if !ok {
+			t.Fatalf("want fieldErrors, got %T: %v", err, err)
+		}
+		for _, path := range []string{"a", "b", "port"} {
+			if _, ok := fieldErrs[path]; !ok {
+				t.Errorf("want err for %s, got %v", path, err)
+			}
+		}
+		if want := "panic: assignment to entry in nil map"; !strings.Contains(fieldErrs["b"].Error(), want) {
+			t.Errorf("want err containing %q, got %v", want, fieldErrs["b"])
+		}
+		if cfg.Host != "localhost" {
+			t.Errorf("want remaining fields processed, got host %q", cfg.Host)
+		}
 	})
-}
 
-func setenv(t *testing.T, key, value string) {
-	t.Helper()
-	t.Setenv(key, value)
+	t.Run("from file", func(t *testing.T) {
+		var cfg Config
+		err := Load(&cfg, Reader(strings.NewReader("a: crash\nb: ok\nport: 80\n"), DecoderYaml))
+		if err == nil || !strings.Contains(err.Error(), "panic: assignment to entry in nil map") {
+			t.Fatalf("want panic err, got %v", err)
+		}
+	})
 }
@@ -0,0 +1,66 @@
+package fig
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// VersionKey is the top-level key of the config file that holds the version of
+// the config, which is used to pick the migrations registered with Migration.
+const VersionKey = "version"
+
+// MigrationFunc migrates the values of a config from one version to the next.
+type MigrationFunc func(vals map[string]interface{}) (map[string]interface{}, error)
+
+// currentVersion returns the version that configs are migrated to, which
+// is the version after that of the latest migration.
+func (f *fig) currentVersion() int {
+	current := 0
+	for from := range f.migrations {
+		if from+1 > current {
+			current = from + 1
+		}
+	}
+	return current
+}
+
+// migrate applies the migrations needed to bring vals from the version in
+// its version key up to the current version, in sequence. vals is returned
+// as is if no migrations are registered or it has no version key, in which
+// case it is taken to be of the current version.
+func (f *fig) migrate(vals map[string]interface{}) (map[string]interface{}, error) {
+	if len(f.migrations) == 0 {
+		return vals, nil
+	}
+	v, ok := vals[VersionKey]
+	if !ok {
+		return vals, nil
+	}
+
+	version, err := strconv.Atoi(fmt.Sprint(v))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config version %v", v)
+	}
+
+	current := f.currentVersion()
+	if version > current {
+		return nil, fmt.Errorf("config version %d is newer than the current version %d", version, current)
+	}
+
+	for ; version < current; version++ {
+		fn, ok := f.migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from config version %d to %d", version, version+1)
+		}
+		if vals, err = fn(vals); err != nil {
+			return nil, fmt.Errorf("migrate config from version %d: %w", version, err)
+		}
+		if vals == nil {
+			vals = make(map[string]interface{})
+		}
+		vals[VersionKey] = version + 1
+		f.logf("migrated config from version %d to %d", version, version+1)
+	}
+
+	return vals, nil
+}
@@ -0,0 +1,122 @@
+package fig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigration(t *testing.T) {
+	type Config struct {
+		Version int    `fig:"version"`
+		Listen  string `fig:"listen"`
+		Level   string `fig:"log_level"`
+	}
+
+	var calls []int
+	migrations := []Option{
+		// version 1 gave the port alone
+		Migration(1, func(vals map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, 1)
+			vals["listen"] = fmt.Sprintf(":%v", vals["port"])
+			delete(vals, "port")
+			return vals, nil
+		}),
+		// version 2 called the log level "level"
+		Migration(2, func(vals map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, 2)
+			vals["log_level"] = vals["level"]
+			delete(vals, "level")
+			return vals, nil
+		}),
+	}
+
+	for _, tc := range []struct {
+		name      string
+		data      string
+		want      Config
+		wantCalls []int
+	}{
+		{
+			name:      "from version 1",
+			data:      "version: 1\nport: 8080\nlevel: debug\n",
+			want:      Config{Version: 3, Listen: ":8080", Level: "debug"},
+			wantCalls: []int{1, 2},
+		},
+		{
+			name:      "from version 2",
+			data:      "version: \"2\"\nlisten: :80\nlevel: warn\n",
+			want:      Config{Version: 3, Listen: ":80", Level: "warn"},
+			wantCalls: []int{2},
+		},
+		{
+			name: "current version",
+			data: "version: 3\nlisten: :80\nlog_level: info\n",
+			want: Config{Version: 3, Listen: ":80", Level: "info"},
+		},
+		{
+			name: "no version",
+			data: "listen: :80\n",
+			want: Config{Listen: ":80"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+
+			var cfg Config
+			options := append([]Option{Reader(strings.NewReader(tc.data), DecoderYaml), UseStrict()}, migrations...)
+			if err := Load(&cfg, options...); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if cfg != tc.want {
+				t.Errorf("want %+v, got %+v", tc.want, cfg)
+			}
+			if !reflect.DeepEqual(tc.wantCalls, calls) {
+				t.Errorf("want migrations %v, got %v", tc.wantCalls, calls)
+			}
+		})
+	}
+
+	boom := errors.New("boom")
+	for _, tc := range []struct {
+		name    string
+		data    string
+		options []Option
+		wantErr string
+	}{
+		{
+			name:    "missing migration",
+			data:    "version: 0\n",
+			wantErr: "no migration from config version 0 to 1",
+		},
+		{
+			name:    "newer version",
+			data:    "version: 4\n",
+			wantErr: "config version 4 is newer than the current version 3",
+		},
+		{
+			name:    "invalid version",
+			data:    "version: v1\n",
+			wantErr: "invalid config version v1",
+		},
+		{
+			name: "migration fails",
+			data: "version: 2\n",
+			options: []Option{Migration(2, func(map[string]interface{}) (map[string]interface{}, error) {
+				return nil, boom
+			})},
+			wantErr: "migrate config from version 2: boom",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			options := append([]Option{Reader(strings.NewReader(tc.data), DecoderYaml)}, migrations...)
+			err := Load(&cfg, append(options, tc.options...)...)
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("want err %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,5 +1,15 @@
 package fig
 
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
 // Option configures how fig loads the configuration.
 type Option func(f *fig)
 
@@ -7,14 +17,39 @@ type Option func(f *fig)
 // looks for to provide the config values.
 //
 // The name must include the extension of the file. Supported
-// file types are `yaml`, `yml`, `json` and `toml`.
+// file types are `yaml`, `yml`, `json` and `toml`. Files that are
+// compressed with gzip are supported by appending a `.gz` extension
+// to the name.
 //
 //	fig.Load(&cfg, fig.File("config.toml"))
+//	fig.Load(&cfg, fig.File("config.yaml.gz"))
 //
 // If this option is not used then fig looks for a file with name `config.yaml`.
 func File(name string) Option {
 	return func(f *fig) {
 		f.filename = name
+		f.filenames = nil
+		f.fileSet = true
+	}
+}
+
+// Files returns an option that configures several filenames that fig looks for
+// to provide the config values, in order of priority. The first file that exists
+// is used and the others are ignored, files are never merged.
+//
+//	fig.Load(&cfg, fig.Files("config.local.yaml", "config.yaml"))
+//
+// Every directory given by `Dirs` is searched for a filename before the next
+// filename is tried, so a `config.local.yaml` in any of the directories is picked
+// over a `config.yaml` in the first one. Each name must include the extension of
+// the file in the same way as with `File`, and the names may be of different file
+// types.
+//
+// This option overrides `File`, and vice versa, depending on which is given last.
+func Files(names ...string) Option {
+	return func(f *fig) {
+		f.filenames = names
+		f.fileSet = true
 	}
 }
 
@@ -31,6 +66,70 @@ func IgnoreFile() Option {
 	}
 }
 
+// URL returns an option that configures fig to fetch the config file from the
+// given URL using an HTTP GET request instead of looking for it on the file system.
+//
+//	fig.Load(&cfg, fig.URL("https://config.internal/myapp.yaml"))
+//
+// The decoder is picked based on the extension of the URL's path, so the same file
+// types as `File` are supported. A response with a non-2xx status code results in
+// an error.
+//
+// This option renders any `File` and `Dirs` options useless. The client used to
+// fetch the file can be configured with `HTTPClient`.
+func URL(url string) Option {
+	return func(f *fig) {
+		f.url = url
+	}
+}
+
+// Reader returns an option that configures fig to read the config from r using
+// the given decoder instead of looking for a config file.
+//
+//	fig.Load(&cfg, fig.Reader(strings.NewReader(data), fig.DecoderYaml))
+//
+// With `DecoderAuto` the format is guessed from the first line of r that is
+// neither blank nor a `#` comment. A line starting with `{`, or with `[` unless
+// it's a toml table header such as `[server]`, is json and a line of the form
+// `key = value` is toml. Anything else is decoded as yaml, which as a superset of
+// json is the safest guess for ambiguous content.
+//
+//	fig.Load(&cfg, fig.Reader(os.Stdin, fig.DecoderAuto))
+//
+// This option renders any `File`, `Dirs` and `URL` options useless.
+func Reader(r io.Reader, decoder Decoder) Option {
+	return func(f *fig) {
+		f.reader = r
+		f.readerDecoder = decoder
+		f.readerName = ""
+	}
+}
+
+// ReaderNamed is like `Reader` but picks the decoder based on the extension of
+// name, as if r were the contents of a config file with that name.
+//
+//	fig.Load(&cfg, fig.ReaderNamed(upload, header.Filename))
+//
+// The same file types as `File` are supported, including gzip compressed files.
+func ReaderNamed(r io.Reader, name string) Option {
+	return func(f *fig) {
+		f.reader = r
+		f.readerName = name
+	}
+}
+
+// HTTPClient returns an option that configures the client that fig uses to fetch
+// the config file when the `URL` option is used.
+//
+//	fig.Load(&cfg, fig.URL(u), fig.HTTPClient(&http.Client{Timeout: 5 * time.Second}))
+//
+// If this option is not used then fig uses a client with a timeout of `DefaultHTTPTimeout`.
+func HTTPClient(client *http.Client) Option {
+	return func(f *fig) {
+		f.httpClient = client
+	}
+}
+
 // Dirs returns an option that configures the directories that fig searches
 // to find the configuration file.
 //
@@ -44,6 +143,235 @@ func IgnoreFile() Option {
 func Dirs(dirs ...string) Option {
 	return func(f *fig) {
 		f.dirs = dirs
+		f.fileSet = true
+	}
+}
+
+// MaxFileSize returns an option that limits the size of the config that fig reads
+// to the given number of bytes. A config that is larger results in an error.
+//
+//	fig.Load(&cfg, fig.MaxFileSize(1<<20))
+//
+// The limit applies to config files, URLs and readers alike. The size of a gzip
+// compressed file is measured after decompression.
+//
+// If this option is not used then the size of the config is not limited.
+func MaxFileSize(bytes int64) Option {
+	return func(f *fig) {
+		f.maxFileSize = bytes
+	}
+}
+
+// Encoding returns an option that configures fig to decode the config from the
+// given character encoding, converting it to UTF-8 before the format decoder
+// runs.
+//
+//	fig.Load(&cfg, fig.Encoding("iso-8859-1"))
+//
+// Supported encodings are utf-8, iso-8859-1 (also latin1) and windows-1252 (also
+// cp1252), matched ignoring case, dashes and underscores. An unsupported encoding
+// results in an error when the config is read. The encoding applies to config
+// files, URLs and readers alike.
+//
+// If this option is not used then the config is assumed to be UTF-8.
+func Encoding(charset string) Option {
+	return func(f *fig) {
+		f.encoding = charset
+	}
+}
+
+// RejectDuplicateKeys returns an option that configures fig to return an error
+// if an object of a json config contains the same key more than once, rather
+// than taking the value of the last one.
+//
+//	fig.Load(&cfg, fig.RejectDuplicateKeys())
+//
+// The error names the path of the duplicated key. Yaml and toml configs always
+// reject duplicate keys and are unaffected by this option.
+func RejectDuplicateKeys() Option {
+	return func(f *fig) {
+		f.rejectDuplicates = true
+	}
+}
+
+// YAMLDocument returns an option that configures fig to load the document at
+// index, counting from zero, of a yaml config that contains multiple documents
+// separated by "---".
+//
+//	fig.Load(&cfg, fig.YAMLDocument(1))
+//
+// It is an error if the config has no document at index. Configs in any other
+// format are unaffected by this option.
+//
+// If neither this option nor YAMLDocumentWhere is used then the first document
+// is loaded.
+func YAMLDocument(index int) Option {
+	return func(f *fig) {
+		f.yamlDoc = &yamlDocSelector{index: index}
+	}
+}
+
+// YAMLDocumentWhere returns an option that configures fig to load the first
+// document of a multi-document yaml config whose top-level key has the given
+// value. Values that are not strings are compared by their default formatting,
+// e.g. "true" or "3".
+//
+//	fig.Load(&cfg, fig.YAMLDocumentWhere("env", "prod"))
+//
+// It is an error if no document matches. Configs in any other format are
+// unaffected by this option.
+func YAMLDocumentWhere(key, value string) Option {
+	return func(f *fig) {
+		f.yamlDoc = &yamlDocSelector{key: key, value: value}
+	}
+}
+
+// DefaultsFile returns an option that configures fig to load default values from
+// the given file, over which the values of the config are merged. Maps that are
+// in both are merged recursively, while any other value of the config replaces
+// the value of the defaults file.
+//
+//	fig.Load(&cfg, fig.File("config.yaml"), fig.DefaultsFile("defaults.yaml"))
+//
+// The path of the defaults file is used as given and is not searched for in the
+// directories of the Dirs option. It is an error if the file does not exist. The
+// defaults file is loaded even if IgnoreFile is used. Defaults given in struct
+// tags still apply to fields that are left zero after the merge.
+func DefaultsFile(path string) Option {
+	return func(f *fig) {
+		f.defaultsFile = path
+		f.defaultsFS = nil
+	}
+}
+
+// DefaultsFS returns an option that is like DefaultsFile but reads the defaults
+// file named name from fsys, such as an embed.FS that ships the defaults inside
+// the binary.
+//
+//	//go:embed defaults.yaml
+//	var defaults embed.FS
+//
+//	fig.Load(&cfg, fig.DefaultsFS(defaults, "defaults.yaml"))
+//
+// The decoder is picked based on the extension of name, so the same file types
+// as `File` are supported. It is an error if the file does not exist in fsys.
+// This option overrides `DefaultsFile`, and vice versa, depending on which is
+// given last.
+func DefaultsFS(fsys fs.FS, name string) Option {
+	return func(f *fig) {
+		f.defaultsFile = name
+		f.defaultsFS = fsys
+	}
+}
+
+// RetainYAMLNode returns an option that configures fig to store the node of the
+// yaml document that it loads in node, in addition to loading it into the
+// struct. The node holds the document's comments and layout, so it can be used
+// to edit the config with SetField and write it back with WriteYAML.
+//
+//	var node yaml.Node
+//	err := fig.Load(&cfg, fig.File("config.yaml"), fig.RetainYAMLNode(&node))
+//	...
+//	err = fig.SetField(&node, "server.port", 8443)
+//	err = fig.WriteYAML(&node, f)
+//
+// node is left as is if the config is not yaml. With SystemThenUser the node
+// is that of the user file if it exists.
+func RetainYAMLNode(node *yaml.Node) Option {
+	return func(f *fig) {
+		f.yamlNode = node
+	}
+}
+
+// SystemThenUser returns an option that configures fig to load the config file
+// from both the system-wide and the user's config directory of app, with the
+// values of the user file merged over those of the system file.
+//
+//	// loads /etc/myapp/config.yaml, then ~/.config/myapp/config.yaml
+//	fig.Load(&cfg, fig.SystemThenUser("myapp"))
+//
+// The user's config directory is the one returned by os.UserConfigDir. The file
+// name is set with `File` and defaults to config.yaml. Maps present in both files
+// are merged key by key while all other values, including slices, in the user file
+// replace those in the system file.
+//
+// Neither file is required to exist, unless `RequireFile` is used. If neither
+// does then the struct is only filled from the environment and defaults. The
+// directories given with `Dirs` are not searched when this option is used.
+func SystemThenUser(app string) Option {
+	return func(f *fig) {
+		f.systemUserApp = app
+	}
+}
+
+// RequireFile returns an option that configures fig to fail if no config file
+// is found even when the file would otherwise be optional, as it is with
+// `SystemThenUser`.
+//
+//	fig.Load(&cfg, fig.SystemThenUser("myapp"), fig.RequireFile())
+//
+// `Load` then returns an error wrapping `ErrFileNotFound` if neither the system
+// nor the user file exists. If this option is not used then only the options that
+// make the file optional let `Load` succeed without one.
+func RequireFile() Option {
+	return func(f *fig) {
+		f.requireFile = true
+	}
+}
+
+// SkipInvalidFiles returns an option that configures fig to skip a config file
+// that fails to decode and continue searching for the file in the next of the
+// directories given by `Dirs`. fn is called with the path of each file that is
+// skipped and the reason.
+//
+//	fig.Load(&cfg,
+//	  fig.Dirs("/etc/myapp", "/opt/myapp"),
+//	  fig.SkipInvalidFiles(func(path string, err error) {
+//	    log.Printf("skipping %s: %v", path, err)
+//	  }),
+//	)
+//
+// If every config file that is found fails to decode then `Load` returns an error
+// wrapping the last decode error. If this option is not used then a file that
+// fails to decode results in an error straight away.
+func SkipInvalidFiles(fn func(path string, err error)) Option {
+	return func(f *fig) {
+		f.onInvalidFile = fn
+	}
+}
+
+// Subtree returns an option that configures fig to load only the subtree of the
+// config file found under the given key into the struct, ignoring the rest of
+// the file. Nested keys are separated by a dot.
+//
+//	# config.yaml
+//	database:
+//	  host: db.internal
+//	server:
+//	  port: 8080
+//
+//	type DBConfig struct {
+//	  Host string `fig:"host"`
+//	}
+//
+//	fig.Load(&cfg, fig.Subtree("database"))
+//
+// If the subtree does not exist in the config file then `Load` returns an error
+// wrapping `ErrSubtreeNotFound`, unless the `AllowMissingSubtree` option is used.
+func Subtree(key string) Option {
+	return func(f *fig) {
+		f.subtree = key
+	}
+}
+
+// AllowMissingSubtree returns an option that configures fig to treat the subtree
+// given by the `Subtree` option as empty if it does not exist in the config
+// file, instead of returning an error.
+//
+//	fig.Load(&cfg, fig.Subtree("database"), fig.AllowMissingSubtree())
+func AllowMissingSubtree() Option {
+	return func(f *fig) {
+		f.allowMissingSubtree = true
 	}
 }
 
@@ -65,12 +393,31 @@ func Tag(tag string) Option {
 //	fig.Load(&cfg, fig.TimeLayout("2006-01-02"))
 //
 // If this option is not used then fig parses times using `time.RFC3339` layout.
+// A field's `timelayout` struct tag takes precedence over this option.
 func TimeLayout(layout string) Option {
 	return func(f *fig) {
 		f.timeLayout = layout
 	}
 }
 
+// TimeLayoutFromKey returns an option that configures fig to read the time layout
+// from the given top-level key of the config file. If the key is present then its
+// value is used instead of the layout given by `TimeLayout`, and the key itself is
+// not decoded into the struct.
+//
+//	// config.yaml
+//	_time_layout: "2006-01-02"
+//	release: "2024-03-01"
+//
+//	fig.Load(&cfg, fig.TimeLayoutFromKey("_time_layout"))
+//
+// A field's `timelayout` struct tag still takes precedence over the layout.
+func TimeLayoutFromKey(key string) Option {
+	return func(f *fig) {
+		f.timeLayoutKey = key
+	}
+}
+
 // UseEnv returns an option that configures fig to additionally load values
 // from the environment.
 //
@@ -106,15 +453,596 @@ func UseEnv(prefix string) Option {
 	}
 }
 
+// StrictTags returns an option that configures fig to check the struct tags of
+// every field before loading the config and return an error if any of them are
+// malformed, such as a misspelled validation.
+//
+//	type Config struct {
+//	  Host string `fig:"host" validate:"requird"` // results in an error
+//	}
+//
+//	fig.Load(&cfg, fig.StrictTags())
+//
+// If this option is not used then unknown flags and validations are ignored.
+func StrictTags() Option {
+	return func(f *fig) {
+		f.strictTags = true
+	}
+}
+
+// ValidateDefaults returns an option that configures fig to parse the default
+// value of every field before loading the config, regardless of whether the
+// field is later set, and return an error listing every default that fails to
+// parse.
+//
+//	type Config struct {
+//	  Port int `fig:"port" default:"not-an-int"` // results in an error, even if port is in the config file
+//	}
+//
+//	fig.Load(&cfg, fig.ValidateDefaults())
+//
+// Defaults are parsed into throwaway values so cfg is not modified by the check.
+// If this option is not used then a default is only parsed when it is set.
+func ValidateDefaults() Option {
+	return func(f *fig) {
+		f.validateDefaults = true
+	}
+}
+
+// NoDefaults returns an option that configures fig to not set the default
+// values of fields. The config file, the environment and validations are
+// processed as usual, so any field that the user did not set is left at its
+// zero value.
+//
+//	fig.Load(&cfg, fig.NoDefaults())
+//
+// This is useful to find out which values were explicitly configured.
+func NoDefaults() Option {
+	return func(f *fig) {
+		f.noDefaults = true
+	}
+}
+
+// TrimSpace returns an option that configures fig to trim leading and trailing
+// white space from string values, including the elements of string slices.
+//
+//	fig.Load(&cfg, fig.TrimSpace())
+//
+// Values are trimmed regardless of whether they are set from the config file,
+// the environment or a default.
+//
+// If this option is not used then string values are set as-is.
+func TrimSpace() Option {
+	return func(f *fig) {
+		f.trimSpace = true
+	}
+}
+
+// ExpandEnvDefaults returns an option that configures fig to expand references
+// to environment variables in default values before they are set.
+//
+//	type Config struct {
+//	  Paths []string `fig:"paths" default:"[${HOME}/bin,${GOPATH:-/go}/bin]"`
+//	}
+//
+//	fig.Load(&cfg, fig.ExpandEnvDefaults())
+//
+// References are of the form ${VAR}, or ${VAR:-fallback} to provide a value that
+// is used when the variable is unset or empty. Referencing a variable that is unset and
+// has no fallback results in an error. The elements of slice defaults are
+// expanded individually, so a variable whose value contains a comma does not
+// split the element.
+//
+// If this option is not used then default values are set as-is.
+func ExpandEnvDefaults() Option {
+	return func(f *fig) {
+		f.expandDefaults = true
+	}
+}
+
+// EnvPrefixes returns an option that configures fig to additionally load values
+// from the environment, like `UseEnv`, while searching for environment variables
+// with each of the given prefixes.
+//
+//	fig.Load(&cfg, fig.EnvPrefixes("myapp", "oldapp"))
+//
+// Prefixes are tried in the order given and the first variable that exists is
+// used, so with the option above MYAPP_LOG_LEVEL takes precedence over
+// OLDAPP_LOG_LEVEL. This is useful when migrating from one prefix to another.
+func EnvPrefixes(prefixes ...string) Option {
+	return func(f *fig) {
+		f.useEnv = true
+		f.envPrefix = ""
+		f.envPrefixes = nil
+		if len(prefixes) > 0 {
+			f.envPrefix = prefixes[0]
+			f.envPrefixes = prefixes[1:]
+		}
+	}
+}
+
+// BoolWords returns an option that configures fig to additionally accept the
+// words yes/no, on/off and enabled/disabled, in any case, as boolean values.
+//
+//	fig.Load(&cfg, fig.BoolWords())
+//
+// The words are accepted in the config file, the environment and defaults.
+//
+// If this option is not used then fig only accepts the values that are
+// accepted by strconv.ParseBool (1, t, T, TRUE, true, True, 0, f, F, FALSE, false, False).
+func BoolWords() Option {
+	return func(f *fig) {
+		f.boolWords = true
+	}
+}
+
+// AllowNumericSeparators returns an option that configures fig to accept
+// underscores between the digits of integers and floats in the environment and
+// defaults, as in Go source.
+//
+//	type Config struct {
+//	  MaxRows int `fig:"maxRows" default:"1_000_000"`
+//	}
+//
+//	fig.Load(&cfg, fig.AllowNumericSeparators())
+//
+// An underscore must be placed between two digits, so values such as 1__000,
+// _1000 or 1_000_ result in an error. Numbers in a config file are unaffected
+// by this option as they already accept underscores.
+func AllowNumericSeparators() Option {
+	return func(f *fig) {
+		f.numericSeps = true
+	}
+}
+
+// AllowNumericBool returns an option that configures whether fig accepts the
+// numbers 0 and 1 as the boolean values false and true. The setting applies
+// equally to the config file, the environment and defaults.
+//
+//	fig.Load(&cfg, fig.AllowNumericBool(false)) // secure: 1 results in an error
+//
+// With allow set to true only 0 and 1 are accepted, whether they are given as
+// numbers or strings, and any other number decoded into a bool is an error.
+// With allow set to false every number, and the strings "0" and "1", result in
+// an error.
+//
+// If this option is not used then the environment and defaults accept "0" and
+// "1" while a config file accepts any number, where every non-zero number is true.
+func AllowNumericBool(allow bool) Option {
+	return func(f *fig) {
+		f.numericBool = &allow
+	}
+}
+
+// MergeEnvSlices returns an option that configures fig to merge slice values
+// from the environment into the existing slice instead of replacing it, when
+// the environment value is prefixed by either a `+` or a `-`.
+//
+//	fig.Load(&cfg, fig.UseEnv("myapp"), fig.MergeEnvSlices())
+//
+// With a field `Ports []int` loaded as [80,443] from the config file:
+//
+//	MYAPP_PORTS=+[8080]  // appends to the slice:  [80,443,8080]
+//	MYAPP_PORTS=-[443]   // removes from the slice: [80]
+//	MYAPP_PORTS=[8080]   // replaces the slice:     [8080]
+//
+// If this option is not used then slice values from the environment always
+// replace the existing slice.
+func MergeEnvSlices() Option {
+	return func(f *fig) {
+		f.mergeEnvSlices = true
+	}
+}
+
+// EnvFile returns an option that configures fig to read environment variables
+// from a .env file, made up of KEY=VALUE lines, in addition to the environment
+// when `UseEnv` or `ExpandEnvDefaults` is used.
+//
+//	fig.Load(&cfg, fig.UseEnv("myapp"), fig.EnvFile(".env.local"))
+//
+// The variables of the file are looked up as if they were set in the environment,
+// including by the ${VAR} references of defaults, but a variable that is actually
+// set in the environment always wins over the file. The file must exist, and a
+// line that cannot be parsed results in an error.
+//
+// If this option is not used then only the environment is consulted.
+func EnvFile(path string) Option {
+	return func(f *fig) {
+		f.envFile = path
+	}
+}
+
+// EnvJSON returns an option that configures fig to accept a json object as the
+// environment value of a struct or map field, setting the whole subtree from a
+// single variable.
+//
+//	fig.Load(&cfg, fig.UseEnv("myapp"), fig.EnvJSON())
+//
+// With a field `Server` holding a Host and a Port:
+//
+//	MYAPP_SERVER={"host":"0.0.0.0","port":8080}
+//	MYAPP_SERVER_PORT=9090  // overrides the port of the object: 9090
+//
+// The object is decoded over the existing value, so keys missing from it keep
+// their value from the config file. A variable of a field within the subtree is
+// applied after the object and so wins over it.
+//
+// If this option is not used then struct fields only accept a flow style mapping
+// from the environment, which replaces the struct as a whole.
+func EnvJSON() Option {
+	return func(f *fig) {
+		f.envJSON = true
+	}
+}
+
+// EnvSplitCamelCase returns an option that configures fig to separate the words
+// of camel-cased field names with underscores when forming environment keys.
+//
+//	fig.Load(&cfg, fig.UseEnv("myapp"), fig.EnvSplitCamelCase())
+//
+// Only field names are split. Alt names defined in the field's struct tag are used as-is.
+//
+//	type Config struct {
+//	  LogLevel   string
+//	  HTTPServer struct {
+//	    ReadTimeout time.Duration `fig:"timeout"`
+//	  }
+//	}
+//
+// With the struct above fig would search for the following environment variables:
+//
+//	MYAPP_LOG_LEVEL
+//	MYAPP_HTTP_SERVER_TIMEOUT
+//
+// If this option is not used then field names are only upper-cased, so the
+// variables would be MYAPP_LOGLEVEL and MYAPP_HTTPSERVER_TIMEOUT.
+func EnvSplitCamelCase() Option {
+	return func(f *fig) {
+		f.envSplitCamelCase = true
+	}
+}
+
+// EnvUseFieldName returns an option that configures fig to form environment keys
+// from the names of fields as defined in the struct, ignoring the alt names of
+// their struct tags. This lets the keys of the config file and the names of
+// environment variables follow different conventions.
+//
+//	type Config struct {
+//	  LogLevel string `fig:"log"`
+//	}
+//
+//	fig.Load(&cfg, fig.UseEnv("myapp"), fig.EnvUseFieldName()) // MYAPP_LOGLEVEL
+//
+// Combine it with EnvSplitCamelCase to separate the words of field names, which
+// would give MYAPP_LOG_LEVEL above.
+//
+// If this option is not used then the alt name of a field is used as its name
+// in environment keys.
+func EnvUseFieldName() Option {
+	return func(f *fig) {
+		f.envUseFieldName = true
+	}
+}
+
+// FromEnvConfig returns an option that configures fig to read settings of the
+// loader itself from environment variables, for deployments where options cannot
+// be changed without changing code.
+//
+//	FIG_CONFIG_FILE=/etc/myapp/config.yaml  // as fig.File("config.yaml"), fig.Dirs("/etc/myapp")
+//	FIG_STRICT=1                            // as fig.UseStrict()
+//
+//	fig.Load(&cfg, fig.FromEnvConfig())
+//
+// Options given explicitly win over the environment, regardless of their order,
+// so FIG_CONFIG_FILE is ignored if `File` or `Dirs` is used and FIG_STRICT cannot
+// turn off `UseStrict`. An invalid value of FIG_STRICT results in an error.
+//
+// If this option is not used then these environment variables are ignored.
+func FromEnvConfig() Option {
+	return func(f *fig) {
+		f.envConfig = true
+	}
+}
+
 // UseStrict returns an option that configures fig to return an error if
 // there exists additional fields in the config file that are not defined
 // in the config struct.
 //
 //	fig.Load(&cfg, fig.UseStrict())
 //
+// The returned error is of type *ErrUnknownKeys and lists the paths of all the
+// additional fields.
+//
 // If this option is not used then fig ignores any additional fields in the config file.
 func UseStrict() Option {
 	return func(f *fig) {
 		f.useStrict = true
 	}
 }
+
+// ConflictError returns an option that configures fig to return an error for
+// every field that is set by the config file and also by the environment to a
+// different value. The error names the field and both values, except for
+// fields that contain a secret flag in their tag.
+//
+//	// config.yaml
+//	port: 8080
+//
+//	// MYAPP_PORT=9090
+//	fig.Load(&cfg, fig.UseEnv("myapp"), fig.ConflictError()) // port: env sets 9090, conflicting with 8080 in the config file
+//
+// An environment variable that sets a field to the value it already has in the
+// config file is not a conflict. If this option is not used then the environment
+// silently overrides the config file.
+func ConflictError() Option {
+	return func(f *fig) {
+		f.envConflicts = true
+	}
+}
+
+// DeepRequired returns an option that configures fig to consider a required
+// struct field unset if all of its fields, and the fields of the structs it
+// contains, are unset.
+//
+//	type Config struct {
+//	  Database struct {
+//	    Host string `fig:"host"`
+//	    Port int    `fig:"port" default:"5432"`
+//	  } `fig:"database" validate:"required"`
+//	}
+//
+//	fig.Load(&cfg, fig.DeepRequired()) // fails if database is missing from the config
+//
+// Fields that were set to their default do not count as set. If this option is
+// not used then a required struct field always passes validation.
+func DeepRequired() Option {
+	return func(f *fig) {
+		f.deepRequired = true
+	}
+}
+
+// PresenceAware returns an option that configures fig to track which fields
+// were explicitly provided by the config file or the environment, and to use
+// that information instead of the field's value when validating required fields.
+//
+//	fig.Load(&cfg, fig.PresenceAware())
+//
+// With this option a required field that is explicitly set to its zero value
+// (e.g. `port: 0`) satisfies the required validation. A field whose value is
+// `null` in the config file is not considered present.
+//
+// If this option is not used then a required field is considered set only if it
+// holds a non-zero value.
+func PresenceAware() Option {
+	return func(f *fig) {
+		f.presenceAware = true
+	}
+}
+
+// NullAsEmpty returns an option that configures fig to treat keys whose value is
+// `null` in the config file as explicitly empty, leaving their fields at their
+// zero value instead of setting them to their default.
+//
+//	fig.Load(&cfg, fig.NullAsEmpty())
+//
+// Null values inside lists are not tracked. A field that is null in the config
+// file is still set by the environment if a matching variable exists.
+//
+// If this option is not used then a null value is treated the same as a missing
+// key, and the field is set to its default.
+func NullAsEmpty() Option {
+	return func(f *fig) {
+		f.nullAsEmpty = true
+	}
+}
+
+// Migration returns an option that registers fn as the migration of configs from
+// version from to version from+1. The version of a config is the value of its
+// top-level VersionKey, and configs are migrated up to the current version, which
+// is the version after that of the latest migration registered.
+//
+//	fig.Load(&cfg,
+//	  fig.Migration(1, func(vals map[string]interface{}) (map[string]interface{}, error) {
+//	    vals["listen"] = fmt.Sprintf(":%v", vals["port"])
+//	    delete(vals, "port")
+//	    return vals, nil
+//	  }),
+//	)
+//
+// Migrations run in sequence on the values of the config file before they are
+// decoded, and the version key is updated to the current version, so a config
+// of version 1 is migrated by the migrations from versions 1, 2 and so on. It is
+// an error if a migration in the sequence is missing, if the config's version is
+// newer than the current version, or if a migration returns an error. A config
+// without a version key is assumed to be of the current version.
+func Migration(from int, fn MigrationFunc) Option {
+	return func(f *fig) {
+		if f.migrations == nil {
+			f.migrations = make(map[int]MigrationFunc)
+		}
+		f.migrations[from] = fn
+	}
+}
+
+// PostLoad returns an option that registers a callback which fig invokes with
+// the populated cfg once it has been loaded and validated, allowing fields to
+// be normalised before Load returns.
+//
+//	fig.Load(&cfg, fig.PostLoad(func(cfg interface{}) error {
+//	  c := cfg.(*Config)
+//	  c.Host = strings.ToLower(c.Host)
+//	  return nil
+//	}))
+//
+// An error returned by the callback fails the load. This option may be given
+// more than once, in which case callbacks are invoked in the order given and
+// the first error stops the load. Callbacks registered with `OnLoad` are
+// invoked after all PostLoad callbacks succeed.
+func PostLoad(fn func(cfg interface{}) error) Option {
+	return func(f *fig) {
+		f.postLoad = append(f.postLoad, fn)
+	}
+}
+
+// OnLoad returns an option that registers a callback which fig invokes after
+// a successful load with a summary of the load.
+//
+//	fig.Load(&cfg, fig.OnLoad(func(info fig.LoadInfo) {
+//	  log.Printf("loaded %s: %d defaults, %d env overrides", info.File, len(info.Defaults), len(info.Env))
+//	}))
+//
+// The callback is not invoked if the load fails. This option may be given
+// more than once, in which case callbacks are invoked in the order given.
+func OnLoad(fn func(info LoadInfo)) Option {
+	return func(f *fig) {
+		f.onLoad = append(f.onLoad, fn)
+	}
+}
+
+// LenientUnmarshal returns an option that configures fig to not fail a load when
+// a StringUnmarshaler returns an error, such as for an enum value that is unknown
+// to this version of the program. The error is instead passed to fn along with
+// the path of the field and the value, and the field is left as if the value had
+// not been given.
+//
+//	fig.Load(&cfg, fig.LenientUnmarshal(func(field, val string, err error) {
+//	  log.Printf("ignoring %s=%q: %v", field, val, err)
+//	}))
+//
+// This applies to values from the config file, the environment and defaults,
+// so a field whose value in the config file is ignored may still be set by the
+// environment or to its default. The field is empty for values of map entries.
+//
+// If this option is not used then an error returned by a StringUnmarshaler fails
+// the load.
+func LenientUnmarshal(fn func(field, val string, err error)) Option {
+	return func(f *fig) {
+		f.onUnmarshalErr = fn
+	}
+}
+
+// WithLogger returns an option that configures fig to log diagnostic messages
+// about the decisions it makes while loading, such as which config file was
+// loaded, which environment variables were found and which fields were set to
+// their default value.
+//
+//	fig.Load(&cfg, fig.WithLogger(log.Printf))
+//
+// The values of environment variables and defaults are not logged. If this
+// option is not used then nothing is logged.
+func WithLogger(logf func(format string, args ...interface{})) Option {
+	return func(f *fig) {
+		f.logger = logf
+	}
+}
+
+// Trace returns an option that configures fig to call fn with every step it
+// takes while loading, in order. This includes the paths searched for the config
+// file, the file loaded, the fields decoded from it, each environment lookup and
+// whether it found a variable, the defaults set and the outcome of every field's
+// validations.
+//
+//	var events []fig.TraceEvent
+//	err := fig.Load(&cfg, fig.Trace(func(e fig.TraceEvent) {
+//	  events = append(events, e)
+//	}))
+//
+// This is intended to investigate why a config ended up with the values it has.
+// Unlike `WithLogger` the events are structured, see `TraceEvent`. If this option
+// is not used then no events are built.
+func Trace(fn func(TraceEvent)) Option {
+	return func(f *fig) {
+		f.tracer = fn
+	}
+}
+
+// DecodeHook returns an option that appends the given mapstructure decode hooks
+// to the hooks that fig uses when decoding the config file into the struct.
+//
+//	fig.Load(&cfg, fig.DecodeHook(stringToDecimalHookFunc()))
+//
+// Hooks are executed in the order given and after fig's own hooks, which convert
+// strings into time.Duration, time.Time, *regexp.Regexp and StringUnmarshaler values.
+// This option may be given more than once, in which case hooks are accumulated.
+func DecodeHook(hooks ...mapstructure.DecodeHookFunc) Option {
+	return func(f *fig) {
+		f.decodeHooks = append(f.decodeHooks, hooks...)
+	}
+}
+
+// RegisterDefault returns an option that registers a constructor for the default
+// value of interface fields of type t. An interface field of that type that has
+// a default key in its tag and is not otherwise set is filled with the value
+// returned by fn. The value of the default key is ignored.
+//
+//	type Notifier interface{ Notify(msg string) }
+//
+//	type Config struct {
+//	  Notifier Notifier `fig:"notifier" default:"noop"`
+//	}
+//
+//	fig.Load(&cfg, fig.RegisterDefault(
+//	  reflect.TypeOf((*Notifier)(nil)).Elem(),
+//	  func() interface{} { return noopNotifier{} },
+//	))
+//
+// The value returned by fn must implement t. This option may be given more than
+// once to register constructors for different types.
+func RegisterDefault(t reflect.Type, fn func() interface{}) Option {
+	return func(f *fig) {
+		if f.defaultCtors == nil {
+			f.defaultCtors = make(map[reflect.Type]func() interface{})
+		}
+		f.defaultCtors[t] = fn
+	}
+}
+
+// DefaultResolver returns an option that registers fn to resolve the default
+// value of fields before it is parsed. fn is called with the path of the field
+// and the raw value of its default key. If fn returns true then the returned
+// string is parsed in place of the raw value, otherwise the raw value is used.
+//
+//	limits := map[string]string{"@MaxConns": strconv.Itoa(runtime.NumCPU() * 4)}
+//
+//	type Config struct {
+//	  MaxConns int `fig:"maxConns" default:"@MaxConns"`
+//	}
+//
+//	fig.Load(&cfg, fig.DefaultResolver(func(fieldPath, raw string) (string, bool) {
+//	  s, ok := limits[raw]
+//	  return s, ok
+//	}))
+//
+// The resolved value is subject to the same parsing, and expansion if
+// `ExpandEnvDefaults` is given, as a literal default.
+func DefaultResolver(fn func(fieldPath, raw string) (string, bool)) Option {
+	return func(f *fig) {
+		f.defaultResolver = fn
+	}
+}
+
+// NormalizeMapKeys returns an option that configures fig to rewrite the keys of
+// map fields read from the config file with fn before they are decoded, so that
+// keys which differ only in their spelling end up as a single entry.
+//
+//	type Config struct {
+//	  Limits map[string]int `fig:"limits"`
+//	}
+//
+//	fig.Load(&cfg, fig.NormalizeMapKeys(strings.ToLower))
+//
+// With a config file containing the keys `Prod` and `staging` under limits, the
+// map holds the keys `prod` and `staging`. Only the keys of map fields, at any
+// depth, are rewritten: the keys matched against struct fields are untouched.
+//
+// Keys of the same map that are rewritten into the same key, such as `Prod` and
+// `PROD`, are folded into one entry in the sorted order of the original keys.
+// Their values are merged if they're maps, and otherwise the value of the last
+// key wins, e.g. that of `prod` over `Prod` and `PROD`.
+//
+// If this option is not used then map keys are kept as they appear in the file.
+func NormalizeMapKeys(fn func(string) string) Option {
+	return func(f *fig) {
+		f.normalizeMapKey = fn
+	}
+}
@@ -0,0 +1,84 @@
+package fig
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Reloader holds the most recent config of type T that was loaded and
+// validated successfully. It is safe for concurrent use.
+type Reloader[T any] struct {
+	options []Option
+	current atomic.Pointer[T]
+	errs    chan error
+	mu      sync.Mutex // serializes reloads.
+}
+
+// Reloadable loads a config of type T with the given options and returns a
+// Reloader holding it. An error is returned if the initial load fails.
+//
+//	cfg, err := fig.Reloadable[Config](fig.File("config.yaml"))
+//	if err != nil {
+//	  return err
+//	}
+//
+//	go func() {
+//	  for range sighup {
+//	    cfg.Reload()
+//	  }
+//	}()
+//
+//	port := cfg.Current().Port
+//
+// Every reload runs the full load with the same options, including env,
+// defaults and validations. Options with a `Reader` cannot be reloaded as
+// the reader is consumed by the first load.
+func Reloadable[T any](options ...Option) (*Reloader[T], error) {
+	r := &Reloader[T]{
+		options: options,
+		errs:    make(chan error, 1),
+	}
+
+	cfg := new(T)
+	if err := Load(cfg, options...); err != nil {
+		return nil, err
+	}
+	r.current.Store(cfg)
+
+	return r, nil
+}
+
+// Current returns the most recent config that was loaded successfully. The
+// returned config must not be modified.
+func (r *Reloader[T]) Current() *T {
+	return r.current.Load()
+}
+
+// Reload loads the config again into a new T and, if that succeeds, makes it
+// the current config. If the load fails then the current config is retained
+// and the error is both returned and delivered to the channel returned by
+// Errors.
+func (r *Reloader[T]) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg := new(T)
+	if err := Load(cfg, r.options...); err != nil {
+		// don't block reloads on a consumer that isn't keeping up
+		select {
+		case r.errs <- err:
+		default:
+		}
+		return err
+	}
+	r.current.Store(cfg)
+
+	return nil
+}
+
+// Errors returns a channel on which the errors of failed reloads are
+// delivered. The channel is buffered and an error is dropped if the
+// previous one has not been received yet.
+func (r *Reloader[T]) Errors() <-chan error {
+	return r.errs
+}
@@ -0,0 +1,98 @@
+package fig
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReloadable(t *testing.T) {
+	type Config struct {
+		Host string `fig:"host" validate:"required"`
+		Port int    `fig:"port" default:"80"`
+	}
+
+	dir := t.TempDir()
+	write := func(t *testing.T, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(data), 0o600); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+	}
+
+	write(t, "host: a\n")
+
+	cfg, err := Reloadable[Config](Dirs(dir))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got := cfg.Current(); got.Host != "a" || got.Port != 80 {
+		t.Fatalf("unexpected config: %+v", got)
+	}
+
+	t.Run("reload replaces config", func(t *testing.T) {
+		write(t, "host: b\nport: 8080\n")
+
+		prev := cfg.Current()
+		if err := cfg.Reload(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if got := cfg.Current(); got.Host != "b" || got.Port != 8080 {
+			t.Errorf("unexpected config: %+v", got)
+		}
+		if prev.Host != "a" {
+			t.Errorf("previous config was modified: %+v", prev)
+		}
+	})
+
+	t.Run("failed reload retains config", func(t *testing.T) {
+		write(t, "port: 9090\n")
+
+		if err := cfg.Reload(); err == nil {
+			t.Fatalf("expected err")
+		}
+		if got := cfg.Current(); got.Host != "b" || got.Port != 8080 {
+			t.Errorf("unexpected config: %+v", got)
+		}
+
+		select {
+		case err := <-cfg.Errors():
+			if err == nil {
+				t.Errorf("expected err on channel")
+			}
+		default:
+			t.Errorf("expected err to be delivered")
+		}
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		write(t, "host: c\n")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_ = cfg.Reload()
+			}()
+			go func() {
+				defer wg.Done()
+				if cfg.Current() == nil {
+					t.Errorf("Current() returned nil")
+				}
+			}()
+		}
+		wg.Wait()
+
+		if got := cfg.Current(); got.Host != "c" {
+			t.Errorf("unexpected config: %+v", got)
+		}
+	})
+
+	t.Run("initial load fails", func(t *testing.T) {
+		if _, err := Reloadable[Config](IgnoreFile()); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
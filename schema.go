@@ -0,0 +1,116 @@
+package fig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldInfo describes a field of a config struct as seen by fig.
+type FieldInfo struct {
+	// Path is the dot separated path of the field, the same path that is
+	// used in errors. The fields of structs in slices, arrays and maps
+	// are given without an index.
+	Path string
+	// Type is the type of the field.
+	Type reflect.Type
+	// Description is the value of the field's desc tag, if any.
+	Description string
+	// Default is the value of the field's default, if HasDefault is true.
+	Default    string
+	HasDefault bool
+	// Required is true if the field has a required validation.
+	Required bool
+	// Secret is true if the field contains a secret flag in its tag.
+	Secret bool
+	// Values are the allowed values of the field if its type, or the type
+	// of its elements, implements StringEnum.
+	Values []string
+}
+
+// Fields returns a description of every field of cfg, and of the structs it
+// contains, in the order they are declared. cfg must be a struct or a pointer
+// to a struct. Fields only inspects the type of cfg, so a nil pointer is
+// accepted.
+//
+//	type Config struct {
+//	  Host string `fig:"host" desc:"address to listen on" default:"0.0.0.0"`
+//	  Port int    `fig:"port" desc:"port to listen on" validate:"required"`
+//	}
+//
+//	fields, err := fig.Fields((*Config)(nil))
+//	for _, f := range fields {
+//	  fmt.Printf("%s\t%s\n", f.Path, f.Description)
+//	}
+//
+// This is intended for tools that generate documentation or flags from a
+// config struct. The fields of squashed structs are described as fields of
+// the struct that contains them. Options that affect how fields are named,
+// namely `Tag`, are honored so that the paths match those that Load uses:
+//
+//	fields, err := fig.Fields((*Config)(nil), fig.Tag("yaml"))
+func Fields(cfg interface{}, options ...Option) ([]FieldInfo, error) {
+	f := defaultFig()
+	for _, opt := range options {
+		opt(f)
+	}
+
+	t := reflect.TypeOf(cfg)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cfg must be a struct or a pointer to a struct, got %T", cfg)
+	}
+
+	var fields []FieldInfo
+	typeFields(t, f.tag, "", &fields, make(map[reflect.Type]bool))
+	return fields, nil
+}
+
+// typeFields appends a FieldInfo to fields for every field of the
+// struct type t and of any struct types it contains. tag is the key of
+// the struct tag that contains the alt names of fields and path is the
+// path of t.
+func typeFields(t reflect.Type, tag, path string, fields *[]FieldInfo, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		st := parseTag(sf.Tag, tag)
+
+		if st.squash {
+			typeFields(sf.Type, tag, path, fields, seen)
+			continue
+		}
+
+		name := sf.Name
+		if st.altName != "" {
+			name = st.altName
+		}
+		fieldPath := strings.TrimPrefix(path+"."+name, ".")
+
+		values, _ := enumValues(sf.Type)
+		*fields = append(*fields, FieldInfo{
+			Path:        fieldPath,
+			Type:        sf.Type,
+			Description: st.description,
+			Default:     st.defaultVal,
+			HasDefault:  st.setDefault,
+			Required:    st.required,
+			Secret:      st.secret,
+			Values:      values,
+		})
+		typeFields(sf.Type, tag, fieldPath, fields, seen)
+	}
+}
@@ -0,0 +1,74 @@
+package fig
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFields(t *testing.T) {
+	type Common struct {
+		Region string `fig:"region" desc:"deployment region" default:"eu-west-1"`
+	}
+
+	type Config struct {
+		Common   `fig:",squash"`
+		Host     string        `fig:"host" desc:"address to listen on" validate:"required"`
+		Password string        `fig:"password,secret" desc:"admin password"`
+		Timeout  time.Duration `fig:"timeout" default:"5s"`
+		Format   logFormat     `fig:"format" default:"text"`
+		Workers  []struct {
+			Name string `fig:"name" desc:"worker name"`
+		} `fig:"workers"`
+		private string
+	}
+
+	fields, err := Fields((*Config)(nil))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	var cfg Config
+	want := []FieldInfo{
+		{Path: "region", Type: reflect.TypeOf(""), Description: "deployment region", Default: "eu-west-1", HasDefault: true},
+		{Path: "host", Type: reflect.TypeOf(""), Description: "address to listen on", Required: true},
+		{Path: "password", Type: reflect.TypeOf(""), Description: "admin password", Secret: true},
+		{Path: "timeout", Type: reflect.TypeOf(time.Duration(0)), Default: "5s", HasDefault: true},
+		{Path: "format", Type: reflect.TypeOf(logFormat("")), Default: "text", HasDefault: true, Values: []string{"text", "json"}},
+		{Path: "workers", Type: reflect.TypeOf(cfg.Workers)},
+		{Path: "workers.name", Type: reflect.TypeOf(""), Description: "worker name"},
+	}
+	if !reflect.DeepEqual(want, fields) {
+		t.Errorf("\nwant %+v\ngot  %+v", want, fields)
+	}
+
+	t.Run("tag option", func(t *testing.T) {
+		type Server struct {
+			Port int `yaml:"port" default:"80"`
+		}
+		type Config struct {
+			Server Server `yaml:"http_server"`
+		}
+
+		fields, err := Fields((*Config)(nil), Tag("yaml"))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		var paths []string
+		for _, f := range fields {
+			paths = append(paths, f.Path)
+		}
+		if want := []string{"http_server", "http_server.port"}; !reflect.DeepEqual(want, paths) {
+			t.Errorf("want paths %v, got %v", want, paths)
+		}
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		if _, err := Fields(42); err == nil {
+			t.Fatalf("expected err")
+		}
+		if _, err := Fields(nil); err == nil {
+			t.Fatalf("expected err")
+		}
+	})
+}
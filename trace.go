@@ -0,0 +1,64 @@
+package fig
+
+import "fmt"
+
+// TraceKind is the kind of a TraceEvent.
+type TraceKind string
+
+const (
+	// TraceFileSearched is emitted for each path that is searched for the
+	// config file. Detail is either "found" or "not found".
+	TraceFileSearched TraceKind = "file searched"
+	// TraceFileLoaded is emitted when a config file, url or reader is
+	// decoded. Path is the file or url, or the name of a named reader.
+	// Detail is "url" or "reader" if the config was not read from a file.
+	TraceFileLoaded TraceKind = "file loaded"
+	// TraceKeyDecoded is emitted for each field that is set from the
+	// config file.
+	TraceKeyDecoded TraceKind = "key decoded"
+	// TraceEnvHit is emitted when a field is set from the environment.
+	// Detail is the name of the variable.
+	TraceEnvHit TraceKind = "env hit"
+	// TraceEnvMiss is emitted when the environment variables of a field do
+	// not exist. Detail contains the names of the variables.
+	TraceEnvMiss TraceKind = "env miss"
+	// TraceDefault is emitted when a field is set to its default. Detail is
+	// the default value, or Redacted if the field is secret.
+	TraceDefault TraceKind = "default applied"
+	// TraceValidation is emitted for each field that has a validation once
+	// it's been validated. Detail is "passed", or the error if it failed.
+	TraceValidation TraceKind = "validation"
+)
+
+// TraceEvent is a single step taken by fig while loading a config, as
+// delivered to the callback registered with `Trace`.
+type TraceEvent struct {
+	Kind TraceKind
+	// Path is the path of the field, or file, that the event concerns.
+	Path string
+	// Detail describes the outcome of the step.
+	Detail string
+}
+
+// String returns a single line description of e.
+func (e TraceEvent) String() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%s: %s", e.Kind, e.Path)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Kind, e.Path, e.Detail)
+}
+
+// trace delivers an event to the callback registered with the Trace
+// option, if any.
+func (f *fig) trace(kind TraceKind, path, detail string) {
+	if f.tracer != nil {
+		f.tracer(TraceEvent{Kind: kind, Path: path, Detail: detail})
+	}
+}
+
+// hasValidation reports whether the tag contains any validation.
+func (st structTag) hasValidation() bool {
+	return st.required || st.requiredWith != "" || st.requiredWithout != "" || st.requiredGroup != "" ||
+		len(st.formats) > 0 || len(st.contains) > 0 || len(st.excludes) > 0 || st.unique ||
+		len(st.keyFormats) > 0
+}
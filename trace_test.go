@@ -0,0 +1,93 @@
+package fig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	type Config struct {
+		Kind     string `fig:"kind" validate:"required"`
+		Replicas int    `fig:"replicas" default:"3"`
+		Level    string `fig:"level"`
+		Owner    string `fig:"owner" validate:"email"`
+	}
+
+	os.Clearenv()
+	setenv(t, "LEVEL", "debug")
+
+	missing := t.TempDir()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(file, []byte("kind: Pod\nowner: nope\n"), 0o600); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+
+	var (
+		cfg    Config
+		events []TraceEvent
+	)
+	err := Load(&cfg, Dirs(missing, dir), UseEnv(""), Trace(func(e TraceEvent) {
+		events = append(events, e)
+	}))
+	if err == nil {
+		t.Fatalf("expected err")
+	}
+	if len(events) == 0 {
+		t.Fatalf("no events")
+	}
+
+	want := []TraceEvent{
+		{Kind: TraceFileSearched, Path: filepath.Join(missing, "config.yaml"), Detail: "not found"},
+		{Kind: TraceFileSearched, Path: file, Detail: "found"},
+		{Kind: TraceFileLoaded, Path: file},
+		{Kind: TraceKeyDecoded, Path: "kind"},
+		{Kind: TraceKeyDecoded, Path: "owner"},
+		{Kind: TraceEnvMiss, Path: "kind", Detail: "KIND"},
+		{Kind: TraceEnvMiss, Path: "replicas", Detail: "REPLICAS"},
+		{Kind: TraceDefault, Path: "replicas", Detail: "3"},
+		{Kind: TraceEnvHit, Path: "level", Detail: "LEVEL"},
+		{Kind: TraceEnvMiss, Path: "owner", Detail: "OWNER"},
+		{Kind: TraceValidation, Path: "kind", Detail: "passed"},
+		{Kind: TraceValidation, Path: "owner", Detail: events[len(events)-1].Detail},
+	}
+	if !reflect.DeepEqual(want, events) {
+		t.Fatalf("\nwant %v\ngot  %v", want, events)
+	}
+	if events[len(events)-1].Detail == "passed" {
+		t.Errorf("want failed validation of owner")
+	}
+
+	t.Run("secret default", func(t *testing.T) {
+		var cfg struct {
+			Token string `fig:"token,secret" default:"hunter2"`
+		}
+		var events []TraceEvent
+		err := Load(&cfg, IgnoreFile(), Trace(func(e TraceEvent) {
+			events = append(events, e)
+		}))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		want := []TraceEvent{{Kind: TraceDefault, Path: "token", Detail: Redacted}}
+		if !reflect.DeepEqual(want, events) {
+			t.Fatalf("\nwant %v\ngot  %v", want, events)
+		}
+		if cfg.Token != "hunter2" {
+			t.Errorf("want token set to default, got %q", cfg.Token)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		e := TraceEvent{Kind: TraceEnvHit, Path: "level", Detail: "LEVEL"}
+		if got, want := e.String(), "env hit: level: LEVEL"; got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+		e = TraceEvent{Kind: TraceKeyDecoded, Path: "kind"}
+		if got, want := e.String(), "key decoded: kind"; got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+}
@@ -1,22 +1,657 @@
 package fig
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
-	"time"
+	"unicode"
 )
 
 // stringSlice converts a Go slice represented as a string
 // into an actual slice. The enclosing square brackets
 // are not necessary.
-// fields should be separated by a comma.
+// fields should be separated by a comma. Commas inside
+// balanced brackets, braces or parentheses, or inside an
+// element that is enclosed in single or double quotes, do
+// not separate fields. The quotes of a quoted element are
+// removed.
 //
-//	"[1,2,3]"     --->   []string{"1", "2", "3"}
-//	" foo , bar"  --->   []string{" foo ", " bar"}
+//	"[1,2,3]"          --->   []string{"1", "2", "3"}
+//	" foo , bar"       --->   []string{" foo ", " bar"}
+//	"[[a-z]{2,4},\d+]" --->   []string{"[a-z]{2,4}", "\d+"}
+//	`["a,b",c]`        --->   []string{"a,b", "c"}
 func stringSlice(s string) []string {
 	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
-	return strings.Split(s, ",")
+
+	var (
+		elems []string
+		start int
+		depth int
+		quote byte // the quote that encloses the current element, if any.
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// only a quote that starts an element quotes it
+			if strings.TrimSpace(s[start:i]) == "" {
+				quote = c
+			}
+		case c == '[' || c == '{' || c == '(':
+			depth++
+		case (c == ']' || c == '}' || c == ')') && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			elems = append(elems, unquoteElem(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(elems, unquoteElem(s[start:]))
+}
+
+// unquoteElem returns the contents of elem if it is enclosed in single
+// or double quotes, ignoring surrounding whitespace, with backslash
+// escaped quotes unescaped. Otherwise elem is returned unchanged.
+func unquoteElem(elem string) string {
+	t := strings.TrimSpace(elem)
+	if len(t) < 2 || (t[0] != '"' && t[0] != '\'') || t[len(t)-1] != t[0] {
+		return elem
+	}
+
+	var sb strings.Builder
+	for i := 1; i < len(t)-1; i++ {
+		switch {
+		case t[i] == '\\' && i+2 < len(t) && t[i+1] == t[0]:
+			sb.WriteByte(t[0])
+			i++
+		case t[i] == t[0]:
+			return elem // the quote ends before the element does
+		default:
+			sb.WriteByte(t[i])
+		}
+	}
+	return sb.String()
+}
+
+// expandEnv replaces references to environment variables in s
+// of the form ${VAR} with their values. A reference may provide
+// a fallback value that is used when the variable is unset or
+// empty in the form ${VAR:-fallback}. An error is returned if a variable is
+// unset and has no fallback. Variables of the env file are used when they are
+// not set in the environment.
+//
+//	"${HOME}/bin"          --->   "/home/user/bin"
+//	"${NOPE:-/usr}/bin"    --->   "/usr/bin"
+func (f *fig) expandEnv(s string) (string, error) {
+	var sb strings.Builder
+
+	for {
+		start := strings.Index(s, "${")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated variable reference in %q", s)
+		}
+		end += start
+
+		name, fallback, hasFallback := strings.Cut(s[start+2:end], ":-")
+		val, ok := f.getenv(name)
+		if !ok && !hasFallback {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		if val == "" && hasFallback {
+			val = fallback
+		}
+
+		sb.WriteString(s[:start])
+		sb.WriteString(val)
+		s = s[end+1:]
+	}
+
+	sb.WriteString(s)
+	return sb.String(), nil
+}
+
+// splitCamelCase separates the words of a camel-cased string
+// with underscores. Acronyms are kept together.
+//
+//	"LogLevel"     --->   "Log_Level"
+//	"HTTPServer"   --->   "HTTP_Server"
+//	"UserID"       --->   "User_ID"
+func splitCamelCase(s string) string {
+	rs := []rune(s)
+
+	var sb strings.Builder
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteRune('_')
+			}
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// byteUnits maps the supported byte size suffixes, in lower case, to
+// their multiplier. SI suffixes are powers of 1000 and IEC suffixes
+// are powers of 1024.
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"eb":  1e18,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+	"eib": 1 << 60,
+}
+
+// parseBytes parses a human-readable byte size into a number of bytes.
+// The size is a non-negative number optionally followed by a unit.
+// Units are case-insensitive.
+//
+//	"512"    --->   512
+//	"10MB"   --->   10000000
+//	"1.5KiB" --->   1536
+func parseBytes(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.TrimSpace(s[i:])
+
+	mult, ok := byteUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", s, unit)
+	}
+
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if n > math.MaxUint64/mult {
+			return 0, fmt.Errorf("invalid byte size %q: value out of range", s)
+		}
+		return n * mult, nil
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	b := n * float64(mult)
+	if b >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid byte size %q: value out of range", s)
+	}
+	if b != math.Trunc(b) {
+		return 0, fmt.Errorf("invalid byte size %q: not a whole number of bytes", s)
+	}
+	return uint64(b), nil
+}
+
+// maxFlowDepth is the maximum nesting depth of a flow style value.
+const maxFlowDepth = 16
+
+// parseFlow parses a flow style mapping into a map. Values may be scalars,
+// nested mappings or lists enclosed in square brackets. Scalars are kept
+// as strings with surrounding white space trimmed.
+//
+//	"{host:localhost,port:8080}"   --->   {"host": "localhost", "port": "8080"}
+//	"{tls:{enabled:true},ips:[a,b]}"  --->   {"tls": {"enabled": "true"}, "ips": ["a", "b"]}
+func parseFlow(s string) (map[string]interface{}, error) {
+	p := &flowParser{s: strings.TrimSpace(s)}
+	v, err := p.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flow value %q: %w", s, err)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid flow value %q: expected a mapping", s)
+	}
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("invalid flow value %q: unexpected %q at offset %d", s, p.s[p.pos], p.pos)
+	}
+	return m, nil
+}
+
+// flowParser is a recursive descent parser of flow style values.
+type flowParser struct {
+	s   string
+	pos int
+}
+
+func (p *flowParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume advances past c, which may be preceded by white space, and
+// reports whether it did.
+func (p *flowParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *flowParser) value(depth int) (interface{}, error) {
+	if depth > maxFlowDepth {
+		return nil, fmt.Errorf("nested deeper than %d levels", maxFlowDepth)
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case '{':
+			return p.mapping(depth)
+		case '[':
+			return p.list(depth)
+		}
+	}
+	return p.scalar(",}]"), nil
+}
+
+func (p *flowParser) mapping(depth int) (map[string]interface{}, error) {
+	p.pos++ // {
+	m := make(map[string]interface{})
+	if p.consume('}') {
+		return m, nil
+	}
+	for {
+		key := p.scalar(":,}")
+		if key == "" || !p.consume(':') {
+			return nil, fmt.Errorf("expected key:value at offset %d", p.pos)
+		}
+		val, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = val
+		if p.consume('}') {
+			return m, nil
+		}
+		if !p.consume(',') {
+			return nil, fmt.Errorf("expected , or } at offset %d", p.pos)
+		}
+	}
+}
+
+func (p *flowParser) list(depth int) ([]interface{}, error) {
+	p.pos++ // [
+	l := make([]interface{}, 0)
+	if p.consume(']') {
+		return l, nil
+	}
+	for {
+		val, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, val)
+		if p.consume(']') {
+			return l, nil
+		}
+		if !p.consume(',') {
+			return nil, fmt.Errorf("expected , or ] at offset %d", p.pos)
+		}
+	}
+}
+
+// scalar reads up to the next character in stop and returns it trimmed.
+func (p *flowParser) scalar(stop string) string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(stop, rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return strings.TrimSpace(p.s[start:p.pos])
+}
+
+// stringifyKeys returns v with every map[interface{}]interface{} it
+// contains converted into a map[string]interface{}, so that all maps
+// decoded from a config file are of the same type. Keys are formatted
+// using fmt.Sprint and converted back into the key type of the target
+// map when decoding.
+func stringifyKeys(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[fmt.Sprint(k)] = stringifyKeys(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range val {
+			val[k] = stringifyKeys(e)
+		}
+		return val
+	case []interface{}:
+		for i, e := range val {
+			val[i] = stringifyKeys(e)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// copyValue returns a deep copy of v, a value decoded from a config
+// file, copying any maps and slices it contains.
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, e := range val {
+			s[i] = copyValue(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// removeDigitSeparators returns s with the underscores that separate
+// its digits removed, e.g. 1_000_000 becomes 1000000. An underscore
+// that is not between two digits results in an error.
+func removeDigitSeparators(s string) (string, error) {
+	isDigit := func(i int) bool { return i >= 0 && i < len(s) && s[i] >= '0' && s[i] <= '9' }
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if !isDigit(i-1) || !isDigit(i+1) {
+			return "", fmt.Errorf("invalid digit separator in %q", s)
+		}
+	}
+	return b.String(), nil
+}
+
+// mergeMaps merges src into dst. Maps that are present in both are
+// merged recursively, while any other value in src replaces the value
+// in dst.
+func mergeMaps(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		if dstMap, isMap := dst[k].(map[string]interface{}); ok && isMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// resolvePaths makes the relative paths held by v absolute by joining
+// them with dir. v must be a string, or a pointer, slice or array of
+// strings. Empty strings are left as they are.
+func resolvePaths(v reflect.Value, dir string) error {
+	switch v.Kind() {
+	case reflect.String:
+		p := v.String()
+		if p == "" || filepath.IsAbs(p) {
+			return nil
+		}
+		abs, err := filepath.Abs(filepath.Join(dir, p))
+		if err != nil {
+			return err
+		}
+		v.SetString(abs)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return resolvePaths(v.Elem(), dir)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolvePaths(v.Index(i), dir); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("relpath is not supported on type %s", v.Type())
+	}
+	return nil
+}
+
+// unmarshalString calls u.UnmarshalString with s, returning a panic raised
+// by it as an error.
+func unmarshalString(u StringUnmarshaler, s string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return u.UnmarshalString(s)
+}
+
+// splitPath splits a path such as "spec.containers[0].image" into its
+// steps, which are either keys or bracketed indices: spec, containers, [0]
+// and image.
+func splitPath(path string) []string {
+	var steps []string
+	for path != "" {
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				end = len(path) - 1
+			}
+			steps = append(steps, path[:end+1])
+			path = path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			steps = append(steps, path[:end])
+			path = path[end:]
+		}
+	}
+	return steps
+}
+
+// transforms are the transforms that can be given in a transform tag, by name.
+var transforms = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"title": titleCase,
+}
+
+// transformValue applies the transforms named by names, in order, to the
+// string v or to each of the string elements of v.
+func transformValue(v reflect.Value, names []string) error {
+	for _, name := range names {
+		if _, ok := transforms[name]; !ok {
+			return fmt.Errorf("unknown transform %q", name)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		for _, name := range names {
+			s = transforms[name](s)
+		}
+		v.SetString(s)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return transformValue(v.Elem(), names)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := transformValue(v.Index(i), names); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("transform is not supported on type %s", v.Type())
+	}
+	return nil
+}
+
+// titleCase returns s with the first letter of each of its words,
+// which are separated by white space, mapped to upper case.
+func titleCase(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		isStart := unicode.IsSpace(prev)
+		prev = r
+		if isStart {
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, s)
+}
+
+// checkJSONDuplicates returns an error naming the first key that appears
+// more than once in the same object of the json document data, if any.
+// Errors in the syntax of data are left to the decoder.
+func checkJSONDuplicates(data []byte) error {
+	type frame struct {
+		keys   map[string]bool // nil if the frame is an array
+		key    string          // the last key of the object
+		index  int             // the index of the next element of the array
+		inKey  bool            // true if the next token of the object is a key
+		prefix string
+	}
+	var stack []*frame
+
+	// path returns the path of the value that the top of the stack is at
+	path := func() string {
+		top := stack[len(stack)-1]
+		if top.keys == nil {
+			return fmt.Sprintf("%s[%d]", top.prefix, top.index)
+		}
+		if top.prefix == "" {
+			return top.key
+		}
+		return top.prefix + "." + top.key
+	}
+	// value records that a value of the top of the stack has been read
+	value := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := stack[len(stack)-1]
+		if top.keys == nil {
+			top.index++
+		} else {
+			top.inKey = true
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		// the end of data, or a syntax error which is left to the decoder
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+
+		if len(stack) > 0 {
+			if top := stack[len(stack)-1]; top.keys != nil && top.inKey {
+				if key, ok := tok.(string); ok {
+					top.key, top.inKey = key, false
+					if top.keys[key] {
+						return fmt.Errorf("duplicate key %s", path())
+					}
+					top.keys[key] = true
+					continue
+				}
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			var prefix string
+			if len(stack) > 0 {
+				prefix = path()
+			}
+			fr := &frame{prefix: prefix}
+			if tok == json.Delim('{') {
+				fr.keys, fr.inKey = make(map[string]bool), true
+			}
+			stack = append(stack, fr)
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			value()
+		default:
+			value()
+		}
+	}
+}
+
+// nullsOnly returns a copy of m that contains only its null values and
+// the maps that contain them, or nil if m contains no null values. The
+// values of lists are not looked into.
+func nullsOnly(m map[string]interface{}) map[string]interface{} {
+	var nulls map[string]interface{}
+	for k, v := range m {
+		if sub, ok := v.(map[string]interface{}); ok {
+			sub = nullsOnly(sub)
+			if sub == nil {
+				continue
+			}
+			v = sub
+		} else if v != nil {
+			continue
+		}
+		if nulls == nil {
+			nulls = make(map[string]interface{})
+		}
+		nulls[k] = v
+	}
+	return nulls
+}
+
+// lookupKey returns the value of key in m. If m does not contain
+// key then a key that is equal to it under case-folding is looked
+// up instead.
+func lookupKey(m map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
 }
 
 // fileExists returns true if the file exists and is not a
@@ -40,16 +675,139 @@ func isZero(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Ptr, reflect.Interface:
 		return v.IsNil()
+	case reflect.Invalid:
+		return true
+	}
+
+	if z, ok := asZeroer(v); ok {
+		return z.IsZero()
+	}
+
+	switch v.Kind() {
 	case reflect.Slice, reflect.Array:
 		return v.Len() == 0
 	case reflect.Struct:
-		if t, ok := v.Interface().(time.Time); ok {
-			return t.IsZero()
-		}
 		return false
-	case reflect.Invalid:
-		return true
 	default:
 		return v.IsZero()
 	}
 }
+
+// equalValues reports whether a and b, which are of the same type, are
+// deeply equal. Unlike reflect.DeepEqual floats that are both NaN, on
+// their own or as elements of slices and arrays, are equal.
+func equalValues(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		return x == y || (math.IsNaN(x) && math.IsNaN(y))
+	case reflect.Slice, reflect.Array:
+		if a.Kind() == reflect.Slice && a.IsNil() != b.IsNil() {
+			return false
+		}
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// asZeroer returns v as an IsZeroer if v, or a pointer to v if v is
+// addressable, implements it.
+func asZeroer(v reflect.Value) (IsZeroer, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	if z, ok := v.Interface().(IsZeroer); ok {
+		return z, true
+	}
+	if v.CanAddr() {
+		if z, ok := v.Addr().Interface().(IsZeroer); ok {
+			return z, true
+		}
+	}
+	return nil, false
+}
+
+// isByteSeqType reports whether t is a slice or array of bytes.
+func isByteSeqType(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// decodeHex decodes the hex string s into a value of t, a slice or array
+// of bytes. An array must be given exactly as many bytes as its length.
+func decodeHex(t reflect.Type, s string) (reflect.Value, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid hex: %w", err)
+	}
+	if t.Kind() == reflect.Slice {
+		return reflect.ValueOf(b).Convert(t), nil
+	}
+	if len(b) != t.Len() {
+		return reflect.Value{}, fmt.Errorf("hex decodes to %d bytes, want %d for %s", len(b), t.Len(), t)
+	}
+	v := reflect.New(t).Elem()
+	reflect.Copy(v, reflect.ValueOf(b))
+	return v, nil
+}
+
+// sniffDecoder guesses the format of a config from data, a prefix of it,
+// by looking at its first line that is neither blank nor a comment. A line
+// that starts with `{` is json, as is one that starts with `[` unless it's
+// a toml table header such as `[server]`. A line of the form `key = value`
+// is toml. Anything else, including an empty config, is taken to be yaml.
+func sniffDecoder(data []byte) Decoder {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "{"):
+			return DecoderJSON
+		case isTOMLTable(line):
+			return DecoderToml
+		case strings.HasPrefix(line, "["):
+			return DecoderJSON
+		}
+		if key, _, ok := strings.Cut(line, "="); ok && isTOMLKey(strings.TrimSpace(key)) {
+			return DecoderToml
+		}
+		return DecoderYaml
+	}
+	return DecoderYaml
+}
+
+// isTOMLTable reports whether line is a toml table or array of tables
+// header, optionally followed by a comment.
+func isTOMLTable(line string) bool {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return false
+	}
+	return isTOMLKey(strings.TrimSpace(strings.Trim(line, "[]")))
+}
+
+// isTOMLKey reports whether s looks like a toml key, which may be dotted
+// and contain quoted parts.
+func isTOMLKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(`_-." '`, r) {
+			return false
+		}
+	}
+	return true
+}
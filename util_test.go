@@ -1,9 +1,11 @@
 package fig
 
 import (
+	"math"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -29,6 +31,30 @@ func Test_stringSlice(t *testing.T) {
 			In:   "[foo]",
 			Want: []string{"foo"},
 		},
+		{
+			In:   `[[a-z]{2,4},\d+]`,
+			Want: []string{"[a-z]{2,4}", `\d+`},
+		},
+		{
+			In:   "[(a,b),{c,d}]",
+			Want: []string{"(a,b)", "{c,d}"},
+		},
+		{
+			In:   `["a,b", 'c]', d]`,
+			Want: []string{"a,b", "c]", " d"},
+		},
+		{
+			In:   `[" x ","say \"hi\"",don't]`,
+			Want: []string{" x ", `say "hi"`, "don't"},
+		},
+		{
+			In:   `["a"b,c]`,
+			Want: []string{`"a"b`, "c"},
+		},
+		{
+			In:   "[a],b]",
+			Want: []string{"a]", "b"},
+		},
 	} {
 		t.Run(tc.In, func(t *testing.T) {
 			got := stringSlice(tc.In)
@@ -39,6 +65,357 @@ func Test_stringSlice(t *testing.T) {
 	}
 }
 
+func Test_expandEnv(t *testing.T) {
+	t.Setenv("FIG_HOME", "/home/fig")
+	t.Setenv("FIG_LIST", "a,b")
+	t.Setenv("FIG_EMPTY", "")
+
+	for _, tc := range []struct {
+		In   string
+		Want string
+	}{
+		{
+			In:   "plain",
+			Want: "plain",
+		},
+		{
+			In:   "${FIG_HOME}/bin",
+			Want: "/home/fig/bin",
+		},
+		{
+			In:   "${FIG_HOME}:${FIG_LIST}",
+			Want: "/home/fig:a,b",
+		},
+		{
+			In:   "${FIG_NOPE:-/usr}/bin",
+			Want: "/usr/bin",
+		},
+		{
+			In:   "${FIG_EMPTY:-/usr}/bin",
+			Want: "/usr/bin",
+		},
+		{
+			In:   "${FIG_EMPTY}/bin",
+			Want: "/bin",
+		},
+		{
+			In:   "$FIG_HOME",
+			Want: "$FIG_HOME",
+		},
+	} {
+		t.Run(tc.In, func(t *testing.T) {
+			got, err := defaultFig().expandEnv(tc.In)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got != tc.Want {
+				t.Fatalf("want %q, got %q", tc.Want, got)
+			}
+		})
+	}
+
+	for _, in := range []string{"${FIG_NOPE}", "${FIG_HOME"} {
+		t.Run(in, func(t *testing.T) {
+			_, err := defaultFig().expandEnv(in)
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+		})
+	}
+}
+
+func Test_parseBytes(t *testing.T) {
+	for _, tc := range []struct {
+		In   string
+		Want uint64
+	}{
+		{In: "0", Want: 0},
+		{In: "512", Want: 512},
+		{In: "512B", Want: 512},
+		{In: "10KB", Want: 10000},
+		{In: "10MB", Want: 10000000},
+		{In: "10mb", Want: 10000000},
+		{In: "2GiB", Want: 2 << 30},
+		{In: "1.5KiB", Want: 1536},
+		{In: " 4 MiB ", Want: 4 << 20},
+	} {
+		t.Run(tc.In, func(t *testing.T) {
+			got, err := parseBytes(tc.In)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got != tc.Want {
+				t.Fatalf("want %d, got %d", tc.Want, got)
+			}
+		})
+	}
+
+	for _, in := range []string{"", "MB", "10XB", "-1MB", "1.0001KB", "1.2.3", "16EiB"} {
+		t.Run(in, func(t *testing.T) {
+			_, err := parseBytes(in)
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+		})
+	}
+}
+
+func Test_parseFlow(t *testing.T) {
+	for _, tc := range []struct {
+		In   string
+		Want map[string]interface{}
+	}{
+		{
+			In:   "{}",
+			Want: map[string]interface{}{},
+		},
+		{
+			In:   "{host:localhost,port:8080}",
+			Want: map[string]interface{}{"host": "localhost", "port": "8080"},
+		},
+		{
+			In:   " { host : localhost , port: 8080 } ",
+			Want: map[string]interface{}{"host": "localhost", "port": "8080"},
+		},
+		{
+			In: "{tls:{enabled:true,ca:{path:/etc/ca.pem}},ips:[a, b],empty:[],blank:}",
+			Want: map[string]interface{}{
+				"tls": map[string]interface{}{
+					"enabled": "true",
+					"ca":      map[string]interface{}{"path": "/etc/ca.pem"},
+				},
+				"ips":   []interface{}{"a", "b"},
+				"empty": []interface{}{},
+				"blank": "",
+			},
+		},
+		{
+			In:   "{list:[{a:1},{a:2}]}",
+			Want: map[string]interface{}{"list": []interface{}{map[string]interface{}{"a": "1"}, map[string]interface{}{"a": "2"}}},
+		},
+	} {
+		t.Run(tc.In, func(t *testing.T) {
+			got, err := parseFlow(tc.In)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(tc.Want, got) {
+				t.Fatalf("\nwant %+v\ngot  %+v", tc.Want, got)
+			}
+		})
+	}
+
+	deep := strings.Repeat("{a:", maxFlowDepth+2) + "1" + strings.Repeat("}", maxFlowDepth+2)
+
+	for _, in := range []string{"", "host:localhost", "{host}", "{host:a", "{host:a}}", "{:a}", "{a:[1,2}", "[a]", deep} {
+		t.Run(in, func(t *testing.T) {
+			_, err := parseFlow(in)
+			if err == nil {
+				t.Fatalf("expected err")
+			}
+		})
+	}
+}
+
+func Test_stringifyKeys(t *testing.T) {
+	in := map[string]interface{}{
+		"ports": map[interface{}]interface{}{80: "http", 443: "https"},
+		"list": []interface{}{
+			map[interface{}]interface{}{true: "on", 1.5: map[interface{}]interface{}{"a": 1}},
+		},
+		"name": "app",
+	}
+
+	want := map[string]interface{}{
+		"ports": map[string]interface{}{"80": "http", "443": "https"},
+		"list": []interface{}{
+			map[string]interface{}{"true": "on", "1.5": map[string]interface{}{"a": 1}},
+		},
+		"name": "app",
+	}
+
+	if got := stringifyKeys(in); !reflect.DeepEqual(want, got) {
+		t.Fatalf("\nwant %+v\ngot  %+v", want, got)
+	}
+}
+
+func Test_removeDigitSeparators(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{in: "1000", want: "1000"},
+		{in: "1_000_000", want: "1000000"},
+		{in: "-1_000", want: "-1000"},
+		{in: "1_000.000_5", want: "1000.0005"},
+		{in: "1e1_0", want: "1e10"},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := removeDigitSeparators(tc.in)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("removeDigitSeparators(%q) == %q, expected %q", tc.in, got, tc.want)
+			}
+		})
+	}
+
+	for _, in := range []string{"_1000", "1000_", "1__000", "1_.5", "-_1", "1_e10"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := removeDigitSeparators(in); err == nil {
+				t.Fatalf("expected err")
+			}
+		})
+	}
+}
+
+func Test_checkJSONDuplicates(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{data: `{"a":1,"b":{"a":2},"c":[{"a":1},{"a":2}]}`},
+		{data: `{"a":1,"a":2}`, want: "duplicate key a"},
+		{data: `{"a":{"b":[1,2],"c":{},"b":3}}`, want: "duplicate key a.b"},
+		{data: `{"list":[{"x":1},{"y":1,"y":2}]}`, want: "duplicate key list[1].y"},
+		{data: `{"a":"a","b":"a","a":"b"}`, want: "duplicate key a"},
+		{data: `[{"k":1},{"k":1,"k":2}]`, want: "duplicate key [1].k"},
+		{data: `{"a":1,`},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			err := checkJSONDuplicates([]byte(tc.data))
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.want {
+				t.Fatalf("want err %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func Test_equalValues(t *testing.T) {
+	nan := math.NaN()
+	for _, tc := range []struct {
+		a, b interface{}
+		want bool
+	}{
+		{a: 1.5, b: 1.5, want: true},
+		{a: nan, b: nan, want: true},
+		{a: nan, b: 0.0, want: false},
+		{a: []float64{1, nan}, b: []float64{1, nan}, want: true},
+		{a: [2]float32{float32(nan), 1}, b: [2]float32{float32(nan), 2}, want: false},
+		{a: []float64(nil), b: []float64{}, want: false},
+		{a: "a", b: "a", want: true},
+	} {
+		if got := equalValues(reflect.ValueOf(tc.a), reflect.ValueOf(tc.b)); got != tc.want {
+			t.Errorf("equalValues(%v, %v) = %t, want %t", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func Test_sniffDecoder(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want Decoder
+	}{
+		{data: `{"host":"localhost"}`, want: DecoderJSON},
+		{data: "\n  [\n {\"a\":1}]", want: DecoderJSON},
+		{data: "\xef\xbb\xbf{}", want: DecoderJSON},
+		{data: "---\nhost: localhost\n", want: DecoderYaml},
+		{data: "# comment\nhost: localhost\n", want: DecoderYaml},
+		{data: "- a\n- b\n", want: DecoderYaml},
+		{data: "url: http://x?a=b\n", want: DecoderYaml},
+		{data: "", want: DecoderYaml},
+		{data: "host = \"localhost\"\n", want: DecoderToml},
+		{data: "# comment\n\nserver.port=80\n", want: DecoderToml},
+		{data: "[server] # the server\nhost = \"localhost\"\n", want: DecoderToml},
+		{data: "[[servers]]\nhost = \"a\"\n", want: DecoderToml},
+		{data: "[\"dotted.key\"]\n", want: DecoderToml},
+		{data: "[1, 2]", want: DecoderJSON},
+	} {
+		if got := sniffDecoder([]byte(tc.data)); got != tc.want {
+			t.Errorf("sniffDecoder(%q) = %s, want %s", tc.data, got, tc.want)
+		}
+	}
+}
+
+func Test_mergeMaps(t *testing.T) {
+	dst := map[string]interface{}{
+		"level":  "warn",
+		"peers":  []interface{}{"a", "b"},
+		"server": map[string]interface{}{"host": "example.com", "tls": true},
+		"db":     "postgres",
+	}
+	src := map[string]interface{}{
+		"level":  "debug",
+		"peers":  []interface{}{"c"},
+		"server": map[string]interface{}{"host": "localhost"},
+		"db":     map[string]interface{}{"name": "app"},
+	}
+
+	want := map[string]interface{}{
+		"level":  "debug",
+		"peers":  []interface{}{"c"},
+		"server": map[string]interface{}{"host": "localhost", "tls": true},
+		"db":     map[string]interface{}{"name": "app"},
+	}
+
+	mergeMaps(dst, src)
+	if !reflect.DeepEqual(want, dst) {
+		t.Fatalf("\nwant %+v\ngot  %+v", want, dst)
+	}
+}
+
+func Test_nullsOnly(t *testing.T) {
+	m := map[string]interface{}{
+		"level": nil,
+		"peers": []interface{}{nil},
+		"server": map[string]interface{}{
+			"host": nil,
+			"port": 80,
+		},
+		"db": map[string]interface{}{"name": "app"},
+	}
+
+	want := map[string]interface{}{
+		"level":  nil,
+		"server": map[string]interface{}{"host": nil},
+	}
+
+	if got := nullsOnly(m); !reflect.DeepEqual(want, got) {
+		t.Fatalf("\nwant %+v\ngot  %+v", want, got)
+	}
+	if got := nullsOnly(map[string]interface{}{"a": 1}); got != nil {
+		t.Fatalf("want nil, got %+v", got)
+	}
+}
+
+func Test_splitCamelCase(t *testing.T) {
+	for _, tc := range []struct {
+		In   string
+		Want string
+	}{
+		{In: "Host", Want: "Host"},
+		{In: "LogLevel", Want: "Log_Level"},
+		{In: "HTTPServer", Want: "HTTP_Server"},
+		{In: "UserID", Want: "User_ID"},
+		{In: "ID", Want: "ID"},
+		{In: "Base64Key", Want: "Base64_Key"},
+		{In: "log_level", Want: "log_level"},
+	} {
+		t.Run(tc.In, func(t *testing.T) {
+			if got := splitCamelCase(tc.In); got != tc.Want {
+				t.Fatalf("want %s, got %s", tc.Want, got)
+			}
+		})
+	}
+}
+
 func Test_fileExists(t *testing.T) {
 	dir := filepath.Join("testdata", "valid")
 	ok := fileExists(dir)
@@ -138,6 +515,32 @@ func Test_isZero(t *testing.T) {
 		}
 	})
 
+	t.Run("IsZeroer struct", func(t *testing.T) {
+		if isZero(reflect.ValueOf(money{Amount: 5})) == false {
+			t.Fatalf("isZero == false")
+		}
+		if isZero(reflect.ValueOf(money{Currency: "EUR"})) == true {
+			t.Fatalf("isZero == true")
+		}
+	})
+
+	t.Run("IsZeroer array with pointer receiver", func(t *testing.T) {
+		var id uuid
+		if isZero(reflect.ValueOf(&id).Elem()) == false {
+			t.Fatalf("isZero == false")
+		}
+		id[0] = 1
+		if isZero(reflect.ValueOf(&id).Elem()) == true {
+			t.Fatalf("isZero == true")
+		}
+	})
+
+	t.Run("non-nil pointer to IsZeroer is not zero", func(t *testing.T) {
+		if isZero(reflect.ValueOf(&money{})) == true {
+			t.Fatalf("isZero == true")
+		}
+	})
+
 	t.Run("zero regexp is zero", func(t *testing.T) {
 		var re *regexp.Regexp
 
@@ -178,3 +581,14 @@ func Test_isZero(t *testing.T) {
 		}
 	})
 }
+
+type money struct {
+	Amount   int64
+	Currency string
+}
+
+func (m money) IsZero() bool { return m.Currency == "" }
+
+type uuid [16]byte
+
+func (u *uuid) IsZero() bool { return *u == uuid{} }
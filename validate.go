@@ -0,0 +1,364 @@
+package fig
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validateRelations validates the rules of field that depend on other
+// fields of cfg, and is called by processCfg for each field after all
+// fields have been processed. fields contains every field of cfg.
+func (f *fig) validateRelations(field *field, fields []*field) error {
+	if field.requiredWith == "" && field.requiredWithout == "" {
+		return nil
+	}
+
+	if f.isSet(field) {
+		return nil
+	}
+
+	if name := field.requiredWith; name != "" {
+		other, err := sibling(field, fields, name)
+		if err != nil {
+			return err
+		}
+		if f.isSet(other) {
+			return fmt.Errorf("required validation failed: required when %s is set", other.name())
+		}
+	}
+
+	if name := field.requiredWithout; name != "" {
+		other, err := sibling(field, fields, name)
+		if err != nil {
+			return err
+		}
+		if !f.isSet(other) {
+			return fmt.Errorf("required validation failed: required when %s is not set", other.name())
+		}
+	}
+
+	return nil
+}
+
+// validateGroups checks that exactly one field of each required group, a
+// named set of sibling fields sharing a required_group validation, is set.
+// It is called by processCfg after all fields have been processed and
+// records an error in errs for each group that fails, keyed by the path
+// of the group.
+func (f *fig) validateGroups(fields []*field, errs fieldErrors) {
+	type group struct {
+		path    string
+		members []*field
+	}
+	var groups []*group
+	byPath := make(map[string]*group)
+
+	for _, fd := range fields {
+		// slice elements and map entries share the tag of their field
+		if fd.requiredGroup == "" || fd.sliceIdx >= 0 || fd.mapKey != nil {
+			continue
+		}
+		path := fd.requiredGroup
+		if parent := fd.parent.path(); parent != "" {
+			path = parent + "." + path
+		}
+		g, ok := byPath[path]
+		if !ok {
+			g = &group{path: path}
+			byPath[path] = g
+			groups = append(groups, g)
+		}
+		g.members = append(g.members, fd)
+	}
+
+	for _, g := range groups {
+		names := make([]string, 0, len(g.members))
+		set := 0
+		for _, fd := range g.members {
+			names = append(names, fd.name())
+			if f.isSet(fd) {
+				set++
+			}
+		}
+		if set != 1 {
+			errs[g.path] = fmt.Errorf("exactly one of [%s] must be set", strings.Join(names, " "))
+		}
+	}
+}
+
+// validateDeepRequired checks that a required struct field fd is not empty
+// when deep required is enabled, or when it has a required_nonempty
+// validation, and is called by processCfg for each field after all fields
+// have been processed. A struct is empty if none of its fields, or the
+// fields of the structs it contains, are set other than to their default.
+func (f *fig) validateDeepRequired(fd *field) error {
+	if !fd.required || fd.v.Kind() != reflect.Struct {
+		return nil
+	}
+	// only fields declared as structs, as pointers signal presence by being non-nil
+	if !fd.requiredNonEmpty && (!f.deepRequired || fd.st.Type != fd.v.Type()) {
+		return nil
+	}
+	// types that define their own zero value are checked by processField
+	if _, ok := asZeroer(fd.v); ok {
+		return nil
+	}
+
+	defaulted := make(map[string]bool, len(f.info.Defaults))
+	for _, path := range f.info.Defaults {
+		defaulted[path] = true
+	}
+
+	root := *fd
+	var fs []*field
+	flattenField(&root, &fs, f.tag)
+	for _, child := range fs {
+		if child.v.Kind() == reflect.Struct {
+			if _, ok := asZeroer(child.v); !ok {
+				continue // its fields are checked instead
+			}
+		}
+		if !defaulted[child.path()] && f.isSet(child) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("required validation failed: all fields are empty")
+}
+
+// validateUnique checks that the elements of a slice or array field fd
+// with a unique validation are unique, comparing the element field named
+// by the validation if it has an argument. Nil elements are skipped. It
+// is called by processCfg for each field after all fields have been
+// processed.
+func (f *fig) validateUnique(fd *field) error {
+	// slice elements share the tag of their field
+	if !fd.unique || fd.sliceIdx >= 0 || fd.mapKey != nil {
+		return nil
+	}
+
+	v := fd.v
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		return nil
+	case reflect.Slice, reflect.Array:
+	default:
+		return fmt.Errorf("unique validation failed: unsupported type %s", v.Type())
+	}
+
+	what := "value"
+	if fd.uniqueKey != "" {
+		what = fd.uniqueKey
+	}
+
+	seen := make(map[interface{}]bool, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+			elem = elem.Elem()
+		}
+		if !elem.IsValid() {
+			continue
+		}
+		if fd.uniqueKey != "" {
+			var err error
+			if elem, err = f.structFieldByName(elem, fd.uniqueKey); err != nil {
+				return fmt.Errorf("unique validation failed: %w", err)
+			}
+		}
+		if !elem.Type().Comparable() {
+			return fmt.Errorf("unique validation failed: %s of type %s cannot be compared", what, elem.Type())
+		}
+		key := elem.Interface()
+		if seen[key] {
+			if fd.secret {
+				return fmt.Errorf("unique validation failed: duplicate %s at index %d", what, i)
+			}
+			return fmt.Errorf("unique validation failed: duplicate %s %#v at index %d", what, key, i)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// validateKeys checks that each of the keys of a map field fd passes the
+// format validations given for keys in its validate tag. Keys are checked
+// in sorted order so that the error reports the same key on every load. It
+// is called by processCfg for each field after all fields have been
+// processed, so the keys set by the environment are checked too.
+func validateKeys(fd *field) error {
+	// map entries share the tag of their field
+	if len(fd.keyFormats) == 0 || fd.sliceIdx >= 0 || fd.mapKey != nil {
+		return nil
+	}
+
+	v := fd.v
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Ptr:
+		return nil
+	case v.Kind() != reflect.Map:
+		return fmt.Errorf("key validation failed: unsupported type %s", v.Type())
+	case v.Type().Key().Kind() != reflect.String:
+		return fmt.Errorf("key validation failed: unsupported key type %s", v.Type().Key())
+	}
+
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, format := range fd.keyFormats {
+			if err := formatValidators[format](key); err != nil {
+				return fmt.Errorf("invalid key %q: %s validation failed", key, format)
+			}
+		}
+	}
+
+	return nil
+}
+
+// structFieldByName returns the field of the struct v with the given name,
+// which may be either its name as defined in the struct or its alt name.
+func (f *fig) structFieldByName(v reflect.Value, name string) (reflect.Value, error) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("elements of type %s have no field %s", v.Type(), name)
+	}
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if sf.Name == name || parseTag(sf.Tag, f.tag).altName == name {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown field %s", name)
+}
+
+// sibling returns the field of fields that shares the same parent as
+// field and has the given name. name may be either the field's name
+// as defined in the struct or its alt name.
+func sibling(field *field, fields []*field, name string) (*field, error) {
+	for _, other := range fields {
+		if other == field || other.parent != field.parent || other.sliceIdx >= 0 || other.mapKey != nil {
+			continue
+		}
+		if other.st.Name == name || (other.altName != "" && other.altName == name) {
+			return other, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown field %s", name)
+}
+
+// formatValidators maps the names of the supported format validations
+// to a function that checks whether a string is of that format. The
+// errors they return describe the string without repeating it, which
+// is left to the caller as the string may be secret.
+var formatValidators = map[string]func(string) error{
+	"email":    validateEmail,
+	"url":      validateURL,
+	"hostname": validateHostname,
+}
+
+// validateStrings checks that the value of field satisfies each of the
+// string validations in its validate tag, i.e. its formats and contains
+// and excludes rules. Empty values are not checked, which leaves that to
+// a required validation. The elements of slices are checked individually.
+func validateStrings(field *field) error {
+	if len(field.formats) == 0 && len(field.contains) == 0 && len(field.excludes) == 0 {
+		return nil
+	}
+
+	v := field.v
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	var vals []string
+	switch {
+	case v.Kind() == reflect.Ptr:
+		return nil
+	case v.Kind() == reflect.String:
+		vals = []string{v.String()}
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < v.Len(); i++ {
+			vals = append(vals, v.Index(i).String())
+		}
+	default:
+		return fmt.Errorf("string validation failed: unsupported type %s", v.Type())
+	}
+
+	for _, val := range vals {
+		if val == "" {
+			continue
+		}
+		for _, format := range field.formats {
+			if err := formatValidators[format](val); err != nil {
+				return fmt.Errorf("%s validation failed: %s %w", format, displayValue(field, val), err)
+			}
+		}
+		for _, sub := range field.contains {
+			if !strings.Contains(val, sub) {
+				return fmt.Errorf("contains validation failed: %s does not contain %q", displayValue(field, val), sub)
+			}
+		}
+		for _, sub := range field.excludes {
+			if strings.Contains(val, sub) {
+				return fmt.Errorf("excludes validation failed: %s contains %q", displayValue(field, val), sub)
+			}
+		}
+	}
+
+	return nil
+}
+
+// displayValue returns s quoted for the error of a validation of fd, or
+// "value" if fd is secret so that the error does not leak it.
+func displayValue(fd *field, s string) string {
+	if fd.secret {
+		return "value"
+	}
+	return strconv.Quote(s)
+}
+
+func validateEmail(s string) error {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return errors.New("is not a valid email address")
+	}
+	return nil
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("is not a valid url")
+	}
+	return nil
+}
+
+// hostnameRegexp matches hostnames as defined by RFC 1123.
+var hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]{0,61}[a-zA-Z0-9])(\.([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]{0,61}[a-zA-Z0-9]))*$`)
+
+func validateHostname(s string) error {
+	if len(s) > 253 || !hostnameRegexp.MatchString(s) {
+		return errors.New("is not a valid hostname")
+	}
+	return nil
+}
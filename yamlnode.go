@@ -0,0 +1,119 @@
+package fig
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetField sets the value at path in the yaml document node, such as one
+// retained with RetainYAMLNode, to value. path is a dot separated path of
+// keys in which the elements of lists are given by their index, e.g.
+// "servers[0].host". Keys of path that do not exist are added to their
+// mapping, while list elements must already exist.
+//
+// The comments of the value that is replaced are kept, so that the document
+// can be written back with WriteYAML without losing them.
+func SetField(node *yaml.Node, path string, value interface{}) error {
+	steps, err := parseNodePath(path)
+	if err != nil {
+		return err
+	}
+
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			node.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+		}
+		node = node.Content[0]
+	}
+
+	for i, step := range steps {
+		node, err = childNode(node, step, i == len(steps)-1 || steps[i+1].key == "")
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var nv yaml.Node
+	if err := nv.Encode(value); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	nv.HeadComment, nv.LineComment, nv.FootComment = node.HeadComment, node.LineComment, node.FootComment
+	*node = nv
+	return nil
+}
+
+// WriteYAML writes the yaml document node to w, including its comments.
+func WriteYAML(node *yaml.Node, w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// nodeStep is a step of a path into a yaml node, which is either the key
+// of a mapping or, if key is empty, the index of a list element.
+type nodeStep struct {
+	key   string
+	index int
+}
+
+// parseNodePath parses a path such as "servers[0].host" into its steps.
+func parseNodePath(path string) ([]nodeStep, error) {
+	var steps []nodeStep
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key == "" && (len(steps) == 0 || rest == "") {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		if key != "" {
+			steps = append(steps, nodeStep{key: key})
+		}
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			i, err := strconv.Atoi(idx)
+			if !ok || err != nil || i < 0 || (after != "" && after[0] != '[') {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			steps = append(steps, nodeStep{index: i})
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return steps, nil
+}
+
+// childNode returns the child of node that step leads to. A missing key is
+// added to the mapping node, with a null value if leaf is true and an empty
+// mapping otherwise.
+func childNode(node *yaml.Node, step nodeStep, leaf bool) (*yaml.Node, error) {
+	if step.key == "" {
+		if node.Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("cannot index into a non-list value")
+		}
+		if step.index >= len(node.Content) {
+			return nil, fmt.Errorf("index %d out of range for list of length %d", step.index, len(node.Content))
+		}
+		return node.Content[step.index], nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("cannot set key %q of a non-mapping value", step.key)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == step.key {
+			return node.Content[i+1], nil
+		}
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if leaf {
+		child = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: step.key}, child)
+	return child, nil
+}
@@ -0,0 +1,102 @@
+package fig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSetField(t *testing.T) {
+	data := `# server settings
+server:
+  host: localhost # the host to bind
+  port: 8080
+servers:
+  - name: a
+  - name: b
+`
+
+	type Config struct {
+		Server struct {
+			Host string `fig:"host"`
+			Port int    `fig:"port"`
+		} `fig:"server"`
+	}
+
+	var (
+		cfg  Config
+		node yaml.Node
+	)
+	if err := Load(&cfg, Reader(strings.NewReader(data), DecoderYaml), RetainYAMLNode(&node)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.Server.Host != "localhost" {
+		t.Fatalf("cfg not loaded, got %+v", cfg)
+	}
+
+	for path, value := range map[string]interface{}{
+		"server.host":     "0.0.0.0",
+		"server.port":     8443,
+		"servers[1].name": "c",
+		"logger.level":    "debug",
+	} {
+		if err := SetField(&node, path, value); err != nil {
+			t.Fatalf("SetField(%s): unexpected err: %v", path, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteYAML(&node, &buf); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := `# server settings
+server:
+  host: 0.0.0.0 # the host to bind
+  port: 8443
+servers:
+  - name: a
+  - name: c
+logger:
+  level: debug
+`
+	if got := buf.String(); got != want {
+		t.Errorf("\nwant:\n%s\ngot:\n%s", want, got)
+	}
+
+	for _, tc := range []struct {
+		path    string
+		wantErr string
+	}{
+		{path: "servers[5].name", wantErr: "index 5 out of range for list of length 2"},
+		{path: "server.host.name", wantErr: `cannot set key "name" of a non-mapping value`},
+		{path: "server[0]", wantErr: "cannot index into a non-list value"},
+		{path: "servers[x]", wantErr: `invalid path "servers[x]"`},
+		{path: "", wantErr: `invalid path ""`},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			err := SetField(&node, tc.path, 1)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("want err %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_parseNodePath(t *testing.T) {
+	steps, err := parseNodePath("a.b[1][2].c")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []nodeStep{{key: "a"}, {key: "b"}, {index: 1}, {index: 2}, {key: "c"}}
+	if len(steps) != len(want) {
+		t.Fatalf("want %+v, got %+v", want, steps)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("want %+v, got %+v", want, steps)
+		}
+	}
+}